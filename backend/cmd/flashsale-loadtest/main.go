@@ -0,0 +1,355 @@
+// Command flashsale-loadtest runs a flash sale rush against the real API and
+// database and checks that nothing is oversold.
+//
+// It creates a seller with one product and a crowd of buyers in the database
+// of the .env file, so point it at a scratch database. The seller opens a
+// flash sale through the API; then, all at the same moment, flash buyers
+// claim units and check them out (cart checkout, then order) while regular
+// buyers put the same product in their cart and check it out at the fixed
+// price. Some flash buyers never check out, their claims keep holding the
+// stock. The background jobs run alongside as in the server.
+//
+// Afterwards the stored rows are checked: the stock never went below zero
+// and matches the ordered units, no more units were claimed than the sale
+// had, no buyer got more than the limit, every purchased claim has its
+// order item, and the allocator's remaining units add up with the stored
+// claims. The command exits with status 1 when a check fails.
+//
+//	go run ./cmd/flashsale-loadtest -units 50 -stock 60 -buyers 300 -regular 30 -limit 2
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"finpro/config"
+	"finpro/controllers"
+	"finpro/database"
+	"finpro/models"
+	"finpro/rbac"
+	"finpro/routes"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var app *fiber.App
+
+func main() {
+	units := flag.Int("units", 50, "units in the flash sale")
+	stock := flag.Int("stock", 60, "stock of the product, shared with regular buyers")
+	limit := flag.Int("limit", 2, "units per flash buyer, 0 for no limit")
+	buyers := flag.Int("buyers", 300, "concurrent flash buyers")
+	regular := flag.Int("regular", 30, "concurrent buyers at the fixed price")
+	quantity := flag.Int("quantity", 2, "largest quantity per claim")
+	checkout := flag.Float64("checkout", 0.8, "share of flash buyers who check out their claim")
+	conns := flag.Int("conns", 50, "database connections")
+	flag.Parse()
+
+	config.ENVLoad()
+	database.Init()
+	database.Migrate()
+	if err := rbac.Load(database.DB); err != nil {
+		panic(err)
+	}
+	if err := controllers.InitSearch(); err != nil {
+		panic(err)
+	}
+	if sqlDB, err := database.DB.DB(); err == nil {
+		sqlDB.SetMaxOpenConns(*conns)
+	}
+	controllers.StartBackgroundJobs()
+
+	app = fiber.New()
+	routes.Routes(app)
+
+	tag := time.Now().UnixNano()
+	seller := createUser(fmt.Sprintf("loadtest-seller-%d", tag), "seller")
+	shop := models.Shop{UserID: uint(seller.ID), ShopName: fmt.Sprintf("Loadtest %d", tag), StatusAdmin: "approve"}
+	must(database.DB.Create(&shop).Error)
+	product := models.Product{
+		ShopID: shop.ID,
+		Name:   fmt.Sprintf("Loadtest product %d", tag),
+		Image:  "http://127.0.0.1:3000/assets/products/loadtest.png",
+		Price:  100000,
+		Stock:  *stock,
+		Status: models.ProductStatusPublished,
+	}
+	must(database.DB.Create(&product).Error)
+
+	status, body := call(http.MethodPost, "/api/v1/flash-sales", token(seller), jsonBody(fiber.Map{
+		"product_id":     product.ID,
+		"name":           "Loadtest",
+		"percent":        50,
+		"quantity":       *units,
+		"per_user_limit": *limit,
+		"ends_at":        time.Now().Add(time.Hour),
+	}))
+	if status != fiber.StatusCreated {
+		fmt.Fprintf(os.Stderr, "failed to create the flash sale: %d %v\n", status, body["error"])
+		os.Exit(1)
+	}
+	saleID := uint(body["data"].(map[string]interface{})["id"].(float64))
+	fmt.Printf("sale %d of product %d: %d units, stock %d, limit %d\n", saleID, product.ID, *units, *stock, *limit)
+
+	users := make([]models.User, *buyers+*regular)
+	for i := range users {
+		users[i] = createUser(fmt.Sprintf("loadtest-buyer-%d-%d", tag, i), "buyer")
+	}
+
+	var results sync.Map
+	count := func(key string) {
+		counter, _ := results.LoadOrStore(key, new(atomic.Int64))
+		counter.(*atomic.Int64).Add(1)
+	}
+
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i, user := range users {
+		wg.Add(1)
+		go func(i int, user models.User) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(tag + int64(i)))
+			session := token(user)
+			<-start
+
+			var cartID uint
+			if i < *buyers {
+				status, body := call(http.MethodPost, fmt.Sprintf("/api/v1/flash-sales/%d/claim", saleID), session,
+					jsonBody(fiber.Map{"quantity": 1 + rng.Intn(*quantity)}))
+				count(fmt.Sprintf("claim %d", status))
+				if status != fiber.StatusCreated {
+					return
+				}
+				if rng.Float64() >= *checkout {
+					return
+				}
+				cartID = uint(body["cart_id"].(float64))
+			} else {
+				status, _ := call(http.MethodPost, "/api/v1/cart", session, jsonBody(fiber.Map{"product_id": product.ID}))
+				count(fmt.Sprintf("add to cart %d", status))
+				var item models.CartItem
+				if err := database.DB.Where("user_id = ? AND product_id = ?", user.ID, product.ID).First(&item).Error; err != nil {
+					return
+				}
+				cartID = item.ID
+			}
+
+			status, _ := call(http.MethodPost, "/api/v1/cart/checkout", session, jsonBody(fiber.Map{"cart_ids": []uint{cartID}}))
+			count(fmt.Sprintf("checkout %d", status))
+			if status != fiber.StatusOK {
+				return
+			}
+			status, _ = call(http.MethodPost, "/api/v1/orders", session, orderForm(shop.ID, cartID))
+			count(fmt.Sprintf("order %d", status))
+		}(i, user)
+	}
+
+	began := time.Now()
+	close(start)
+	wg.Wait()
+	fmt.Printf("%d flash buyers and %d regular buyers in %s\n", *buyers, *regular, time.Since(began).Round(time.Millisecond))
+	results.Range(func(key, counter any) bool {
+		fmt.Printf("  %-20s %d\n", key, counter.(*atomic.Int64).Load())
+		return true
+	})
+
+	if !verify(product, saleID, *units, *stock, *limit) {
+		os.Exit(1)
+	}
+	fmt.Println("OK: no overselling")
+}
+
+// verify checks the stored rows after the rush and reports every failure.
+func verify(product models.Product, saleID uint, units, stock, limit int) bool {
+	ok := true
+	fail := func(format string, args ...interface{}) {
+		fmt.Printf("FAIL: "+format+"\n", args...)
+		ok = false
+	}
+
+	must(database.DB.First(&product, product.ID).Error)
+	var ordered, flashOrdered int64
+	must(database.DB.Model(&models.OrderItem{}).Where("product_id = ?", product.ID).
+		Select("COALESCE(SUM(quantity), 0)").Scan(&ordered).Error)
+	must(database.DB.Model(&models.OrderItem{}).Where("product_id = ? AND flash_claim_id IS NOT NULL", product.ID).
+		Select("COALESCE(SUM(quantity), 0)").Scan(&flashOrdered).Error)
+
+	var claims []models.FlashSaleClaim
+	must(database.DB.Where("flash_sale_id = ?", saleID).Find(&claims).Error)
+	held, purchased := 0, 0
+	perUser := map[uint]int{}
+	for _, claim := range claims {
+		switch claim.Status {
+		case models.FlashClaimHeld:
+			held += claim.Quantity
+		case models.FlashClaimPurchased:
+			purchased += claim.Quantity
+		default:
+			continue
+		}
+		perUser[claim.UserID] += claim.Quantity
+	}
+
+	remaining := -1
+	_, body := call(http.MethodGet, "/api/v1/flash-sales", "", nil)
+	if sales, _ := body["data"].([]interface{}); sales != nil {
+		for _, raw := range sales {
+			sale := raw.(map[string]interface{})
+			if uint(sale["id"].(float64)) == saleID && sale["remaining"] != nil {
+				remaining = int(sale["remaining"].(float64))
+			}
+		}
+	}
+
+	fmt.Printf("stock %d left of %d, %d ordered (%d at the flash price)\n", product.Stock, stock, ordered, flashOrdered)
+	fmt.Printf("claims: %d held, %d purchased, %d remaining in memory\n", held, purchased, remaining)
+
+	if product.Stock < 0 {
+		fail("stock went down to %d", product.Stock)
+	}
+	if product.Stock+int(ordered) != stock {
+		fail("%d left and %d ordered do not add up to the stock of %d", product.Stock, ordered, stock)
+	}
+	if held+purchased > units {
+		fail("oversold, %d units claimed for a sale of %d", held+purchased, units)
+	}
+	if int(flashOrdered) != purchased {
+		fail("%d units were ordered at the flash price, %d claimed units were purchased", flashOrdered, purchased)
+	}
+	if limit > 0 {
+		for userID, quantity := range perUser {
+			if quantity > limit {
+				fail("buyer %d got %d units, the limit is %d", userID, quantity, limit)
+			}
+		}
+	}
+	if remaining < 0 {
+		fail("the flash sale is not loaded in memory")
+	} else if remaining+held+purchased != units {
+		fail("%d remaining, %d held and %d purchased do not add up to %d units", remaining, held, purchased, units)
+	}
+
+	removePaymentProofs(product.ID)
+	return ok
+}
+
+// removePaymentProofs deletes the proof images the orders of the run saved.
+func removePaymentProofs(productID uint) {
+	var proofs []string
+	database.DB.Model(&models.Order{}).
+		Where("id IN (?)", database.DB.Model(&models.OrderItem{}).Select("order_id").Where("product_id = ?", productID)).
+		Pluck("proof_payment", &proofs)
+	for _, proof := range proofs {
+		if proof != "" {
+			_ = os.Remove(filepath.Join("./assets/payments", filepath.Base(proof)))
+		}
+	}
+}
+
+func createUser(name, role string) models.User {
+	user := models.User{
+		Username:  name,
+		Email:     name + "@loadtest.local",
+		Role:      role,
+		Address:   "Jl. Loadtest 1",
+		Telephone: "081234567890",
+	}
+	must(database.DB.Create(&user).Error)
+	return user
+}
+
+// token signs a session like the login does.
+func token(user models.User) string {
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"id":       user.ID,
+		"username": user.Username,
+		"role":     user.Role,
+		"exp":      time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(os.Getenv("JWT_SECRET")))
+	must(err)
+	return signed
+}
+
+type requestBody struct {
+	contentType string
+	data        io.Reader
+}
+
+func jsonBody(value interface{}) *requestBody {
+	data, err := json.Marshal(value)
+	must(err)
+	return &requestBody{contentType: fiber.MIMEApplicationJSON, data: bytes.NewReader(data)}
+}
+
+// orderForm is the checkout form of the order page with a tiny payment
+// proof.
+func orderForm(shopID, cartID uint) *requestBody {
+	var buf bytes.Buffer
+	form := multipart.NewWriter(&buf)
+	for key, value := range map[string]string{
+		"shop_id":    fmt.Sprint(shopID),
+		"recipient":  "Loadtest",
+		"telephone":  "081234567890",
+		"address":    "Jl. Loadtest 1",
+		"note":       "",
+		"cart_ids[]": fmt.Sprint(cartID),
+	} {
+		must(form.WriteField(key, value))
+	}
+	file, err := form.CreateFormFile("proof_payment", "proof.png")
+	must(err)
+	_, err = file.Write([]byte("\x89PNG\r\n\x1a\n"))
+	must(err)
+	must(form.Close())
+	return &requestBody{contentType: form.FormDataContentType(), data: &buf}
+}
+
+// call sends a request through the routes of the server and decodes the
+// JSON answer.
+func call(method, path, session string, body *requestBody) (int, map[string]interface{}) {
+	var data io.Reader
+	if body != nil {
+		data = body.data
+	}
+	req := httptest.NewRequest(method, path, data)
+	if body != nil {
+		req.Header.Set(fiber.HeaderContentType, body.contentType)
+	}
+	if session != "" {
+		req.AddCookie(&http.Cookie{Name: "token", Value: session})
+	}
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %s: %v\n", method, path, err)
+		return 0, nil
+	}
+	defer resp.Body.Close()
+
+	decoded := map[string]interface{}{}
+	raw, _ := io.ReadAll(resp.Body)
+	if err := json.Unmarshal(raw, &decoded); err != nil && strings.TrimSpace(string(raw)) != "" {
+		decoded["error"] = string(raw)
+	}
+	return resp.StatusCode, decoded
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"finpro/database"
+	"finpro/models"
+	"finpro/rbac"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const maxAPIKeysPerUser = 10
+
+func CreateAPIKey(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*jwt.Token)
+	claims := userToken.Claims.(jwt.MapClaims)
+	userID := int(claims["id"].(float64))
+	role := claims["role"].(string)
+
+	var body struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	name := strings.TrimSpace(body.Name)
+	if name == "" || len(name) > 100 {
+		return c.Status(400).JSON(fiber.Map{"error": "Name is required (max 100 characters)"})
+	}
+
+	if len(body.Scopes) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "At least one scope is required"})
+	}
+
+	// A key can never do more than its owner, so scopes are limited to
+	// permissions the owner's role has right now.
+	for _, scope := range body.Scopes {
+		if !rbac.Known(scope) || !rbac.Can(role, scope) {
+			return c.Status(400).JSON(fiber.Map{"error": "Scope not allowed: " + scope})
+		}
+	}
+
+	if body.ExpiresInDays < 0 || body.ExpiresInDays > 365 {
+		return c.Status(400).JSON(fiber.Map{"error": "expires_in_days must be between 0 (never) and 365"})
+	}
+
+	var activeCount int64
+	database.DB.Model(&models.APIKey{}).Where("user_id = ? AND revoked_at IS NULL", userID).Count(&activeCount)
+	if activeCount >= maxAPIKeysPerUser {
+		return c.Status(400).JSON(fiber.Map{"error": "API key limit reached, revoke an unused key first"})
+	}
+
+	prefixBytes := make([]byte, 4)
+	secretBytes := make([]byte, 24)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to generate API key"})
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to generate API key"})
+	}
+
+	prefix := hex.EncodeToString(prefixBytes)
+	key := models.APIKeyPrefix + prefix + "_" + hex.EncodeToString(secretBytes)
+
+	apiKey := models.APIKey{
+		UserID:  userID,
+		Name:    name,
+		Prefix:  prefix,
+		KeyHash: models.HashAPIKey(key),
+		Scopes:  strings.Join(body.Scopes, ","),
+	}
+	if body.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, body.ExpiresInDays)
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	if err := database.DB.Create(&apiKey).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create API key"})
+	}
+
+	recordAudit(c, "api_key.create", "api_key", apiKey.ID, nil, apiKeyResponse(apiKey))
+
+	response := apiKeyResponse(apiKey)
+	response["key"] = key
+
+	return c.Status(201).JSON(fiber.Map{
+		"status":  "success",
+		"message": "API key created. Copy it now, it will not be shown again",
+		"data":    response,
+	})
+}
+
+func GetAPIKeys(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*jwt.Token)
+	claims := userToken.Claims.(jwt.MapClaims)
+	userID := int(claims["id"].(float64))
+
+	var keys []models.APIKey
+	if err := database.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch API keys"})
+	}
+
+	data := []fiber.Map{}
+	for _, key := range keys {
+		data = append(data, apiKeyResponse(key))
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   data,
+	})
+}
+
+func RevokeAPIKey(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*jwt.Token)
+	claims := userToken.Claims.(jwt.MapClaims)
+	userID := int(claims["id"].(float64))
+
+	var apiKey models.APIKey
+	if err := database.DB.Where("id = ? AND user_id = ?", c.Params("id"), userID).First(&apiKey).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "API key not found"})
+	}
+
+	if apiKey.RevokedAt != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "API key is already revoked"})
+	}
+
+	before := apiKeyResponse(apiKey)
+	now := time.Now()
+	if err := database.DB.Model(&apiKey).Update("revoked_at", now).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to revoke API key"})
+	}
+
+	recordAudit(c, "api_key.revoke", "api_key", apiKey.ID, before, apiKeyResponse(apiKey))
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "API key revoked",
+	})
+}
+
+func apiKeyResponse(key models.APIKey) fiber.Map {
+	return fiber.Map{
+		"id":           key.ID,
+		"name":         key.Name,
+		"prefix":       models.APIKeyPrefix + key.Prefix,
+		"scopes":       key.ScopeList(),
+		"last_used_at": key.LastUsedAt,
+		"expires_at":   key.ExpiresAt,
+		"revoked_at":   key.RevokedAt,
+		"active":       key.Active(time.Now()),
+		"created_at":   key.CreatedAt,
+	}
+}
@@ -0,0 +1,580 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"finpro/database"
+	"finpro/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// auctionSnipeWindow is how long an auction keeps running after the last
+// bid: a bid closer to the end moves the end this far past the bid.
+const auctionSnipeWindow = 2 * time.Minute
+
+type auctionError struct {
+	status  int
+	message string
+}
+
+func (e *auctionError) Error() string {
+	return e.message
+}
+
+// GetAuctions lists auctions for bidders, running ones by default. Use
+// ?status= for others and ?shop_id= for the auctions of one shop.
+func GetAuctions(c *fiber.Ctx) error {
+	query := database.DB.Preload("Product").
+		Where("status = ? AND shop_id NOT IN (?)", c.Query("status", models.AuctionRunning), hiddenShopIDs())
+	if shopID := c.Query("shop_id"); shopID != "" {
+		query = query.Where("shop_id = ?", shopID)
+	}
+
+	auctions := []models.Auction{}
+	if err := query.Order("ends_at ASC, id ASC").Limit(100).Find(&auctions).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch auctions"})
+	}
+	for i := range auctions {
+		presentAuction(&auctions[i], false)
+	}
+
+	return c.JSON(fiber.Map{"status": "success", "data": auctions})
+}
+
+// GetMyAuctions lists the auctions of the seller's shop, with reserve prices.
+func GetMyAuctions(c *fiber.Ctx) error {
+	shop, err := sellerShop(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	query := database.DB.Preload("Product").Where("shop_id = ?", shop.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	auctions := []models.Auction{}
+	if err := query.Order("created_at DESC, id DESC").Find(&auctions).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch auctions"})
+	}
+	for i := range auctions {
+		presentAuction(&auctions[i], true)
+	}
+
+	return c.JSON(fiber.Map{"status": "success", "data": auctions})
+}
+
+// GetAuction shows an auction with its latest bids. Bidders are shown by a
+// masked username only.
+func GetAuction(c *fiber.Ctx) error {
+	var auction models.Auction
+	if err := database.DB.Preload("Product").First(&auction, c.Params("id")).Error; err != nil || shopHidden(auction.ShopID) {
+		return c.Status(404).JSON(fiber.Map{"error": "Auction not found"})
+	}
+	presentAuction(&auction, false)
+
+	var bids []models.Bid
+	if err := database.DB.Preload("User").Where("auction_id = ?", auction.ID).
+		Order("amount DESC, id DESC").Limit(50).Find(&bids).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch bids"})
+	}
+
+	history := make([]fiber.Map, 0, len(bids))
+	for _, bid := range bids {
+		bidder := ""
+		if bid.User != nil {
+			bidder = maskName(bid.User.Username)
+		}
+		history = append(history, fiber.Map{
+			"amount":     bid.Amount,
+			"bidder":     bidder,
+			"created_at": bid.CreatedAt,
+		})
+	}
+
+	return c.JSON(fiber.Map{"status": "success", "data": auction, "bids": history})
+}
+
+// CreateAuction puts one unit of a product of the seller's shop up for
+// auction. The product cannot be bought through the cart until the auction
+// ends without a winner or is cancelled.
+func CreateAuction(c *fiber.Ctx) error {
+	var body struct {
+		ProductID    uint       `json:"product_id"`
+		StartPrice   float64    `json:"start_price"`
+		ReservePrice *float64   `json:"reserve_price"`
+		MinIncrement float64    `json:"min_increment"`
+		StartsAt     *time.Time `json:"starts_at"`
+		EndsAt       time.Time  `json:"ends_at"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	product, status, err := ownedProduct(c, fmt.Sprint(body.ProductID))
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	if !product.IsPublished(time.Now()) || product.ArchivedAt != nil || product.Stock <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Only published products in stock can be auctioned"})
+	}
+
+	var variantCount int64
+	database.DB.Model(&models.ProductVariant{}).Where("product_id = ?", product.ID).Count(&variantCount)
+	if variantCount > 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Products with variants cannot be auctioned"})
+	}
+
+	now := time.Now()
+	auction := models.Auction{
+		ProductID:    product.ID,
+		ShopID:       product.ShopID,
+		StartPrice:   body.StartPrice,
+		ReservePrice: body.ReservePrice,
+		MinIncrement: body.MinIncrement,
+		CurrentPrice: body.StartPrice,
+		StartsAt:     now,
+		EndsAt:       body.EndsAt,
+		Status:       models.AuctionRunning,
+	}
+	if body.StartsAt != nil {
+		auction.StartsAt = *body.StartsAt
+	}
+
+	switch {
+	case auction.StartPrice <= 0:
+		return c.Status(400).JSON(fiber.Map{"error": "start_price must be greater than 0"})
+	case auction.MinIncrement <= 0:
+		return c.Status(400).JSON(fiber.Map{"error": "min_increment must be greater than 0"})
+	case auction.ReservePrice != nil && *auction.ReservePrice < auction.StartPrice:
+		return c.Status(400).JSON(fiber.Map{"error": "reserve_price cannot be below start_price"})
+	case !auction.EndsAt.After(now) || !auction.EndsAt.After(auction.StartsAt):
+		return c.Status(400).JSON(fiber.Map{"error": "ends_at must be in the future and after starts_at"})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		auctioned, err := productAuctioned(tx, product.ID)
+		if err != nil {
+			return err
+		}
+		if auctioned {
+			return &auctionError{status: 409, message: "This product is already being auctioned"}
+		}
+		if err := tx.Create(&auction).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Product{}).Where("id = ?", product.ID).
+			Update("listing_type", models.ListingAuction).Error
+	})
+	if err != nil {
+		var auctionErr *auctionError
+		if errors.As(err, &auctionErr) {
+			return c.Status(auctionErr.status).JSON(fiber.Map{"error": auctionErr.Error()})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create auction"})
+	}
+
+	recordAudit(c, "auction.create", "auction", auction.ID, nil, auction)
+
+	return c.Status(201).JSON(fiber.Map{"status": "success", "data": auction})
+}
+
+// CancelAuction stops an auction nobody has bid on yet and lists the product
+// at its fixed price again.
+func CancelAuction(c *fiber.Ctx) error {
+	shop, err := sellerShop(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var auction, before models.Auction
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&auction, c.Params("id")).Error; err != nil {
+			return &auctionError{status: 404, message: "Auction not found"}
+		}
+		before = auction
+		if auction.ShopID != shop.ID {
+			return &auctionError{status: fiber.StatusForbidden, message: "Kamu tidak memiliki izin untuk mengubah lelang ini"}
+		}
+		if auction.Status != models.AuctionRunning || auction.BidCount > 0 {
+			return &auctionError{status: 409, message: "Only running auctions without bids can be cancelled"}
+		}
+
+		now := time.Now()
+		auction.Status = models.AuctionCancelled
+		auction.ClosedAt = &now
+		if err := tx.Save(&auction).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Product{}).Where("id = ?", auction.ProductID).
+			Update("listing_type", models.ListingFixed).Error
+	})
+	if err != nil {
+		var auctionErr *auctionError
+		if errors.As(err, &auctionErr) {
+			return c.Status(auctionErr.status).JSON(fiber.Map{"error": auctionErr.Error()})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to cancel auction"})
+	}
+
+	recordAudit(c, "auction.cancel", "auction", auction.ID, before, auction)
+
+	return c.JSON(fiber.Map{"status": "success", "message": "Auction cancelled"})
+}
+
+// PlaceBid bids on a running auction. The auction row is locked for the
+// check and the update, so concurrent bids are applied one after the other
+// and each must beat the one before.
+func PlaceBid(c *fiber.Ctx) error {
+	var body struct {
+		Amount float64 `json:"amount"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	userID := sessionUserID(c)
+	var auction models.Auction
+	var outbid *uint
+	extended := false
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&auction, c.Params("id")).Error; err != nil {
+			return &auctionError{status: 404, message: "Auction not found"}
+		}
+
+		now := time.Now()
+		if !auction.Open(now) {
+			return &auctionError{status: 409, message: "This auction is not accepting bids"}
+		}
+
+		var shop models.Shop
+		if err := tx.First(&shop, auction.ShopID).Error; err != nil {
+			return err
+		}
+		if shop.UserID == userID {
+			return &auctionError{status: 400, message: "You cannot bid on your own auction"}
+		}
+		if auction.LeaderID != nil && *auction.LeaderID == userID {
+			return &auctionError{status: 409, message: "You are already the highest bidder"}
+		}
+		if minimum := auction.NextBid(); body.Amount < minimum {
+			return &auctionError{status: 400, message: fmt.Sprintf("Your bid must be at least %.0f", minimum)}
+		}
+
+		bid := models.Bid{AuctionID: auction.ID, UserID: userID, Amount: body.Amount}
+		if err := tx.Create(&bid).Error; err != nil {
+			return err
+		}
+
+		outbid = auction.LeaderID
+		auction.CurrentPrice = body.Amount
+		auction.BidCount++
+		auction.LeaderID = &userID
+		if auction.EndsAt.Sub(now) < auctionSnipeWindow {
+			auction.EndsAt = now.Add(auctionSnipeWindow)
+			extended = true
+		}
+		return tx.Omit(clause.Associations).Save(&auction).Error
+	})
+	if err != nil {
+		var auctionErr *auctionError
+		if errors.As(err, &auctionErr) {
+			return c.Status(auctionErr.status).JSON(fiber.Map{"error": auctionErr.Error()})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to place bid"})
+	}
+
+	if outbid != nil {
+		notifyAuction(*outbid, auction, "You have been outbid",
+			fmt.Sprintf("Someone bid %.0f in auction #%d", auction.CurrentPrice, auction.ID))
+	}
+	presentAuction(&auction, false)
+
+	return c.Status(201).JSON(fiber.Map{"status": "success", "data": auction, "extended": extended})
+}
+
+// closeAuctions ends the auctions whose time is up and creates the orders of
+// winners that do not have one yet, e.g. after a failed attempt.
+func closeAuctions() {
+	var ids []uint
+	if err := database.DB.Model(&models.Auction{}).
+		Where("status = ? AND ends_at <= ?", models.AuctionRunning, time.Now()).
+		Pluck("id", &ids).Error; err != nil {
+		fmt.Printf("⚠️ Failed to find ended auctions: %v\n", err)
+		return
+	}
+	for _, id := range ids {
+		if err := closeAuction(id); err != nil {
+			fmt.Printf("⚠️ Failed to close auction %d: %v\n", id, err)
+		}
+	}
+
+	var won []models.Auction
+	if err := database.DB.Where("status = ? AND order_id IS NULL", models.AuctionWon).Find(&won).Error; err != nil {
+		fmt.Printf("⚠️ Failed to find won auctions: %v\n", err)
+		return
+	}
+	for i := range won {
+		if err := placeAuctionOrder(&won[i]); err != nil {
+			fmt.Printf("⚠️ Failed to create the order of auction %d: %v\n", won[i].ID, err)
+		}
+	}
+}
+
+// closeAuction picks the winner, or lists the product at its fixed price
+// again when the reserve was not met. A bid placed just before may have
+// extended the auction, so the end time is checked again under the lock.
+func closeAuction(id uint) error {
+	var auction models.Auction
+	var before models.Auction
+	closed := false
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&auction, id).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		if auction.Status != models.AuctionRunning || now.Before(auction.EndsAt) {
+			return nil
+		}
+
+		before = auction
+		closed = true
+		auction.ClosedAt = &now
+		if auction.ReserveReached() {
+			auction.Status = models.AuctionWon
+			auction.WinnerID = auction.LeaderID
+			return tx.Save(&auction).Error
+		}
+
+		auction.Status = models.AuctionUnsold
+		if err := tx.Save(&auction).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Product{}).Where("id = ?", auction.ProductID).
+			Update("listing_type", models.ListingFixed).Error
+	})
+	if err != nil || !closed {
+		return err
+	}
+
+	recordSystemAudit("auction.close", "auction", auction.ID, before, auction)
+
+	var shop models.Shop
+	if err := database.DB.First(&shop, auction.ShopID).Error; err == nil {
+		message := fmt.Sprintf("Auction #%d ended without reaching the reserve price", auction.ID)
+		if auction.WinnerID != nil {
+			message = fmt.Sprintf("Auction #%d was won with a bid of %.0f", auction.ID, auction.CurrentPrice)
+		}
+		notifyAuction(shop.UserID, auction, "Auction ended", message)
+	}
+	return nil
+}
+
+// placeAuctionOrder checks the won product out for the winner like a cart
+// purchase: the item is put in the winner's cart at the winning bid and
+// ordered with the delivery details of the account. The winner then uploads
+// the payment proof to the order.
+func placeAuctionOrder(auction *models.Auction) error {
+	if auction.WinnerID == nil {
+		return nil
+	}
+	winnerID := *auction.WinnerID
+
+	var winner models.User
+	if err := database.DB.First(&winner, winnerID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return failAuction(auction, nil, "the account of the winner no longer exists")
+	} else if err != nil {
+		return err
+	}
+
+	var item models.CartItem
+	err := database.DB.Where("user_id = ? AND product_id = ? AND variant_id IS NULL", winnerID, auction.ProductID).
+		First(&item).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		item = models.CartItem{UserID: &winnerID, ProductID: auction.ProductID}
+	} else if err != nil {
+		return err
+	}
+	item.Quantity = 1
+	item.Price = auction.CurrentPrice
+	item.ReducedFrom = nil
+	if err := database.DB.Omit(clause.Associations).Save(&item).Error; err != nil {
+		return err
+	}
+
+	if err := database.DB.Preload("Product").Preload("Variant").First(&item, item.ID).Error; err != nil {
+		return err
+	}
+	checks, err := revalidateCart([]models.CartItem{item}, time.Now())
+	if err != nil {
+		return err
+	}
+	if warnings := cartWarnings(checks); len(warnings) > 0 {
+		return failAuction(auction, &item, warnings[0].Message)
+	}
+
+	order := models.Order{
+		UserID:         winnerID,
+		ShopID:         auction.ShopID,
+		Recipient:      winner.Username,
+		Telephone:      winner.Telephone,
+		Address:        winner.Address,
+		Note:           fmt.Sprintf("Auction #%d", auction.ID),
+		StatusShipping: "awaitingPayment",
+	}
+	if err := placeOrder(&order, checks, ""); err != nil {
+		return err
+	}
+
+	recordSystemAudit("order.create", "order", order.ID, nil, order)
+	notifyAuction(winnerID, *auction, "You won the auction",
+		fmt.Sprintf("Order #%d was created for your winning bid of %.0f, please upload the payment proof", order.ID, auction.CurrentPrice))
+	return nil
+}
+
+// failAuction ends a won auction whose item can no longer be ordered, so it
+// is not retried forever. The item put in the cart of the winner is removed
+// again, the product is sold at its fixed price and both sides are told.
+func failAuction(auction *models.Auction, item *models.CartItem, reason string) error {
+	before := *auction
+	failed := false
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Auction{}).
+			Where("id = ? AND status = ? AND order_id IS NULL", auction.ID, models.AuctionWon).
+			Update("status", models.AuctionFailed)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		failed = true
+		if item != nil {
+			if err := tx.Delete(&models.CartItem{}, item.ID).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.Product{}).Where("id = ?", auction.ProductID).
+			Update("listing_type", models.ListingFixed).Error
+	})
+	if err != nil || !failed {
+		return err
+	}
+	auction.Status = models.AuctionFailed
+
+	recordSystemAudit("auction.fail", "auction", auction.ID, before, auction)
+
+	message := fmt.Sprintf("Auction #%d could not be ordered: %s", auction.ID, reason)
+	if auction.WinnerID != nil {
+		notifyAuction(*auction.WinnerID, *auction, "Auction order failed", message)
+	}
+	var shop models.Shop
+	if err := database.DB.First(&shop, auction.ShopID).Error; err == nil {
+		notifyAuction(shop.UserID, *auction, "Auction order failed", message)
+	}
+	return nil
+}
+
+// productAuctioned reports whether a product has a running auction or a won
+// one that waits for its order. Such products cannot be deleted, archived or
+// have their stock edited.
+func productAuctioned(db *gorm.DB, productID uint) (bool, error) {
+	var count int64
+	err := db.Model(&models.Auction{}).
+		Where("product_id = ? AND status IN ?", productID, []string{models.AuctionRunning, models.AuctionWon}).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// wonAuctions returns the auctions a buyer won that still wait for their
+// order, by product.
+func wonAuctions(db *gorm.DB, userID uint, productIDs []uint) (map[uint]*models.Auction, error) {
+	won := map[uint]*models.Auction{}
+	if userID == 0 || len(productIDs) == 0 {
+		return won, nil
+	}
+
+	var rows []models.Auction
+	if err := db.Where("winner_id = ? AND product_id IN ? AND status = ? AND order_id IS NULL",
+		userID, productIDs, models.AuctionWon).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		won[rows[i].ProductID] = &rows[i]
+	}
+	return won, nil
+}
+
+// withAuction prices a won product at the winning bid.
+func withAuction(quote models.PriceQuote, auction *models.Auction) models.PriceQuote {
+	if auction == nil {
+		return quote
+	}
+	return models.PriceQuote{
+		OriginalPrice: auction.CurrentPrice,
+		Price:         auction.CurrentPrice,
+		AuctionID:     &auction.ID,
+	}
+}
+
+// claimAuction marks a won auction as sold with its order. Remaining stock of
+// the product is sold at the fixed price again.
+func claimAuction(tx *gorm.DB, auctionID, orderID uint) error {
+	var auction models.Auction
+	if err := tx.First(&auction, auctionID).Error; err != nil {
+		return err
+	}
+
+	result := tx.Model(&models.Auction{}).
+		Where("id = ? AND status = ? AND order_id IS NULL", auctionID, models.AuctionWon).
+		Updates(map[string]interface{}{"status": models.AuctionSold, "order_id": orderID})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("auction %d was already ordered", auctionID)
+	}
+	return tx.Model(&models.Product{}).Where("id = ?", auction.ProductID).
+		Update("listing_type", models.ListingFixed).Error
+}
+
+// presentAuction fills in the bidder view of an auction. Only the seller
+// sees the reserve price.
+func presentAuction(auction *models.Auction, owner bool) {
+	auction.MinimumBid = auction.NextBid()
+	auction.ReserveMet = auction.ReserveReached()
+	if !owner {
+		auction.ReservePrice = nil
+	}
+}
+
+func notifyAuction(userID uint, auction models.Auction, title, message string) {
+	notification := models.Notification{
+		UserID:    userID,
+		Type:      models.NotificationAuction,
+		Title:     title,
+		Message:   message,
+		ProductID: &auction.ProductID,
+	}
+	if err := database.DB.Create(&notification).Error; err != nil {
+		fmt.Printf("⚠️ Failed to notify user %d about auction %d: %v\n", userID, auction.ID, err)
+	}
+}
+
+// maskName hides most of a username, e.g. "budi" becomes "b**i".
+func maskName(name string) string {
+	runes := []rune(name)
+	if len(runes) <= 2 {
+		return "**"
+	}
+	masked := make([]rune, len(runes))
+	for i := range runes {
+		masked[i] = '*'
+	}
+	masked[0], masked[len(runes)-1] = runes[0], runes[len(runes)-1]
+	return string(masked)
+}
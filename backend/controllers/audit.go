@@ -0,0 +1,283 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"finpro/database"
+	"finpro/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+)
+
+// recordAudit writes an audit entry for the logged-in user of the request.
+// Pass nil as before for creations and nil as after for deletions.
+func recordAudit(c *fiber.Ctx, action string, entityType string, entityID interface{}, before interface{}, after interface{}) {
+	var actorID *int
+	var actorRole string
+
+	if userToken, ok := c.Locals("user").(*jwt.Token); ok {
+		claims := userToken.Claims.(jwt.MapClaims)
+		if id, ok := claims["id"].(float64); ok {
+			actor := int(id)
+			actorID = &actor
+		}
+		actorRole, _ = claims["role"].(string)
+	}
+
+	writeAudit(c, actorID, actorRole, action, entityType, entityID, before, after)
+}
+
+// recordAuditAs is recordAudit for requests without a session yet, such as
+// registration and the login steps.
+func recordAuditAs(c *fiber.Ctx, actor *models.User, action string, entityType string, entityID interface{}, before interface{}, after interface{}) {
+	writeAudit(c, &actor.ID, actor.Role, action, entityType, entityID, before, after)
+}
+
+func writeAudit(c *fiber.Ctx, actorID *int, actorRole string, action string, entityType string, entityID interface{}, before interface{}, after interface{}) {
+	beforeJSON, afterJSON := auditDiff(before, after)
+
+	userAgent := c.Get(fiber.HeaderUserAgent)
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	entry := models.AuditLog{
+		ActorID:    actorID,
+		ActorRole:  actorRole,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   fmt.Sprint(entityID),
+		Before:     beforeJSON,
+		After:      afterJSON,
+		IP:         c.IP(),
+		UserAgent:  userAgent,
+	}
+
+	if err := database.DB.Create(&entry).Error; err != nil {
+		fmt.Printf("⚠️ Failed to write audit log %s: %v\n", action, err)
+	}
+}
+
+// recordSystemAudit records a change made by a background job rather than a
+// request.
+func recordSystemAudit(action string, entityType string, entityID interface{}, before interface{}, after interface{}) {
+	beforeJSON, afterJSON := auditDiff(before, after)
+
+	entry := models.AuditLog{
+		ActorRole:  "system",
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   fmt.Sprint(entityID),
+		Before:     beforeJSON,
+		After:      afterJSON,
+	}
+
+	if err := database.DB.Create(&entry).Error; err != nil {
+		fmt.Printf("⚠️ Failed to write audit log %s: %v\n", action, err)
+	}
+}
+
+// auditDiff reduces before and after to the top-level fields that differ.
+// Fields hidden from JSON (passwords, 2FA secrets) never reach the log.
+func auditDiff(before interface{}, after interface{}) (json.RawMessage, json.RawMessage) {
+	beforeMap := auditFields(before)
+	afterMap := auditFields(after)
+
+	if beforeMap == nil || afterMap == nil {
+		return auditJSON(beforeMap), auditJSON(afterMap)
+	}
+
+	changedBefore := map[string]interface{}{}
+	changedAfter := map[string]interface{}{}
+	for key, value := range afterMap {
+		if !reflect.DeepEqual(beforeMap[key], value) {
+			changedBefore[key] = beforeMap[key]
+			changedAfter[key] = value
+		}
+	}
+	for key, value := range beforeMap {
+		if _, ok := afterMap[key]; !ok {
+			changedBefore[key] = value
+		}
+	}
+
+	return auditJSON(changedBefore), auditJSON(changedAfter)
+}
+
+func auditFields(value interface{}) map[string]interface{} {
+	if value == nil || (reflect.ValueOf(value).Kind() == reflect.Ptr && reflect.ValueOf(value).IsNil()) {
+		return nil
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return map[string]interface{}{"value": value}
+	}
+
+	// Associations that were not preloaded marshal as empty objects.
+	for key, field := range fields {
+		if nested, ok := field.(map[string]interface{}); ok && nested["id"] == float64(0) {
+			delete(fields, key)
+		}
+	}
+	return fields
+}
+
+func auditJSON(fields map[string]interface{}) json.RawMessage {
+	if fields == nil {
+		return nil
+	}
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		return nil
+	}
+	return raw
+}
+
+func GetAuditLogs(c *fiber.Ctx) error {
+	query, err := auditLogQuery(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	var total int64
+	if err := query.Model(&models.AuditLog{}).Count(&total).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to count audit logs"})
+	}
+
+	var logs []models.AuditLog
+	if err := query.Order("created_at DESC, id DESC").Offset((page - 1) * limit).Limit(limit).Find(&logs).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch audit logs"})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   logs,
+		"pagination": fiber.Map{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+func ExportAuditLogs(c *fiber.Ctx) error {
+	query, err := auditLogQuery(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/csv")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="audit-log-`+time.Now().Format("20060102-150405")+`.csv"`)
+
+	writer := csv.NewWriter(c)
+	writer.Write([]string{"id", "created_at", "actor_id", "actor_role", "action", "entity_type", "entity_id", "before", "after", "ip", "user_agent"})
+
+	var batch []models.AuditLog
+	result := query.Order("created_at ASC, id ASC").FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+		for _, log := range batch {
+			actorID := ""
+			if log.ActorID != nil {
+				actorID = strconv.Itoa(*log.ActorID)
+			}
+			writer.Write([]string{
+				strconv.FormatUint(uint64(log.ID), 10),
+				log.CreatedAt.Format(time.RFC3339),
+				actorID,
+				spreadsheetCell(log.ActorRole),
+				spreadsheetCell(log.Action),
+				spreadsheetCell(log.EntityType),
+				spreadsheetCell(log.EntityID),
+				spreadsheetCell(string(log.Before)),
+				spreadsheetCell(string(log.After)),
+				spreadsheetCell(log.IP),
+				spreadsheetCell(log.UserAgent),
+			})
+		}
+		writer.Flush()
+		return writer.Error()
+	})
+	if result.Error != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to export audit logs"})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// auditLogQuery applies the shared filters of the query and export endpoints:
+// actor_id, action, entity_type, entity_id, from and to (YYYY-MM-DD or RFC 3339).
+func auditLogQuery(c *fiber.Ctx) (*gorm.DB, error) {
+	query := database.DB.Model(&models.AuditLog{})
+
+	if actorID := c.Query("actor_id"); actorID != "" {
+		id, err := strconv.Atoi(actorID)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid actor_id")
+		}
+		query = query.Where("actor_id = ?", id)
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if entityType := c.Query("entity_type"); entityType != "" {
+		query = query.Where("entity_type = ?", entityType)
+	}
+	if entityID := c.Query("entity_id"); entityID != "" {
+		query = query.Where("entity_id = ?", entityID)
+	}
+	if from := c.Query("from"); from != "" {
+		t, err := parseAuditDate(from, false)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid from date")
+		}
+		query = query.Where("created_at >= ?", t)
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := parseAuditDate(to, true)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid to date")
+		}
+		query = query.Where("created_at <= ?", t)
+	}
+
+	return query.Session(&gorm.Session{}), nil
+}
+
+// parseAuditDate accepts a plain date, which covers the whole day when used
+// as the end of a range.
+func parseAuditDate(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
+}
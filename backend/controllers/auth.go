@@ -3,6 +3,7 @@ package controllers
 import (
 	"finpro/database"
 	"finpro/models"
+	"fmt"
 	"os"
 	"time"
 
@@ -58,10 +59,18 @@ func SignUp(c *fiber.Ctx) error {
 
 	user.Password = ""
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+	recordAuditAs(c, &user, "user.register", "user", user.ID, nil, user)
+
+	response := fiber.Map{
 		"message": "Register success",
 		"user":    user,
-	})
+	}
+	// The cart built before registering is kept for the new account.
+	if merged := mergeGuestCart(c, &user); merged != nil {
+		response["cart_merge"] = merged
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(response)
 }
 
 func Login(c *fiber.Ctx) error{
@@ -88,17 +97,145 @@ func Login(c *fiber.Ctx) error{
 		return c.Status(401).JSON(fiber.Map{"Message": "Email or Password not validaa"})
 	}
 
+	if existingUser.IsBlocked(time.Now()) {
+		return accountBlockedResponse(c, &existingUser)
+	}
+
+	if existingUser.TwoFactorEnabled {
+		mfaToken, err := issuePendingToken(existingUser.ID, "2fa")
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Could not login"})
+		}
+
+		return c.JSON(fiber.Map{
+			"message":             "Two-factor authentication code required",
+			"two_factor_required": true,
+			"mfa_token":           mfaToken,
+		})
+	}
+
+	if existingUser.Role == "admin" && adminTwoFactorRequired() {
+		mfaToken, err := issuePendingToken(existingUser.ID, "2fa_setup")
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Could not login"})
+		}
+
+		return c.JSON(fiber.Map{
+			"message":                   "Two-factor authentication must be set up before login",
+			"two_factor_setup_required": true,
+			"mfa_token":                 mfaToken,
+		})
+	}
+
+	return completeLogin(c, &existingUser, nil)
+}
+
+// ResetPasswordLogin sets a new password for an account an admin flagged for
+// a forced reset, then finishes the login.
+func ResetPasswordLogin(c *fiber.Ctx) error {
+	input := new(models.ResetPasswordInput)
+	if err := c.BodyParser(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	if len(input.NewPassword) < 6 {
+		return c.Status(400).JSON(fiber.Map{"error": "New password must be at least 6 characters"})
+	}
+
+	userID, err := parsePendingToken(input.ResetToken, "password_reset")
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Login session expired, please login again"})
+	}
+
+	var user models.User
+	if err := database.DB.Preload("Shop").First(&user, userID).Error; err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Login session expired, please login again"})
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.NewPassword)) == nil {
+		return c.Status(400).JSON(fiber.Map{"error": "New password must be different from the old one"})
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to hash password"})
+	}
+
+	before := user
+	if err := database.DB.Model(&user).Updates(map[string]interface{}{
+		"password":            string(hashedPassword),
+		"must_reset_password": false,
+	}).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update password"})
+	}
+
+	recordAuditAs(c, &user, "user.password_reset", "user", user.ID, before, user)
+
+	return completeLogin(c, &user, nil)
+}
+
+// completeLogin runs after every credential check passed. It still holds the
+// session back while an admin-forced password reset is pending.
+func completeLogin(c *fiber.Ctx, user *models.User, extra fiber.Map) error {
+	if user.MustResetPassword {
+		resetToken, err := issuePendingToken(user.ID, "password_reset")
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Could not login"})
+		}
+
+		response := fiber.Map{
+			"message":                 "Password reset required",
+			"password_reset_required": true,
+			"reset_token":             resetToken,
+		}
+		for key, value := range extra {
+			response[key] = value
+		}
+		return c.JSON(response)
+	}
+
+	if err := issueSession(c, user); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Could not login"})
+	}
+
+	response := fiber.Map{
+		"message": "Login success",
+		"user":    user,
+	}
+	for key, value := range extra {
+		response[key] = value
+	}
+	if merged := mergeGuestCart(c, user); merged != nil {
+		response["cart_merge"] = merged
+	}
+	return c.JSON(response)
+}
+
+func accountBlockedResponse(c *fiber.Ctx, user *models.User) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"error":  "Account is " + user.Status,
+		"status": user.Status,
+		"reason": user.StatusReason,
+		"until":  user.StatusUntil,
+	})
+}
+
+// issueSession signs the session JWT and stores it in the token cookie. It is
+// the only place a full session is handed out, after every login step passed.
+func issueSession(c *fiber.Ctx, user *models.User) error {
 	claims := jwt.MapClaims{
-		"id":   existingUser.ID,
-		"username": existingUser.Username,
-		"role": existingUser.Role,
+		"id":   user.ID,
+		"username": user.Username,
+		"role": user.Role,
 		"exp":  time.Now().Add(time.Hour * 24).Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	signed, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Could not login"})
+		return err
 	}
 
 	c.Cookie(&fiber.Cookie{
@@ -110,12 +247,45 @@ func Login(c *fiber.Ctx) error{
 		SameSite: "Lax",
 	})
 
-	return c.JSON(fiber.Map{
-		"message": "Login success",
-		"user": existingUser,
-	})
+	return nil
+}
+
+// issuePendingToken signs a short-lived token for a login that still has a
+// step left. The purpose claim keeps it from being accepted as a session.
+func issuePendingToken(userID int, purpose string) (string, error) {
+	claims := jwt.MapClaims{
+		"id":      userID,
+		"purpose": purpose,
+		"exp":     time.Now().Add(time.Minute * 5).Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
 }
 
+func parsePendingToken(tokenString string, purpose string) (int, error) {
+	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method")
+		}
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	})
+	if err != nil || !token.Valid {
+		return 0, fmt.Errorf("invalid or expired token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != purpose {
+		return 0, fmt.Errorf("invalid or expired token")
+	}
+
+	id, ok := claims["id"].(float64)
+	if !ok {
+		return 0, fmt.Errorf("invalid or expired token")
+	}
+
+	return int(id), nil
+}
 
 func Logout(c *fiber.Ctx) error {
 	c.Cookie(&fiber.Cookie{
@@ -1,17 +1,20 @@
 package controllers
 
 import (
+	"fmt"
+	"time"
+
 	"finpro/database"
 	"finpro/models"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v4"
 	"gorm.io/gorm"
 )
 
 func AddToCart(c *fiber.Ctx) error {
 	type Request struct {
-		ProductID uint `json:"product_id"`
+		ProductID uint  `json:"product_id"`
+		VariantID *uint `json:"variant_id"`
 	}
 
 	var body Request
@@ -19,66 +22,149 @@ func AddToCart(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	userTokenRaw := c.Locals("user")
-	if userTokenRaw == nil {
-		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized - missing token"})
+	owner, err := currentCartOwner(c, false)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database error"})
 	}
 
-	userToken, ok := userTokenRaw.(*jwt.Token)
-	if !ok {
-		return c.Status(401).JSON(fiber.Map{"error": "Invalid token"})
+	quote, created, status, err := addToCart(c, owner, body.ProductID, body.VariantID, 1)
+	if err != nil {
+		response := fiber.Map{"error": err.Error()}
+		if status == fiber.StatusConflict {
+			response["availability"] = availabilityReserved
+		}
+		return c.Status(status).JSON(response)
 	}
 
-	claims := userToken.Claims.(jwt.MapClaims)
-	userID := uint(claims["id"].(float64))
+	if !created {
+		return c.JSON(fiber.Map{"message": "Cart updated successfully", "data": quote})
+	}
+	return c.Status(201).JSON(fiber.Map{"message": "Product added to cart", "data": quote})
+}
 
+// addToCart puts quantity pieces of a product into the owner's cart, adding
+// to an item that is already there. Every way into the cart goes through it
+// so the same checks apply. created is false when an item was topped up.
+func addToCart(c *fiber.Ctx, owner cartOwner, productID uint, variantID *uint, quantity int) (quote models.PriceQuote, created bool, status int, err error) {
 	var product models.Product
-	if err := database.DB.First(&product, body.ProductID).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{"error": "Product not found"})
+	if err := database.DB.Scopes(visibleProducts).First(&product, productID).Error; err != nil {
+		return quote, false, 404, fmt.Errorf("Product not found")
+	}
+
+	if product.ListingType == models.ListingAuction {
+		return quote, false, 400, fmt.Errorf("This product is sold by auction, place a bid instead")
 	}
 
 	var shop models.Shop
-	if err := database.DB.First(&shop, product.ShopID).Error; err == nil {
-		if shop.UserID == userID {
-			return c.Status(400).JSON(fiber.Map{"error": "You cannot add your own product to cart"})
+	if err := database.DB.First(&shop, product.ShopID).Error; err == nil && owner.UserID != 0 {
+		if shop.UserID == owner.UserID {
+			return quote, false, 400, fmt.Errorf("You cannot add your own product to cart")
+		}
+	}
+
+	var variant *models.ProductVariant
+	var variantCount int64
+	database.DB.Model(&models.ProductVariant{}).Where("product_id = ?", product.ID).Count(&variantCount)
+	if variantCount > 0 && variantID == nil {
+		return quote, false, 400, fmt.Errorf("Please choose a variant of this product")
+	}
+	if variantID != nil {
+		variant = &models.ProductVariant{}
+		if err := database.DB.Where("product_id = ?", product.ID).First(variant, *variantID).Error; err != nil {
+			return quote, false, 404, fmt.Errorf("Variant not found")
 		}
 	}
+
+	book, err := loadPriceBook([]models.Product{product}, time.Now())
+	if err != nil {
+		return quote, false, 500, fmt.Errorf("Failed to fetch discounts")
+	}
+	quote = book.quote(&product, variant)
+
+	offers, err := usableOffers(database.DB, owner.UserID, []uint{product.ID}, time.Now())
+	if err != nil {
+		return quote, false, 500, fmt.Errorf("Database error")
+	}
+	offer := offers[cartStockKey(product.ID, variantID)]
+
+	stock := product.Stock
+	if variant != nil {
+		stock = variant.Stock
+	}
+	if stock <= 0 {
+		return quote, false, 400, fmt.Errorf("This product is out of stock")
+	}
+
+	reserved, err := reservedStock(database.DB, []uint{product.ID}, owner.UserID, time.Now())
+	if err != nil {
+		return quote, false, 500, fmt.Errorf("Database error")
+	}
+	available := stock - reserved[cartStockKey(product.ID, variantID)]
+	if available <= 0 {
+		return quote, false, fiber.StatusConflict, fmt.Errorf("This item is reserved by another buyer, please try again later")
+	}
+
+	existing := database.DB.Scopes(owner.scope).Where("product_id = ?", productID)
+	if variantID != nil {
+		existing = existing.Where("variant_id = ?", *variantID)
+	} else {
+		existing = existing.Where("variant_id IS NULL")
+	}
+
 	var existingItem models.CartItem
-	err := database.DB.Where("user_id = ? AND product_id = ?", userID, body.ProductID).First(&existingItem).Error
+	err = gorm.ErrRecordNotFound
+	if !owner.empty() {
+		err = existing.First(&existingItem).Error
+	}
 	if err == nil {
-		existingItem.Quantity += 1
+		if existingItem.Quantity+quantity > available {
+			return quote, false, 400, fmt.Errorf("Only %d left in stock", available)
+		}
+		before := existingItem
+		existingItem.Quantity += quantity
+		quote = withOffer(quote, offer, existingItem.Quantity)
+		existingItem.Price = quote.Price
 		if err := database.DB.Save(&existingItem).Error; err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to update cart item"})
+			return quote, false, 500, fmt.Errorf("Failed to update cart item")
 		}
-		return c.JSON(fiber.Map{"message": "Cart updated successfully"})
+		recordAudit(c, "cart.update", "cart_item", existingItem.ID, before, existingItem)
+		return quote, false, 0, nil
 	}
 
 	if err != gorm.ErrRecordNotFound {
-		return c.Status(500).JSON(fiber.Map{"error": "Database error"})
+		return quote, false, 500, fmt.Errorf("Database error")
+	}
+	if quantity > available {
+		return quote, false, 400, fmt.Errorf("Only %d left in stock", available)
 	}
 
-	newCart := models.CartItem{
-		UserID:    userID,
-		ProductID: body.ProductID,
-		Quantity:  1,
-		Price:     product.Price,
+	// Visitors get a guest cart with their first item.
+	if owner.empty() {
+		if owner, err = currentCartOwner(c, true); err != nil {
+			return quote, false, 500, fmt.Errorf("Failed to add to cart")
+		}
 	}
 
+	newCart := owner.newItem()
+	newCart.ProductID = productID
+	newCart.VariantID = variantID
+	newCart.Quantity = quantity
+	quote = withOffer(quote, offer, quantity)
+	newCart.Price = quote.Price
+
 	if err := database.DB.Create(&newCart).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to add to cart"})
+		return quote, false, 500, fmt.Errorf("Failed to add to cart")
 	}
 
-	return c.Status(201).JSON(fiber.Map{"message": "Product added to cart"})
+	recordAudit(c, "cart.add", "cart_item", newCart.ID, nil, newCart)
+
+	return quote, true, 0, nil
 }
 
 
 func GetAllCart(c *fiber.Ctx) error {
-	userToken := c.Locals("user").(*jwt.Token)
-	claims := userToken.Claims.(jwt.MapClaims)
-	userID := int(claims["id"].(float64))
-
-	var cartItems []models.CartItem
-	if err := database.DB.Preload("Product.Shop").Where("user_id = ?", userID).Find(&cartItems).Error; err != nil {
+	owner, err := currentCartOwner(c, false)
+	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Failed to fetch cart items",
@@ -86,6 +172,22 @@ func GetAllCart(c *fiber.Ctx) error {
 		})
 	}
 
+	// Removed products are loaded too, so the cart can say what is gone.
+	cartItems := []models.CartItem{}
+	if !owner.empty() {
+		if err := database.DB.
+			Preload("Product", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+			Preload("Product.Shop").
+			Preload("Variant").
+			Scopes(owner.scope).Find(&cartItems).Error; err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Failed to fetch cart items",
+				"error":   err.Error(),
+			})
+		}
+	}
+
 	if len(cartItems) == 0 {
 		return c.Status(200).JSON(fiber.Map{
 			"status":  "success",
@@ -94,9 +196,19 @@ func GetAllCart(c *fiber.Ctx) error {
 		})
 	}
 
+	checks, err := revalidateCart(cartItems, time.Now())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to check cart items",
+			"error":   err.Error(),
+		})
+	}
+
 	shopMap := make(map[uint]fiber.Map)
 
-	for _, item := range cartItems {
+	for _, check := range checks {
+		item := check.Item
 		shop := item.Product.Shop
 
 		if _, exists := shopMap[shop.ID]; !exists {
@@ -107,16 +219,32 @@ func GetAllCart(c *fiber.Ctx) error {
 			}
 		}
 
+		variantLabel := ""
+		if item.Variant != nil {
+			variantLabel = item.Variant.Label()
+		}
+
+		warnings := check.Warnings
+		if warnings == nil {
+			warnings = []cartWarning{}
+		}
+
 		cartArray := shopMap[shop.ID]["cart_items"].([]fiber.Map)
 		cartArray = append(cartArray, fiber.Map{
 			"id":         item.ID,
 			"product_id": item.ProductID,
+			"variant_id": item.VariantID,
+			"variant":    variantLabel,
 			"image":      item.Product.Image,
 			"name":       item.Product.Name,
 			"label":      item.Product.Label,
-			"price":      item.Price,
+			"price":      check.Quote.Price,
+			"original_price": check.Quote.OriginalPrice,
+			"discount":   check.Quote.Discount,
 			"quantity":   item.Quantity,
-			"product_stock": item.Product.Stock, 
+			"product_stock": check.Stock, 
+			"available":  check.Available,
+			"warnings":   warnings,
 		})
 		shopMap[shop.ID]["cart_items"] = cartArray
 	}
@@ -126,9 +254,84 @@ func GetAllCart(c *fiber.Ctx) error {
 		result = append(result, val)
 	}
 
+	warnings := cartWarnings(checks)
 	return c.Status(200).JSON(fiber.Map{
 		"status": "success",
 		"data":   result,
+		// Checkout is refused while there are warnings, see AcknowledgeCart.
+		"warnings":                 warnings,
+		"requires_acknowledgement": len(warnings) > 0,
+	})
+}
+
+// AcknowledgeCart accepts the changes reported by GetAllCart: items take the
+// current price, reduced quantities are confirmed and items whose product
+// is gone are removed. Out of stock items stay until the buyer removes them
+// or the seller restocks. Without cart_ids the whole cart is acknowledged.
+func AcknowledgeCart(c *fiber.Ctx) error {
+	type Request struct {
+		CartIDs []uint `json:"cart_ids"`
+	}
+
+	var body Request
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	owner, err := currentCartOwner(c, false)
+	if err != nil || owner.empty() {
+		return c.Status(404).JSON(fiber.Map{"error": "Your cart is empty"})
+	}
+
+	query := database.DB.
+		Preload("Product", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Preload("Variant").
+		Scopes(owner.scope)
+	if len(body.CartIDs) > 0 {
+		query = query.Where("id IN ?", body.CartIDs)
+	}
+
+	var cartItems []models.CartItem
+	if err := query.Find(&cartItems).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch cart items"})
+	}
+
+	checks, err := revalidateCart(cartItems, time.Now())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to check cart items"})
+	}
+
+	acknowledged := 0
+	for _, check := range checks {
+		if len(check.Warnings) == 0 {
+			continue
+		}
+		acknowledged++
+		item := check.Item
+
+		if check.Warnings[0].Code == cartProductRemoved {
+			if err := database.DB.Delete(&item).Error; err != nil {
+				return c.Status(500).JSON(fiber.Map{"error": "Failed to update cart"})
+			}
+			_ = releaseReservations(database.DB, []uint{item.ID})
+			recordAudit(c, "cart.remove", "cart_item", item.ID, item, nil)
+			continue
+		}
+
+		before := item
+		item.Price = check.Quote.Price
+		item.ReducedFrom = nil
+		if err := database.DB.Model(&models.CartItem{}).Where("id = ?", item.ID).
+			Updates(map[string]interface{}{"price": item.Price, "reduced_from": nil}).Error; err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to update cart"})
+		}
+		recordAudit(c, "cart.acknowledge", "cart_item", item.ID, before, item)
+	}
+
+	return c.JSON(fiber.Map{
+		"status":       "success",
+		"message":      "Cart changes acknowledged",
+		"acknowledged": acknowledged,
 	})
 }
 
@@ -144,35 +347,81 @@ func UpdateCartQuantity(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
+	owner, err := currentCartOwner(c, false)
+	if err != nil || owner.empty() {
+		return c.Status(404).JSON(fiber.Map{"error": "Cart item not found"})
+	}
+
 	var cartItem models.CartItem
-	if err := database.DB.First(&cartItem, cartID).Error; err != nil {
+	if err := database.DB.Preload("Product").Preload("Variant").Scopes(owner.scope).First(&cartItem, cartID).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "Cart item not found"})
 	}
 
 	if body.Quantity <= 0 {
 		database.DB.Delete(&cartItem)
+		_ = releaseReservations(database.DB, []uint{cartItem.ID})
+		recordAudit(c, "cart.remove", "cart_item", cartItem.ID, cartItem, nil)
 		return c.JSON(fiber.Map{"message": "Cart item deleted because quantity was 0"})
 	}
 
+	stock := cartItem.Product.Stock
+	if cartItem.Variant != nil {
+		stock = cartItem.Variant.Stock
+	}
+	if body.Quantity > stock {
+		return c.Status(400).JSON(fiber.Map{
+			"error":         fmt.Sprintf("Only %d left in stock", stock),
+			"product_stock": stock,
+		})
+	}
+
+	reserved, err := reservedStock(database.DB, []uint{cartItem.ProductID}, owner.UserID, time.Now())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update cart item"})
+	}
+	if body.Quantity > stock-reserved[cartStockKey(cartItem.ProductID, cartItem.VariantID)] {
+		return c.Status(409).JSON(fiber.Map{
+			"error":        "Part of the stock is reserved by another buyer, please try again later",
+			"availability": availabilityReserved,
+		})
+	}
+
+	before := cartItem
 	cartItem.Quantity = body.Quantity
-	if err := database.DB.Save(&cartItem).Error; err != nil {
+	// Choosing a quantity confirms an earlier reduction.
+	cartItem.ReducedFrom = nil
+	if err := database.DB.Model(&models.CartItem{}).Where("id = ?", cartItem.ID).
+		Updates(map[string]interface{}{"quantity": cartItem.Quantity, "reduced_from": nil}).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to update cart item"})
 	}
 
+	// The hold was for the old quantity, checkout has to be started again.
+	_ = releaseReservations(database.DB, []uint{cartItem.ID})
+
+	recordAudit(c, "cart.update", "cart_item", cartItem.ID, before, cartItem)
+
 	return c.JSON(fiber.Map{"message": "Cart updated successfully"})
 }
 
 func DeleteCartItem(c *fiber.Ctx) error {
 	cartID := c.Params("cart_id")
 
+	owner, err := currentCartOwner(c, false)
+	if err != nil || owner.empty() {
+		return c.Status(404).JSON(fiber.Map{"error": "Cart item not found"})
+	}
+
 	var cartItem models.CartItem
-	if err := database.DB.First(&cartItem, cartID).Error; err != nil {
+	if err := database.DB.Scopes(owner.scope).First(&cartItem, cartID).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "Cart item not found"})
 	}
 
 	if err := database.DB.Delete(&cartItem).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete cart item"})
 	}
+	_ = releaseReservations(database.DB, []uint{cartItem.ID})
+
+	recordAudit(c, "cart.remove", "cart_item", cartItem.ID, cartItem, nil)
 
 	return c.JSON(fiber.Map{"message": "Cart item deleted successfully"})
 }
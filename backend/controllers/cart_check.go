@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	"finpro/database"
+	"finpro/models"
+)
+
+const (
+	cartPriceChanged    = "price_changed"
+	cartOutOfStock      = "out_of_stock"
+	cartProductRemoved  = "product_removed"
+	cartQuantityReduced = "quantity_reduced"
+	cartReserved        = "reserved"
+)
+
+// cartWarning tells the buyer how a cart item changed since it was added or
+// last acknowledged.
+type cartWarning struct {
+	CartID      uint     `json:"cart_id"`
+	Code        string   `json:"code"`
+	Message     string   `json:"message"`
+	OldPrice    *float64 `json:"old_price,omitempty"`
+	NewPrice    *float64 `json:"new_price,omitempty"`
+	OldQuantity *int     `json:"old_quantity,omitempty"`
+	NewQuantity *int     `json:"new_quantity,omitempty"`
+}
+
+// cartCheck is a cart item compared with the current product.
+type cartCheck struct {
+	Item      models.CartItem
+	Quote     models.PriceQuote
+	Stock     int
+	Available bool
+	Warnings  []cartWarning
+}
+
+// revalidateCart compares cart items, loaded with Product and Variant, with
+// the current products: price after discounts and accepted offers, stock, holds of other buyers
+// and whether the product can still be bought. Quantities above the stock are lowered and saved
+// right away; the warning stays until the buyer acknowledges it.
+func revalidateCart(items []models.CartItem, now time.Time) ([]cartCheck, error) {
+	products := make([]models.Product, 0, len(items))
+	productIDs := make([]uint, 0, len(items))
+	for _, item := range items {
+		products = append(products, item.Product)
+		productIDs = append(productIDs, item.ProductID)
+	}
+
+	book, err := loadPriceBook(products, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var hidden []uint
+	if err := hiddenShopIDs().Pluck("shops.id", &hidden).Error; err != nil {
+		return nil, err
+	}
+
+	var userID uint
+	if len(items) > 0 && items[0].UserID != nil {
+		userID = *items[0].UserID
+	}
+	reserved, err := reservedStock(database.DB, productIDs, userID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	offers, err := usableOffers(database.DB, userID, productIDs, now)
+	if err != nil {
+		return nil, err
+	}
+	won, err := wonAuctions(database.DB, userID, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := heldFlashClaims(database.DB, userID, productIDs, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var withVariants []uint
+	if err := database.DB.Model(&models.ProductVariant{}).
+		Where("product_id IN ?", productIDs).
+		Distinct().Pluck("product_id", &withVariants).Error; err != nil {
+		return nil, err
+	}
+
+	checks := make([]cartCheck, 0, len(items))
+	for _, item := range items {
+		check := cartCheck{Item: item}
+		product := &item.Product
+
+		removed := product.ID == 0 || product.DeletedAt.Valid || product.ArchivedAt != nil ||
+			containsUint(hidden, product.ShopID) ||
+			(item.VariantID != nil && item.Variant == nil) ||
+			(item.VariantID == nil && containsUint(withVariants, item.ProductID)) ||
+			(product.ListingType == models.ListingAuction && won[item.ProductID] == nil)
+		soldOut := product.Status == models.ProductStatusSoldOut
+
+		if removed || (!soldOut && !product.IsPublished(now)) {
+			check.Warnings = append(check.Warnings, cartWarning{
+				CartID:  item.ID,
+				Code:    cartProductRemoved,
+				Message: "This product is no longer available",
+			})
+			checks = append(checks, check)
+			continue
+		}
+
+		check.Stock = product.Stock
+		if item.Variant != nil {
+			check.Stock = item.Variant.Stock
+		}
+		check.Quote = book.quote(product, item.Variant)
+
+		if check.Stock <= 0 {
+			check.Warnings = append(check.Warnings, cartWarning{
+				CartID:  item.ID,
+				Code:    cartOutOfStock,
+				Message: fmt.Sprintf("%s is out of stock", product.Name),
+			})
+			checks = append(checks, check)
+			continue
+		}
+		check.Available = true
+
+		if check.Item.Quantity > check.Stock {
+			if check.Item.ReducedFrom == nil {
+				from := check.Item.Quantity
+				check.Item.ReducedFrom = &from
+			}
+			check.Item.Quantity = check.Stock
+			if err := database.DB.Model(&models.CartItem{}).Where("id = ?", item.ID).
+				Updates(map[string]interface{}{"quantity": check.Item.Quantity, "reduced_from": check.Item.ReducedFrom}).Error; err != nil {
+				return nil, err
+			}
+		}
+		if check.Item.ReducedFrom != nil {
+			from, to := *check.Item.ReducedFrom, check.Item.Quantity
+			check.Warnings = append(check.Warnings, cartWarning{
+				CartID:      item.ID,
+				Code:        cartQuantityReduced,
+				Message:     fmt.Sprintf("Only %d of %s left, the quantity was reduced", to, product.Name),
+				OldQuantity: &from,
+				NewQuantity: &to,
+			})
+		}
+
+		// Another buyer's checkout holds part of the stock. The quantity is
+		// kept, the hold may expire.
+		if held := reserved[cartStockKey(item.ProductID, item.VariantID)]; check.Stock-held < check.Item.Quantity {
+			check.Available = false
+			check.Warnings = append(check.Warnings, cartWarning{
+				CartID:  item.ID,
+				Code:    cartReserved,
+				Message: fmt.Sprintf("%s is reserved by another buyer, please try again later", product.Name),
+			})
+		}
+
+		check.Quote = withOffer(check.Quote, offers[cartStockKey(item.ProductID, item.VariantID)], check.Item.Quantity)
+		check.Quote = withFlashClaim(check.Quote, claims[cartStockKey(item.ProductID, item.VariantID)], check.Item.Quantity)
+		check.Quote = withAuction(check.Quote, won[item.ProductID])
+		if check.Quote.Price != item.Price {
+			oldPrice, newPrice := item.Price, check.Quote.Price
+			check.Warnings = append(check.Warnings, cartWarning{
+				CartID:   item.ID,
+				Code:     cartPriceChanged,
+				Message:  fmt.Sprintf("The price of %s changed", product.Name),
+				OldPrice: &oldPrice,
+				NewPrice: &newPrice,
+			})
+		}
+
+		checks = append(checks, check)
+	}
+
+	return checks, nil
+}
+
+func cartWarnings(checks []cartCheck) []cartWarning {
+	warnings := []cartWarning{}
+	for _, check := range checks {
+		warnings = append(warnings, check.Warnings...)
+	}
+	return warnings
+}
+
+func containsUint(list []uint, value uint) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,305 @@
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"finpro/database"
+	"finpro/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func GetAllCategories(c *fiber.Ctx) error {
+	categories, err := loadCategories()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch categories",
+			"error":   err.Error(),
+		})
+	}
+
+	if c.QueryBool("flat") {
+		return c.JSON(fiber.Map{"status": "success", "data": categories})
+	}
+
+	return c.JSON(fiber.Map{"status": "success", "data": categoryTree(categories, nil)})
+}
+
+// GetCategory looks a category up by ID or slug and returns it with its
+// subcategories and the path from the root category.
+func GetCategory(c *fiber.Ctx) error {
+	category, err := findCategory(c.Params("category"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Category not found"})
+	}
+
+	categories, err := loadCategories()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch categories"})
+	}
+	category.Children = categoryTree(categories, &category.ID)
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   category,
+		"path":   categoryPath(categories, category.ID),
+		// The schema products of this category are validated against,
+		// which may come from a parent category.
+		"effective_attribute_schema": categorySchema(categories, &category.ID),
+	})
+}
+
+func CreateCategory(c *fiber.Ctx) error {
+	var input models.CategoryInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	category := models.Category{}
+	if input.ParentID != nil && *input.ParentID != 0 {
+		category.ParentID = input.ParentID
+	}
+	if status, err := applyCategoryInput(&category, input); err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	category.AttributeSchema = normalizeAttributeSchema(input.AttributeSchema)
+
+	if err := database.DB.Create(&category).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create category"})
+	}
+
+	recordAudit(c, "category.create", "category", category.ID, nil, category)
+
+	return c.Status(201).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Category created successfully",
+		"data":    category,
+	})
+}
+
+// UpdateCategory renames a category or moves it under another parent. A
+// parent_id of 0 moves it to the root.
+func UpdateCategory(c *fiber.Ctx) error {
+	var category models.Category
+	if err := database.DB.First(&category, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Category not found"})
+	}
+	before := category
+
+	var input models.CategoryInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if input.Name == "" {
+		input.Name = category.Name
+	}
+	// Keep the slug on rename so existing links stay valid.
+	if input.Slug == "" {
+		input.Slug = category.Slug
+	}
+	if input.ParentID != nil {
+		if *input.ParentID == 0 {
+			category.ParentID = nil
+		} else {
+			category.ParentID = input.ParentID
+		}
+	}
+
+	if status, err := applyCategoryInput(&category, input); err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	if input.AttributeSchema != nil {
+		category.AttributeSchema = normalizeAttributeSchema(input.AttributeSchema)
+	}
+
+	if err := database.DB.Select("name", "slug", "parent_id", "attribute_schema").Save(&category).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update category"})
+	}
+
+	recordAudit(c, "category.update", "category", category.ID, before, category)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Category updated successfully",
+		"data":    category,
+	})
+}
+
+// DeleteCategory only removes empty categories, so no product ever points at
+// a missing category.
+func DeleteCategory(c *fiber.Ctx) error {
+	var category models.Category
+	if err := database.DB.First(&category, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Category not found"})
+	}
+
+	var children int64
+	database.DB.Model(&models.Category{}).Where("parent_id = ?", category.ID).Count(&children)
+	if children > 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Category still has subcategories"})
+	}
+
+	var products int64
+	database.DB.Model(&models.Product{}).Where("category_id = ?", category.ID).Count(&products)
+	if products > 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Category still has products, move them to another category first"})
+	}
+
+	if err := database.DB.Delete(&category).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete category"})
+	}
+
+	recordAudit(c, "category.delete", "category", category.ID, category, nil)
+
+	return c.JSON(fiber.Map{"status": "success", "message": "Category deleted successfully"})
+}
+
+// applyCategoryInput validates name, slug and parent and copies them onto the
+// category. ParentID must already be set on the category.
+func applyCategoryInput(category *models.Category, input models.CategoryInput) (int, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" || len(name) > 100 {
+		return 400, fmt.Errorf("Category name is required and must be at most 100 characters")
+	}
+
+	slug := models.Slugify(input.Slug)
+	if slug == "" {
+		slug = models.Slugify(name)
+	}
+	if slug == "" || len(slug) > 120 {
+		return 400, fmt.Errorf("Category slug must contain letters or digits")
+	}
+	// A numeric slug would be ambiguous with category IDs in URLs.
+	if _, err := strconv.Atoi(slug); err == nil {
+		return 400, fmt.Errorf("Category slug cannot be a number")
+	}
+
+	var count int64
+	database.DB.Model(&models.Category{}).Where("slug = ? AND id <> ?", slug, category.ID).Count(&count)
+	if count > 0 {
+		return 409, fmt.Errorf("Category slug already exists")
+	}
+
+	if category.ParentID != nil {
+		categories, err := loadCategories()
+		if err != nil {
+			return 500, fmt.Errorf("Failed to fetch categories")
+		}
+
+		found := false
+		for _, other := range categories {
+			if other.ID == *category.ParentID {
+				found = true
+			}
+		}
+		if !found {
+			return 400, fmt.Errorf("Parent category not found")
+		}
+
+		if category.ID != 0 {
+			for _, id := range categoryDescendants(categories, category.ID) {
+				if id == *category.ParentID {
+					return 400, fmt.Errorf("A category cannot be moved below itself")
+				}
+			}
+		}
+	}
+
+	if err := validateAttributeSchema(input.AttributeSchema); err != nil {
+		return 400, err
+	}
+
+	category.Name = name
+	category.Slug = slug
+	return 0, nil
+}
+
+func loadCategories() ([]models.Category, error) {
+	var categories []models.Category
+	err := database.DB.Order("name").Find(&categories).Error
+	return categories, err
+}
+
+// findCategory accepts a category ID or slug, matching the slug case
+// insensitively.
+func findCategory(value string) (*models.Category, error) {
+	value = strings.TrimSpace(value)
+
+	var category models.Category
+	if id, err := strconv.ParseUint(value, 10, 64); err == nil {
+		if err := database.DB.First(&category, id).Error; err != nil {
+			return nil, err
+		}
+		return &category, nil
+	}
+
+	if err := database.DB.Where("slug = ?", models.Slugify(value)).First(&category).Error; err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// categoryFilter resolves a category ID or slug to the IDs of the category
+// and all of its subcategories, for listing a whole branch.
+func categoryFilter(value string) ([]uint, error) {
+	category, err := findCategory(value)
+	if err != nil {
+		return nil, fmt.Errorf("Category not found")
+	}
+
+	categories, err := loadCategories()
+	if err != nil {
+		return nil, err
+	}
+	return categoryDescendants(categories, category.ID), nil
+}
+
+// categoryTree nests the flat list below the given parent, nil for roots.
+func categoryTree(categories []models.Category, parentID *uint) []models.Category {
+	tree := []models.Category{}
+	for _, category := range categories {
+		if (parentID == nil && category.ParentID == nil) ||
+			(parentID != nil && category.ParentID != nil && *category.ParentID == *parentID) {
+			category.Children = categoryTree(categories, &category.ID)
+			tree = append(tree, category)
+		}
+	}
+	return tree
+}
+
+// categoryDescendants returns the ID itself and the IDs of every category
+// below it.
+func categoryDescendants(categories []models.Category, id uint) []uint {
+	ids := []uint{id}
+	for i := 0; i < len(ids); i++ {
+		for _, category := range categories {
+			if category.ParentID != nil && *category.ParentID == ids[i] {
+				ids = append(ids, category.ID)
+			}
+		}
+	}
+	return ids
+}
+
+// categoryPath lists the categories from the root down to the given one.
+func categoryPath(categories []models.Category, id uint) []models.Category {
+	byID := map[uint]models.Category{}
+	for _, category := range categories {
+		byID[category.ID] = category
+	}
+
+	path := []models.Category{}
+	current, ok := byID[id]
+	for ok && len(path) <= len(categories) {
+		current.Children = nil
+		path = append([]models.Category{current}, path...)
+		if current.ParentID == nil {
+			break
+		}
+		current, ok = byID[*current.ParentID]
+	}
+	return path
+}
@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"finpro/database"
+	"finpro/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetMyDiscounts lists the discounts of the caller's shop. ?active=true only
+// returns discounts running now.
+func GetMyDiscounts(c *fiber.Ctx) error {
+	shop, err := sellerShop(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	query := database.DB.Where("shop_id = ?", shop.ID)
+	if c.QueryBool("active") {
+		now := time.Now()
+		query = query.Where("starts_at <= ? AND ends_at > ?", now, now)
+	}
+
+	discounts := []models.Discount{}
+	if err := query.Order("starts_at DESC, id DESC").Find(&discounts).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch discounts"})
+	}
+
+	return c.JSON(fiber.Map{"status": "success", "data": discounts})
+}
+
+// CreateDiscount adds a sale price or percentage to one product, or a
+// shop-wide sale when no product_id is given.
+func CreateDiscount(c *fiber.Ctx) error {
+	shop, err := sellerShop(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var input models.DiscountInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	discount := models.Discount{ShopID: shop.ID, StartsAt: time.Now()}
+	if status, err := applyDiscountInput(&discount, input); err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := database.DB.Create(&discount).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create discount"})
+	}
+
+	recordAudit(c, "discount.create", "discount", discount.ID, nil, discount)
+
+	return c.Status(201).JSON(fiber.Map{"status": "success", "data": discount})
+}
+
+func UpdateDiscount(c *fiber.Ctx) error {
+	discount, status, err := ownedDiscount(c)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var input models.DiscountInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	before := *discount
+	if status, err := applyDiscountInput(discount, input); err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := database.DB.Save(discount).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update discount"})
+	}
+
+	recordAudit(c, "discount.update", "discount", discount.ID, before, discount)
+
+	return c.JSON(fiber.Map{"status": "success", "data": discount})
+}
+
+// DeleteDiscount ends a discount. Orders already placed keep their price.
+func DeleteDiscount(c *fiber.Ctx) error {
+	discount, status, err := ownedDiscount(c)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := database.DB.Delete(discount).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete discount"})
+	}
+
+	recordAudit(c, "discount.delete", "discount", discount.ID, discount, nil)
+
+	return c.JSON(fiber.Map{"status": "success", "message": "Discount deleted successfully"})
+}
+
+func ownedDiscount(c *fiber.Ctx) (*models.Discount, int, error) {
+	shop, err := sellerShop(c)
+	if err != nil {
+		return nil, fiber.StatusBadRequest, err
+	}
+
+	var discount models.Discount
+	if err := database.DB.First(&discount, c.Params("id")).Error; err != nil {
+		return nil, 404, fmt.Errorf("Discount not found")
+	}
+	if discount.ShopID != shop.ID {
+		return nil, fiber.StatusForbidden, fmt.Errorf("Kamu tidak memiliki izin untuk mengubah diskon ini")
+	}
+	return &discount, 0, nil
+}
+
+func applyDiscountInput(discount *models.Discount, input models.DiscountInput) (int, error) {
+	if input.ProductID != nil {
+		discount.ProductID = nil
+		if *input.ProductID != 0 {
+			var product models.Product
+			if err := database.DB.First(&product, *input.ProductID).Error; err != nil {
+				return 404, fmt.Errorf("Product not found")
+			}
+			if product.ShopID != discount.ShopID {
+				return fiber.StatusForbidden, fmt.Errorf("Kamu tidak memiliki izin untuk mengubah produk ini")
+			}
+			discount.ProductID = &product.ID
+		}
+	}
+	if input.Name != nil {
+		name := strings.TrimSpace(*input.Name)
+		if len(name) > 100 {
+			return 400, fmt.Errorf("Name must be at most 100 characters")
+		}
+		discount.Name = name
+	}
+
+	// A discount is either a sale price or a percentage, setting one
+	// replaces the other.
+	if input.SalePrice != nil && input.Percent != nil {
+		return 400, fmt.Errorf("Send either sale_price or percent, not both")
+	}
+	if input.SalePrice != nil {
+		discount.SalePrice = input.SalePrice
+		discount.Percent = nil
+	}
+	if input.Percent != nil {
+		discount.Percent = input.Percent
+		discount.SalePrice = nil
+	}
+	if input.StartsAt != nil {
+		discount.StartsAt = *input.StartsAt
+	}
+	if input.EndsAt != nil {
+		discount.EndsAt = *input.EndsAt
+	}
+
+	switch {
+	case discount.Percent != nil:
+		if *discount.Percent <= 0 || *discount.Percent >= 100 {
+			return 400, fmt.Errorf("percent must be between 0 and 100")
+		}
+	case discount.SalePrice != nil:
+		if discount.ProductID == nil {
+			return 400, fmt.Errorf("A shop-wide sale needs a percent")
+		}
+		if *discount.SalePrice <= 0 {
+			return 400, fmt.Errorf("sale_price must be greater than 0")
+		}
+		var product models.Product
+		if err := database.DB.First(&product, *discount.ProductID).Error; err != nil {
+			return 404, fmt.Errorf("Product not found")
+		}
+		if *discount.SalePrice >= product.Price {
+			return 400, fmt.Errorf("sale_price must be lower than the product price")
+		}
+	default:
+		return 400, fmt.Errorf("A sale_price or percent is required")
+	}
+
+	if discount.EndsAt.IsZero() {
+		return 400, fmt.Errorf("ends_at is required")
+	}
+	if !discount.EndsAt.After(discount.StartsAt) {
+		return 400, fmt.Errorf("ends_at must be after starts_at")
+	}
+
+	return 0, nil
+}
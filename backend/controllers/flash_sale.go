@@ -0,0 +1,518 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"finpro/database"
+	"finpro/flashsale"
+	"finpro/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// flashClaimWindow is how long a buyer has to check out claimed units.
+const flashClaimWindow = 10 * time.Minute
+
+// flashSales allocates the units of running and upcoming flash sales.
+// flashSaleCache keeps those sales next to it, so a claim that gets no units
+// is answered without a query.
+var (
+	flashSales     = flashsale.NewAllocator()
+	flashSaleCache sync.Map
+)
+
+type flashSaleEntry struct {
+	sale    models.FlashSale
+	ownerID uint
+}
+
+type flashSaleError struct {
+	message string
+}
+
+func (e *flashSaleError) Error() string {
+	return e.message
+}
+
+// GetFlashSales lists running and upcoming flash sales with the units left.
+func GetFlashSales(c *fiber.Ctx) error {
+	sales := []models.FlashSale{}
+	if err := database.DB.Preload("Product").
+		Where("ends_at > ? AND shop_id NOT IN (?)", time.Now(), hiddenShopIDs()).
+		Order("starts_at ASC, id ASC").Limit(100).Find(&sales).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch flash sales"})
+	}
+	for i := range sales {
+		presentFlashSale(&sales[i])
+	}
+
+	return c.JSON(fiber.Map{"status": "success", "data": sales})
+}
+
+// GetMyFlashSales lists the flash sales of the seller's shop.
+func GetMyFlashSales(c *fiber.Ctx) error {
+	shop, err := sellerShop(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	sales := []models.FlashSale{}
+	if err := database.DB.Preload("Product").Where("shop_id = ?", shop.ID).
+		Order("starts_at DESC, id DESC").Find(&sales).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch flash sales"})
+	}
+	for i := range sales {
+		presentFlashSale(&sales[i])
+	}
+
+	return c.JSON(fiber.Map{"status": "success", "data": sales})
+}
+
+// CreateFlashSale offers a limited quantity of a product of the seller's
+// shop at a sale price or percentage off for a short time.
+func CreateFlashSale(c *fiber.Ctx) error {
+	var body struct {
+		ProductID    uint       `json:"product_id"`
+		VariantID    *uint      `json:"variant_id"`
+		Name         string     `json:"name"`
+		SalePrice    *float64   `json:"sale_price"`
+		Percent      *float64   `json:"percent"`
+		Quantity     int        `json:"quantity"`
+		PerUserLimit int        `json:"per_user_limit"`
+		StartsAt     *time.Time `json:"starts_at"`
+		EndsAt       time.Time  `json:"ends_at"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	product, status, err := ownedProduct(c, fmt.Sprint(body.ProductID))
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	if product.ListingType == models.ListingAuction {
+		return c.Status(400).JSON(fiber.Map{"error": "Products being auctioned cannot be in a flash sale"})
+	}
+
+	var variantCount int64
+	database.DB.Model(&models.ProductVariant{}).Where("product_id = ?", product.ID).Count(&variantCount)
+	if variantCount > 0 && body.VariantID == nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Please choose a variant of this product"})
+	}
+	price, stock := product.Price, product.Stock
+	if body.VariantID != nil {
+		var variant models.ProductVariant
+		if err := database.DB.Where("product_id = ?", product.ID).First(&variant, *body.VariantID).Error; err != nil {
+			return c.Status(404).JSON(fiber.Map{"error": "Variant not found"})
+		}
+		price, stock = variant.UnitPrice(product), variant.Stock
+	}
+
+	now := time.Now()
+	sale := models.FlashSale{
+		ShopID:       product.ShopID,
+		ProductID:    product.ID,
+		VariantID:    body.VariantID,
+		Name:         body.Name,
+		Quantity:     body.Quantity,
+		PerUserLimit: body.PerUserLimit,
+		StartsAt:     now,
+		EndsAt:       body.EndsAt,
+	}
+	if body.StartsAt != nil {
+		sale.StartsAt = *body.StartsAt
+	}
+
+	switch {
+	case len(sale.Name) > 100:
+		return c.Status(400).JSON(fiber.Map{"error": "Name must be at most 100 characters"})
+	case (body.SalePrice == nil) == (body.Percent == nil):
+		return c.Status(400).JSON(fiber.Map{"error": "Send either sale_price or percent"})
+	case body.Percent != nil && (*body.Percent <= 0 || *body.Percent >= 100):
+		return c.Status(400).JSON(fiber.Map{"error": "percent must be between 0 and 100"})
+	case body.SalePrice != nil && (*body.SalePrice <= 0 || *body.SalePrice >= price):
+		return c.Status(400).JSON(fiber.Map{"error": "sale_price must be greater than 0 and lower than the product price"})
+	case sale.Quantity <= 0 || sale.Quantity > stock:
+		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("quantity must be between 1 and the stock of %d", stock)})
+	case sale.PerUserLimit < 0:
+		return c.Status(400).JSON(fiber.Map{"error": "per_user_limit cannot be negative"})
+	case !sale.EndsAt.After(now) || !sale.EndsAt.After(sale.StartsAt):
+		return c.Status(400).JSON(fiber.Map{"error": "ends_at must be in the future and after starts_at"})
+	}
+	sale.SalePrice, sale.Percent = body.SalePrice, body.Percent
+
+	overlap := database.DB.Model(&models.FlashSale{}).
+		Where("product_id = ? AND starts_at < ? AND ends_at > ?", product.ID, sale.EndsAt, sale.StartsAt)
+	if sale.VariantID != nil {
+		overlap = overlap.Where("variant_id = ?", *sale.VariantID)
+	}
+	var count int64
+	if err := overlap.Count(&count).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database error"})
+	}
+	if count > 0 {
+		return c.Status(409).JSON(fiber.Map{"error": "This product already has a flash sale at that time"})
+	}
+
+	if err := database.DB.Create(&sale).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create flash sale"})
+	}
+	if err := loadFlashSale(sale); err != nil {
+		fmt.Printf("⚠️ Failed to load flash sale %d: %v\n", sale.ID, err)
+	}
+
+	recordAudit(c, "flash_sale.create", "flash_sale", sale.ID, nil, sale)
+
+	return c.Status(201).JSON(fiber.Map{"status": "success", "data": sale})
+}
+
+// DeleteFlashSale removes a flash sale that has not started yet.
+func DeleteFlashSale(c *fiber.Ctx) error {
+	shop, err := sellerShop(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var sale models.FlashSale
+	if err := database.DB.First(&sale, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Flash sale not found"})
+	}
+	if sale.ShopID != shop.ID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Kamu tidak memiliki izin untuk mengubah flash sale ini"})
+	}
+	if !time.Now().Before(sale.StartsAt) {
+		return c.Status(409).JSON(fiber.Map{"error": "Flash sales that have started cannot be deleted"})
+	}
+
+	if err := database.DB.Delete(&sale).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete flash sale"})
+	}
+	flashSales.Forget(sale.ID)
+	flashSaleCache.Delete(sale.ID)
+
+	recordAudit(c, "flash_sale.delete", "flash_sale", sale.ID, sale, nil)
+
+	return c.JSON(fiber.Map{"status": "success", "message": "Flash sale deleted successfully"})
+}
+
+// ClaimFlashSale allocates units of a running flash sale to the buyer. The
+// allocation is decided in memory; only buyers who get units write to the
+// database, where the units are put in the cart at the flash sale price and
+// the stock is held until the claim expires.
+func ClaimFlashSale(c *fiber.Ctx) error {
+	var body struct {
+		Quantity int `json:"quantity"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+	}
+	if body.Quantity == 0 {
+		body.Quantity = 1
+	}
+	if body.Quantity < 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Quantity must be at least 1"})
+	}
+
+	id, _ := strconv.ParseUint(c.Params("id"), 10, 64)
+	cached, ok := flashSaleCache.Load(uint(id))
+	if !ok {
+		return c.Status(404).JSON(fiber.Map{"error": "Flash sale not found"})
+	}
+	entry := cached.(flashSaleEntry)
+	sale := entry.sale
+
+	now := time.Now()
+	if !sale.ActiveAt(now) {
+		return c.Status(409).JSON(fiber.Map{"error": "This flash sale is not running", "starts_at": sale.StartsAt})
+	}
+	userID := sessionUserID(c)
+	if userID == entry.ownerID {
+		return c.Status(400).JSON(fiber.Map{"error": "You cannot claim your own flash sale"})
+	}
+
+	switch err := flashSales.Claim(sale.ID, userID, body.Quantity); err {
+	case nil:
+	case flashsale.ErrSoldOut:
+		return c.Status(409).JSON(fiber.Map{"error": "This flash sale is sold out"})
+	case flashsale.ErrLimit:
+		return c.Status(409).JSON(fiber.Map{"error": fmt.Sprintf("You can claim at most %d units of this flash sale", sale.PerUserLimit)})
+	default:
+		return c.Status(404).JSON(fiber.Map{"error": "Flash sale not found"})
+	}
+
+	claim, cartItem, err := storeFlashClaim(sale, userID, body.Quantity, now)
+	if err != nil {
+		flashSales.Release(sale.ID, userID, body.Quantity)
+
+		var stockErr *outOfStockError
+		var heldErr *reservedError
+		var saleErr *flashSaleError
+		switch {
+		case errors.As(err, &stockErr):
+			return c.Status(409).JSON(fiber.Map{"error": stockErr.Error()})
+		case errors.As(err, &heldErr):
+			return c.Status(409).JSON(fiber.Map{"error": heldErr.Error(), "availability": availabilityReserved})
+		case errors.As(err, &saleErr):
+			return c.Status(409).JSON(fiber.Map{"error": saleErr.Error()})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to claim flash sale"})
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"status":     "success",
+		"message":    fmt.Sprintf("Check out before %s to get the flash sale price", claim.ExpiresAt.Format(time.RFC3339)),
+		"data":       claim,
+		"cart_id":    cartItem.ID,
+		"expires_at": claim.ExpiresAt,
+	})
+}
+
+// storeFlashClaim records units the allocator gave to a buyer. Claims of the
+// same sale are merged into one, which is put in the cart and holds the
+// stock until it expires.
+func storeFlashClaim(sale models.FlashSale, userID uint, quantity int, now time.Time) (*models.FlashSaleClaim, *models.CartItem, error) {
+	var claim models.FlashSaleClaim
+	var item models.CartItem
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var product models.Product
+		if err := tx.Scopes(visibleProducts).First(&product, sale.ProductID).Error; err != nil {
+			return &flashSaleError{message: "This product is no longer available"}
+		}
+		price := product.Price
+		var variant *models.ProductVariant
+		if sale.VariantID != nil {
+			variant = &models.ProductVariant{}
+			if err := tx.First(variant, *sale.VariantID).Error; err != nil {
+				return &flashSaleError{message: "This product is no longer available"}
+			}
+			price = variant.UnitPrice(&product)
+		}
+
+		err := tx.Where("flash_sale_id = ? AND user_id = ? AND status = ? AND expires_at > ?",
+			sale.ID, userID, models.FlashClaimHeld, now).First(&claim).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			claim = models.FlashSaleClaim{
+				FlashSaleID: sale.ID,
+				UserID:      userID,
+				ProductID:   sale.ProductID,
+				VariantID:   sale.VariantID,
+				Status:      models.FlashClaimHeld,
+			}
+		} else if err != nil {
+			return err
+		}
+		claim.Quantity += quantity
+		claim.Price = sale.Apply(price)
+		claim.ExpiresAt = now.Add(flashClaimWindow)
+		if err := tx.Save(&claim).Error; err != nil {
+			return err
+		}
+
+		err = cartOwner{UserID: userID}.scope(tx).
+			Where("product_id = ?", sale.ProductID).
+			Where(variantCondition(sale.VariantID)).
+			First(&item).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			item = models.CartItem{UserID: &userID, ProductID: sale.ProductID, VariantID: sale.VariantID}
+		} else if err != nil {
+			return err
+		}
+		item.Quantity = claim.Quantity
+		item.Price = claim.Price
+		item.ReducedFrom = nil
+		if err := tx.Omit(clause.Associations).Save(&item).Error; err != nil {
+			return err
+		}
+		item.Product = product
+		item.Variant = variant
+
+		if err := holdStock(tx, userID, item, now); err != nil {
+			return err
+		}
+		if err := releaseReservations(tx, []uint{item.ID}); err != nil {
+			return err
+		}
+		return tx.Create(&models.StockReservation{
+			UserID:     userID,
+			CartItemID: item.ID,
+			ProductID:  item.ProductID,
+			VariantID:  item.VariantID,
+			Quantity:   item.Quantity,
+			ExpiresAt:  claim.ExpiresAt,
+		}).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &claim, &item, nil
+}
+
+func variantCondition(variantID *uint) clause.Expr {
+	if variantID == nil {
+		return clause.Expr{SQL: "variant_id IS NULL"}
+	}
+	return clause.Expr{SQL: "variant_id = ?", Vars: []interface{}{*variantID}}
+}
+
+// heldFlashClaims returns the flash sale claims of a buyer that can still be
+// checked out, by product and variant.
+func heldFlashClaims(db *gorm.DB, userID uint, productIDs []uint, now time.Time) (map[stockKey]*models.FlashSaleClaim, error) {
+	claims := map[stockKey]*models.FlashSaleClaim{}
+	if userID == 0 || len(productIDs) == 0 {
+		return claims, nil
+	}
+
+	var rows []models.FlashSaleClaim
+	if err := db.Where("user_id = ? AND product_id IN ? AND status = ? AND expires_at > ?",
+		userID, productIDs, models.FlashClaimHeld, now).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		claims[cartStockKey(rows[i].ProductID, rows[i].VariantID)] = &rows[i]
+	}
+	return claims, nil
+}
+
+// withFlashClaim prices cart units covered by a held claim at the flash sale
+// price.
+func withFlashClaim(quote models.PriceQuote, claim *models.FlashSaleClaim, quantity int) models.PriceQuote {
+	if claim == nil || quantity > claim.Quantity || claim.Price >= quote.Price {
+		return quote
+	}
+	quote.Price = claim.Price
+	quote.Discount = nil
+	quote.OfferID = nil
+	quote.FlashClaimID = &claim.ID
+	return quote
+}
+
+// purchaseFlashClaim marks a held claim as bought by an order.
+func purchaseFlashClaim(tx *gorm.DB, claimID, orderID uint, now time.Time) error {
+	result := tx.Model(&models.FlashSaleClaim{}).
+		Where("id = ? AND status = ? AND expires_at > ?", claimID, models.FlashClaimHeld, now).
+		Updates(map[string]interface{}{"status": models.FlashClaimPurchased, "order_id": orderID})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return &flashSaleError{message: "Your flash sale claim has expired, please review your cart"}
+	}
+	return nil
+}
+
+// loadFlashSale sets up the allocator for a sale from its stored claims.
+func loadFlashSale(sale models.FlashSale) error {
+	var shop models.Shop
+	if err := database.DB.First(&shop, sale.ShopID).Error; err != nil {
+		return err
+	}
+
+	taken, total, err := flashSaleTaken(sale.ID)
+	if err != nil {
+		return err
+	}
+	flashSales.Load(sale.ID, sale.Quantity-total, sale.PerUserLimit, taken)
+	flashSaleCache.Store(sale.ID, flashSaleEntry{sale: sale, ownerID: shop.UserID})
+	return nil
+}
+
+// flashSaleTaken sums the held and purchased units of a sale per user.
+func flashSaleTaken(saleID uint) (map[uint]int, int, error) {
+	var rows []struct {
+		UserID   uint
+		Quantity int
+	}
+	if err := database.DB.Model(&models.FlashSaleClaim{}).
+		Select("user_id, SUM(quantity) AS quantity").
+		Where("flash_sale_id = ? AND status IN ?", saleID, []string{models.FlashClaimHeld, models.FlashClaimPurchased}).
+		Group("user_id").Scan(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+
+	taken := map[uint]int{}
+	total := 0
+	for _, row := range rows {
+		taken[row.UserID] = row.Quantity
+		total += row.Quantity
+	}
+	return taken, total, nil
+}
+
+// reconcileFlashSales keeps the allocator and the database in step: expired
+// claims go back to their sale, sales that are about to run are loaded,
+// counters are checked against the stored claims and ended sales are
+// dropped from memory.
+func reconcileFlashSales() {
+	now := time.Now()
+
+	var expired []models.FlashSaleClaim
+	if err := database.DB.Where("status = ? AND expires_at <= ?", models.FlashClaimHeld, now).
+		Find(&expired).Error; err != nil {
+		fmt.Printf("⚠️ Failed to find expired flash sale claims: %v\n", err)
+		return
+	}
+	for _, claim := range expired {
+		result := database.DB.Model(&models.FlashSaleClaim{}).
+			Where("id = ? AND status = ?", claim.ID, models.FlashClaimHeld).
+			Update("status", models.FlashClaimReleased)
+		if result.Error != nil {
+			fmt.Printf("⚠️ Failed to release flash sale claim %d: %v\n", claim.ID, result.Error)
+			continue
+		}
+		if result.RowsAffected == 1 {
+			flashSales.Release(claim.FlashSaleID, claim.UserID, claim.Quantity)
+		}
+	}
+
+	var sales []models.FlashSale
+	if err := database.DB.Where("ends_at > ?", now.Add(-flashClaimWindow)).Find(&sales).Error; err != nil {
+		fmt.Printf("⚠️ Failed to find flash sales: %v\n", err)
+		return
+	}
+	for _, sale := range sales {
+		taken, total, err := flashSaleTaken(sale.ID)
+		if err != nil {
+			fmt.Printf("⚠️ Failed to count flash sale %d: %v\n", sale.ID, err)
+			continue
+		}
+
+		var sold int64
+		database.DB.Model(&models.FlashSaleClaim{}).
+			Where("flash_sale_id = ? AND status = ?", sale.ID, models.FlashClaimPurchased).
+			Select("COALESCE(SUM(quantity), 0)").Scan(&sold)
+		if int(sold) != sale.Sold {
+			database.DB.Model(&models.FlashSale{}).Where("id = ?", sale.ID).Update("sold", sold)
+		}
+
+		switch {
+		case !now.Before(sale.EndsAt):
+			flashSales.Forget(sale.ID)
+			flashSaleCache.Delete(sale.ID)
+		case !flashSales.Loaded(sale.ID):
+			if err := loadFlashSale(sale); err != nil {
+				fmt.Printf("⚠️ Failed to load flash sale %d: %v\n", sale.ID, err)
+			}
+		default:
+			if removed := flashSales.Reconcile(sale.ID, sale.Quantity-total); removed > 0 {
+				fmt.Printf("⚠️ Flash sale %d had %d units too many in memory, corrected from %d stored claims (%d users)\n",
+					sale.ID, removed, total, len(taken))
+			}
+		}
+	}
+}
+
+// presentFlashSale fills in the units left of a sale.
+func presentFlashSale(sale *models.FlashSale) {
+	if remaining, ok := flashSales.Remaining(sale.ID); ok {
+		sale.Remaining = &remaining
+	}
+}
@@ -0,0 +1,255 @@
+package controllers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"finpro/database"
+	"finpro/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+)
+
+const (
+	guestCartCookie = "guest_cart"
+	// guestCartTTL is how long an untouched guest cart is kept.
+	guestCartTTL = 14 * 24 * time.Hour
+)
+
+// cartOwner is whoever a cart belongs to: a logged-in user or a guest cart.
+// The zero value owns nothing.
+type cartOwner struct {
+	UserID      uint
+	GuestCartID uint
+}
+
+func (o cartOwner) scope(db *gorm.DB) *gorm.DB {
+	if o.UserID != 0 {
+		return db.Where("cartitem.user_id = ?", o.UserID)
+	}
+	return db.Where("cartitem.guest_cart_id = ?", o.GuestCartID)
+}
+
+func (o cartOwner) empty() bool {
+	return o.UserID == 0 && o.GuestCartID == 0
+}
+
+// newItem starts a cart item that belongs to the owner.
+func (o cartOwner) newItem() models.CartItem {
+	var item models.CartItem
+	if o.UserID != 0 {
+		id := o.UserID
+		item.UserID = &id
+	}
+	if o.GuestCartID != 0 {
+		id := o.GuestCartID
+		item.GuestCartID = &id
+	}
+	return item
+}
+
+// currentCartOwner returns the logged-in user, or else the guest cart of the
+// cookie. With create set, a visitor without a guest cart gets a new one.
+func currentCartOwner(c *fiber.Ctx, create bool) (cartOwner, error) {
+	if userToken, ok := c.Locals("user").(*jwt.Token); ok {
+		claims := userToken.Claims.(jwt.MapClaims)
+		return cartOwner{UserID: uint(claims["id"].(float64))}, nil
+	}
+
+	now := time.Now()
+	if id, ok := guestCartFromCookie(c); ok {
+		var cart models.GuestCart
+		if err := database.DB.Where("last_seen_at > ?", now.Add(-guestCartTTL)).First(&cart, id).Error; err == nil {
+			// A day of precision is enough to know the cart is still used.
+			if now.Sub(cart.LastSeenAt) > 24*time.Hour {
+				database.DB.Model(&cart).Update("last_seen_at", now)
+				setGuestCartCookie(c, cart.ID)
+			}
+			return cartOwner{GuestCartID: cart.ID}, nil
+		}
+	}
+
+	if !create {
+		return cartOwner{}, nil
+	}
+
+	cart := models.GuestCart{LastSeenAt: now}
+	if err := database.DB.Create(&cart).Error; err != nil {
+		return cartOwner{}, err
+	}
+	setGuestCartCookie(c, cart.ID)
+	return cartOwner{GuestCartID: cart.ID}, nil
+}
+
+// signGuestCart signs the cart ID so visitors cannot pick another cart by
+// changing the cookie.
+func signGuestCart(id uint) string {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("JWT_SECRET")))
+	mac.Write([]byte("guest_cart:" + strconv.FormatUint(uint64(id), 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func guestCartFromCookie(c *fiber.Ctx) (uint, bool) {
+	value := c.Cookies(guestCartCookie)
+	idPart, signature, found := strings.Cut(value, ".")
+	if !found {
+		return 0, false
+	}
+
+	id, err := strconv.ParseUint(idPart, 10, 64)
+	if err != nil || id == 0 {
+		return 0, false
+	}
+	if !hmac.Equal([]byte(signature), []byte(signGuestCart(uint(id)))) {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func setGuestCartCookie(c *fiber.Ctx, id uint) {
+	c.Cookie(&fiber.Cookie{
+		Name:     guestCartCookie,
+		Value:    fmt.Sprintf("%d.%s", id, signGuestCart(id)),
+		Expires:  time.Now().Add(guestCartTTL),
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: "Lax",
+	})
+}
+
+func clearGuestCartCookie(c *fiber.Ctx) {
+	c.Cookie(&fiber.Cookie{
+		Name:     guestCartCookie,
+		Value:    "",
+		Expires:  time.Now().Add(-time.Hour),
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: "Lax",
+	})
+}
+
+// cartMergeResult tells the user what happened to their guest cart.
+type cartMergeResult struct {
+	Moved    int      `json:"moved"`
+	Combined int      `json:"combined"`
+	Dropped  []string `json:"dropped"`
+}
+
+// mergeGuestCart moves the guest cart of the cookie into the user's cart
+// after login or registration:
+//   - items the user does not have yet are moved over as they are;
+//   - items the user already has are combined, adding up the quantities but
+//     never beyond the stock left;
+//   - items of the user's own shop are dropped, sellers cannot buy from
+//     themselves.
+//
+// Price, availability and stock changes are reported by the cart as usual.
+// It returns nil when there was no guest cart.
+func mergeGuestCart(c *fiber.Ctx, user *models.User) *cartMergeResult {
+	id, ok := guestCartFromCookie(c)
+	if !ok {
+		return nil
+	}
+	clearGuestCartCookie(c)
+
+	var guestItems []models.CartItem
+	if err := database.DB.Preload("Product").Preload("Variant").
+		Where("guest_cart_id = ?", id).Find(&guestItems).Error; err != nil {
+		fmt.Printf("⚠️ Failed to load guest cart %d: %v\n", id, err)
+		return nil
+	}
+
+	var ownShopID uint
+	var shop models.Shop
+	if err := database.DB.Where("user_id = ?", user.ID).First(&shop).Error; err == nil {
+		ownShopID = shop.ID
+	}
+
+	result := &cartMergeResult{Dropped: []string{}}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, item := range guestItems {
+			if ownShopID != 0 && item.Product.ShopID == ownShopID {
+				result.Dropped = append(result.Dropped, item.Product.Name)
+				if err := tx.Delete(&item).Error; err != nil {
+					return err
+				}
+				continue
+			}
+
+			existing := tx.Where("user_id = ? AND product_id = ?", user.ID, item.ProductID)
+			if item.VariantID != nil {
+				existing = existing.Where("variant_id = ?", *item.VariantID)
+			} else {
+				existing = existing.Where("variant_id IS NULL")
+			}
+
+			var userItem models.CartItem
+			err := existing.First(&userItem).Error
+			if err == gorm.ErrRecordNotFound {
+				if err := tx.Model(&models.CartItem{}).Where("id = ?", item.ID).
+					Updates(map[string]interface{}{"user_id": user.ID, "guest_cart_id": nil}).Error; err != nil {
+					return err
+				}
+				result.Moved++
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			stock := item.Product.Stock
+			if item.Variant != nil {
+				stock = item.Variant.Stock
+			}
+			// Never lower what the user already had, the cart revalidation
+			// reports when that is more than the stock.
+			quantity := userItem.Quantity + item.Quantity
+			if quantity > stock {
+				quantity = max(stock, userItem.Quantity)
+			}
+
+			if err := tx.Model(&models.CartItem{}).Where("id = ?", userItem.ID).
+				Update("quantity", quantity).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&item).Error; err != nil {
+				return err
+			}
+			result.Combined++
+		}
+
+		return tx.Delete(&models.GuestCart{}, id).Error
+	})
+	if err != nil {
+		fmt.Printf("⚠️ Failed to merge guest cart %d: %v\n", id, err)
+		return nil
+	}
+
+	recordAuditAs(c, user, "cart.merge", "guest_cart", id, nil, result)
+	return result
+}
+
+// expireGuestCarts is the background job that deletes guest carts nobody
+// used for guestCartTTL, with their items.
+func expireGuestCarts() {
+	cutoff := time.Now().Add(-guestCartTTL)
+	expired := database.DB.Model(&models.GuestCart{}).Select("id").Where("last_seen_at <= ?", cutoff)
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("guest_cart_id IN (?)", expired).Delete(&models.CartItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("last_seen_at <= ?", cutoff).Delete(&models.GuestCart{}).Error
+	})
+	if err != nil {
+		fmt.Printf("⚠️ Failed to expire guest carts: %v\n", err)
+	}
+}
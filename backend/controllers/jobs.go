@@ -0,0 +1,22 @@
+package controllers
+
+import "time"
+
+// StartBackgroundJobs runs the periodic maintenance jobs of the shop in the
+// background. Call it once after the database is ready.
+func StartBackgroundJobs() {
+	go every(time.Minute, publishScheduledProducts)
+	go every(time.Minute, releaseExpiredReservations)
+	go every(time.Hour, expireGuestCarts)
+	go every(time.Minute, notifyWishlistChanges)
+	go every(time.Minute, expireOffers)
+	go every(10*time.Second, closeAuctions)
+	go every(30*time.Second, reconcileFlashSales)
+}
+
+func every(interval time.Duration, job func()) {
+	job()
+	for range time.Tick(interval) {
+		job()
+	}
+}
@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"time"
+
+	"finpro/database"
+	"finpro/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetNotifications lists the caller's notifications, newest first.
+// ?unread=true leaves out the ones already read.
+func GetNotifications(c *fiber.Ctx) error {
+	userID := sessionUserID(c)
+
+	query := database.DB.Where("user_id = ?", userID)
+	if c.QueryBool("unread") {
+		query = query.Where("read_at IS NULL")
+	}
+
+	notifications := []models.Notification{}
+	if err := query.Order("created_at DESC, id DESC").Limit(100).Find(&notifications).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch notifications"})
+	}
+
+	var unread int64
+	database.DB.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&unread)
+
+	return c.JSON(fiber.Map{"status": "success", "data": notifications, "unread": unread})
+}
+
+func MarkNotificationRead(c *fiber.Ctx) error {
+	var notification models.Notification
+	if err := database.DB.Where("user_id = ?", sessionUserID(c)).First(&notification, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Notification not found"})
+	}
+
+	if notification.ReadAt == nil {
+		now := time.Now()
+		notification.ReadAt = &now
+		if err := database.DB.Model(&notification).Update("read_at", now).Error; err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to update notification"})
+		}
+	}
+
+	return c.JSON(fiber.Map{"status": "success", "data": notification})
+}
+
+func MarkAllNotificationsRead(c *fiber.Ctx) error {
+	if err := database.DB.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", sessionUserID(c)).
+		Update("read_at", time.Now()).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update notifications"})
+	}
+
+	return c.JSON(fiber.Map{"status": "success", "message": "All notifications marked as read"})
+}
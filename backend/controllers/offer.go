@@ -0,0 +1,432 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"finpro/database"
+	"finpro/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	// offerResponseWindow is how long the other side has to answer an
+	// offer or counter offer.
+	offerResponseWindow = 48 * time.Hour
+	// acceptedOfferWindow is how long the buyer can check out at the
+	// agreed price.
+	acceptedOfferWindow = 24 * time.Hour
+)
+
+type offerError struct {
+	message string
+}
+
+func (e *offerError) Error() string {
+	return e.message
+}
+
+// MakeOffer lets a buyer propose a lower price for a product, or for one of
+// its variants. The seller is notified and has offerResponseWindow to answer.
+func MakeOffer(c *fiber.Ctx) error {
+	type Request struct {
+		ProductID uint    `json:"product_id"`
+		VariantID *uint   `json:"variant_id"`
+		Quantity  int     `json:"quantity"`
+		Price     float64 `json:"price"`
+		Message   string  `json:"message"`
+	}
+
+	var body Request
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if body.Quantity == 0 {
+		body.Quantity = 1
+	}
+	if body.Quantity < 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Quantity must be at least 1"})
+	}
+	message := strings.TrimSpace(body.Message)
+	if len(message) > 300 {
+		return c.Status(400).JSON(fiber.Map{"error": "Message must be at most 300 characters"})
+	}
+
+	userID := sessionUserID(c)
+	now := time.Now()
+
+	var product models.Product
+	if err := database.DB.Scopes(visibleProducts).First(&product, body.ProductID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Product not found"})
+	}
+
+	if product.ListingType == models.ListingAuction {
+		return c.Status(400).JSON(fiber.Map{"error": "This product is sold by auction, place a bid instead"})
+	}
+
+	var shop models.Shop
+	if err := database.DB.First(&shop, product.ShopID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Shop not found"})
+	}
+	if shop.UserID == userID {
+		return c.Status(400).JSON(fiber.Map{"error": "You cannot make an offer on your own product"})
+	}
+
+	var variant *models.ProductVariant
+	var variantCount int64
+	database.DB.Model(&models.ProductVariant{}).Where("product_id = ?", product.ID).Count(&variantCount)
+	if variantCount > 0 && body.VariantID == nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Please choose a variant of this product"})
+	}
+	if body.VariantID != nil {
+		variant = &models.ProductVariant{}
+		if err := database.DB.Where("product_id = ?", product.ID).First(variant, *body.VariantID).Error; err != nil {
+			return c.Status(404).JSON(fiber.Map{"error": "Variant not found"})
+		}
+	}
+
+	stock := product.Stock
+	if variant != nil {
+		stock = variant.Stock
+	}
+	if stock <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "This product is out of stock"})
+	}
+	if body.Quantity > stock {
+		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("Only %d left in stock", stock)})
+	}
+
+	book, err := loadPriceBook([]models.Product{product}, now)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch discounts"})
+	}
+	quote := book.quote(&product, variant)
+	if body.Price <= 0 || body.Price >= quote.Price {
+		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("Your offer must be below the current price of %.0f", quote.Price)})
+	}
+
+	var open int64
+	if err := offerFor(database.DB, userID, product.ID, body.VariantID).
+		Where("status IN ? AND expires_at > ?", []string{models.OfferPending, models.OfferCountered}, now).
+		Count(&open).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Database error"})
+	}
+	if open > 0 {
+		return c.Status(409).JSON(fiber.Map{"error": "You already have an open offer on this product"})
+	}
+
+	offer := models.Offer{
+		UserID:    userID,
+		ShopID:    shop.ID,
+		ProductID: product.ID,
+		VariantID: body.VariantID,
+		Quantity:  body.Quantity,
+		Price:     body.Price,
+		Message:   message,
+		Status:    models.OfferPending,
+		ExpiresAt: now.Add(offerResponseWindow),
+	}
+	if err := database.DB.Create(&offer).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to make offer"})
+	}
+
+	notifyOffer(shop.UserID, offer, product.Name, "New offer", fmt.Sprintf("A buyer offered %.0f for %s", offer.Price, product.Name))
+	recordAudit(c, "offer.create", "offer", offer.ID, nil, offer)
+
+	return c.Status(201).JSON(fiber.Map{"status": "success", "data": offer})
+}
+
+// GetMyOffers lists the offers the buyer made, optionally filtered by
+// ?status=.
+func GetMyOffers(c *fiber.Ctx) error {
+	query := database.DB.Where("user_id = ?", sessionUserID(c))
+	return listOffers(c, query)
+}
+
+// GetShopOffers lists the offers on the products of the seller's shop,
+// optionally filtered by ?status=.
+func GetShopOffers(c *fiber.Ctx) error {
+	shop, err := sellerShop(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return listOffers(c, database.DB.Where("shop_id = ?", shop.ID))
+}
+
+func listOffers(c *fiber.Ctx, query *gorm.DB) error {
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	offers := []models.Offer{}
+	if err := query.Preload("Product").Preload("Variant").
+		Order("updated_at DESC, id DESC").Limit(100).Find(&offers).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch offers"})
+	}
+
+	return c.JSON(fiber.Map{"status": "success", "data": offers})
+}
+
+// AcceptOffer accepts the price on the table: the buyer's offer when the
+// seller answers, the counter offer when the buyer does. The buyer can then
+// check out at that price for acceptedOfferWindow.
+func AcceptOffer(c *fiber.Ctx) error {
+	offer, counterpart, status, err := offerTurn(c)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	before := *offer
+	price := offer.Price
+	if offer.Status == models.OfferCountered {
+		price = *offer.CounterPrice
+	}
+	priceExpiresAt := time.Now().Add(acceptedOfferWindow)
+	offer.Status = models.OfferAccepted
+	offer.AgreedPrice = &price
+	offer.PriceExpiresAt = &priceExpiresAt
+
+	if status, err := transitionOffer(offer, before.Status, map[string]interface{}{
+		"status":           offer.Status,
+		"agreed_price":     offer.AgreedPrice,
+		"price_expires_at": offer.PriceExpiresAt,
+	}); err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	notifyOffer(counterpart, *offer, offer.Product.Name, "Offer accepted",
+		fmt.Sprintf("The offer of %.0f for %s was accepted", price, offer.Product.Name))
+	recordAudit(c, "offer.accept", "offer", offer.ID, before, offer)
+
+	return c.JSON(fiber.Map{"status": "success", "data": offer})
+}
+
+// RejectOffer ends the negotiation when it is the caller's turn.
+func RejectOffer(c *fiber.Ctx) error {
+	offer, counterpart, status, err := offerTurn(c)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	before := *offer
+	offer.Status = models.OfferRejected
+	if status, err := transitionOffer(offer, before.Status, map[string]interface{}{"status": offer.Status}); err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	notifyOffer(counterpart, *offer, offer.Product.Name, "Offer rejected",
+		fmt.Sprintf("The offer for %s was rejected", offer.Product.Name))
+	recordAudit(c, "offer.reject", "offer", offer.ID, before, offer)
+
+	return c.JSON(fiber.Map{"status": "success", "data": offer})
+}
+
+// CounterOffer answers with another price. Sellers counter above the buyer's
+// offer, buyers counter between their offer and the seller's counter.
+func CounterOffer(c *fiber.Ctx) error {
+	offer, counterpart, status, err := offerTurn(c)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var body struct {
+		Price float64 `json:"price"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	before := *offer
+	if offer.Status == models.OfferPending {
+		if body.Price <= offer.Price {
+			return c.Status(400).JSON(fiber.Map{"error": "A counter offer must be above the buyer's offer"})
+		}
+		offer.CounterPrice = &body.Price
+		offer.Status = models.OfferCountered
+	} else {
+		if body.Price <= offer.Price || body.Price >= *offer.CounterPrice {
+			return c.Status(400).JSON(fiber.Map{"error": "A counter offer must be between your last offer and the seller's counter offer"})
+		}
+		offer.Price = body.Price
+		offer.Status = models.OfferPending
+	}
+	offer.ExpiresAt = time.Now().Add(offerResponseWindow)
+
+	if status, err := transitionOffer(offer, before.Status, map[string]interface{}{
+		"status":        offer.Status,
+		"price":         offer.Price,
+		"counter_price": offer.CounterPrice,
+		"expires_at":    offer.ExpiresAt,
+	}); err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	notifyOffer(counterpart, *offer, offer.Product.Name, "Counter offer",
+		fmt.Sprintf("You received a counter offer of %.0f for %s", body.Price, offer.Product.Name))
+	recordAudit(c, "offer.counter", "offer", offer.ID, before, offer)
+
+	return c.JSON(fiber.Map{"status": "success", "data": offer})
+}
+
+// WithdrawOffer lets the buyer take back an offer that is still open.
+func WithdrawOffer(c *fiber.Ctx) error {
+	var offer models.Offer
+	if err := database.DB.First(&offer, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Offer not found"})
+	}
+	if offer.UserID != sessionUserID(c) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Kamu tidak memiliki izin untuk mengubah penawaran ini"})
+	}
+	if !offer.Open(time.Now()) {
+		return c.Status(409).JSON(fiber.Map{"error": "This offer is no longer open"})
+	}
+
+	before := offer
+	offer.Status = models.OfferWithdrawn
+	if status, err := transitionOffer(&offer, before.Status, map[string]interface{}{"status": offer.Status}); err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	recordAudit(c, "offer.withdraw", "offer", offer.ID, before, offer)
+
+	return c.JSON(fiber.Map{"status": "success", "data": offer})
+}
+
+// offerTurn loads the open offer of the request and checks that the caller
+// is the side who has to answer it. It also returns the user ID of the other
+// side.
+func offerTurn(c *fiber.Ctx) (*models.Offer, uint, int, error) {
+	var offer models.Offer
+	if err := database.DB.Preload("Product", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	}).First(&offer, c.Params("id")).Error; err != nil {
+		return nil, 0, 404, fmt.Errorf("Offer not found")
+	}
+
+	var shop models.Shop
+	if err := database.DB.First(&shop, offer.ShopID).Error; err != nil {
+		return nil, 0, 404, fmt.Errorf("Shop not found")
+	}
+
+	userID := sessionUserID(c)
+	if userID != offer.UserID && userID != shop.UserID {
+		return nil, 0, fiber.StatusForbidden, fmt.Errorf("Kamu tidak memiliki izin untuk mengubah penawaran ini")
+	}
+	if !offer.Open(time.Now()) {
+		return nil, 0, 409, fmt.Errorf("This offer is no longer open")
+	}
+
+	sellerTurn := offer.Status == models.OfferPending
+	if (sellerTurn && userID != shop.UserID) || (!sellerTurn && userID != offer.UserID) {
+		return nil, 0, 409, fmt.Errorf("Waiting for the other side to respond")
+	}
+
+	counterpart := shop.UserID
+	if sellerTurn {
+		counterpart = offer.UserID
+	}
+	return &offer, counterpart, 0, nil
+}
+
+// transitionOffer stores the changes of an answer to an offer, provided the
+// offer still has the status it was answered in. Both sides may answer at
+// the same time, e.g. the buyer withdrawing while the seller accepts; only
+// the first answer is stored, like claimOffer does at checkout.
+func transitionOffer(offer *models.Offer, fromStatus string, changes map[string]interface{}) (int, error) {
+	result := database.DB.Model(&models.Offer{}).
+		Where("id = ? AND status = ?", offer.ID, fromStatus).
+		Updates(changes)
+	if result.Error != nil {
+		return 500, fmt.Errorf("Failed to update offer")
+	}
+	if result.RowsAffected == 0 {
+		return 409, fmt.Errorf("This offer was answered meanwhile, please reload it")
+	}
+	return 0, nil
+}
+
+func offerFor(db *gorm.DB, userID, productID uint, variantID *uint) *gorm.DB {
+	query := db.Model(&models.Offer{}).Where("user_id = ? AND product_id = ?", userID, productID)
+	if variantID != nil {
+		return query.Where("variant_id = ?", *variantID)
+	}
+	return query.Where("variant_id IS NULL")
+}
+
+func notifyOffer(userID uint, offer models.Offer, productName, title, message string) {
+	notification := models.Notification{
+		UserID:    userID,
+		Type:      models.NotificationOffer,
+		Title:     title,
+		Message:   message,
+		ProductID: &offer.ProductID,
+	}
+	if err := database.DB.Create(&notification).Error; err != nil {
+		fmt.Printf("⚠️ Failed to notify user %d about offer %d on %s: %v\n", userID, offer.ID, productName, err)
+	}
+}
+
+// usableOffers returns the accepted offers of a buyer that can still be used
+// at checkout, by product and variant.
+func usableOffers(db *gorm.DB, userID uint, productIDs []uint, now time.Time) (map[stockKey]*models.Offer, error) {
+	offers := map[stockKey]*models.Offer{}
+	if userID == 0 || len(productIDs) == 0 {
+		return offers, nil
+	}
+
+	var rows []models.Offer
+	if err := db.Where("user_id = ? AND product_id IN ? AND status = ? AND order_id IS NULL AND price_expires_at > ?",
+		userID, productIDs, models.OfferAccepted, now).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		key := cartStockKey(rows[i].ProductID, rows[i].VariantID)
+		if current, ok := offers[key]; !ok || *rows[i].AgreedPrice < *current.AgreedPrice {
+			offers[key] = &rows[i]
+		}
+	}
+	return offers, nil
+}
+
+// withOffer lowers a quote to the agreed price of an offer when the offer
+// covers the quantity. Offers do not stack with discounts either, the lower
+// price wins.
+func withOffer(quote models.PriceQuote, offer *models.Offer, quantity int) models.PriceQuote {
+	if offer == nil || offer.AgreedPrice == nil || quantity > offer.Quantity || *offer.AgreedPrice >= quote.Price {
+		return quote
+	}
+	quote.Price = *offer.AgreedPrice
+	quote.Discount = nil
+	quote.OfferID = &offer.ID
+	return quote
+}
+
+// claimOffer marks an accepted offer as used by an order. It fails when the
+// offer has been used or expired in the meantime.
+func claimOffer(tx *gorm.DB, offerID, orderID uint, now time.Time) error {
+	result := tx.Model(&models.Offer{}).
+		Where("id = ? AND status = ? AND order_id IS NULL AND price_expires_at > ?", offerID, models.OfferAccepted, now).
+		Updates(map[string]interface{}{"status": models.OfferPurchased, "order_id": orderID})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return &offerError{message: "The agreed price of your offer is no longer available, please review your cart"}
+	}
+	return nil
+}
+
+// expireOffers closes offers nobody answered in time and agreed prices that
+// were not used.
+func expireOffers() {
+	now := time.Now()
+	err := database.DB.Model(&models.Offer{}).
+		Where("(status IN ? AND expires_at <= ?) OR (status = ? AND order_id IS NULL AND price_expires_at <= ?)",
+			[]string{models.OfferPending, models.OfferCountered}, now, models.OfferAccepted, now).
+		Update("status", models.OfferExpired).Error
+	if err != nil {
+		fmt.Printf("⚠️ Failed to expire offers: %v\n", err)
+	}
+}
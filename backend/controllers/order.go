@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"finpro/database"
 	"finpro/models"
 	"fmt"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
 )
 
 func parseUint(s string) uint {
@@ -20,6 +22,43 @@ func parseUint(s string) uint {
 	return n
 }
 
+type outOfStockError struct {
+	name string
+}
+
+func (e *outOfStockError) Error() string {
+	return "Not enough stock left for " + e.name
+}
+
+// decrementStock takes the quantity of a cart item from the stock of its
+// variant, or of the product when it has none. It fails instead of letting
+// the stock go below zero.
+func decrementStock(tx *gorm.DB, item models.CartItem) error {
+	if item.VariantID != nil {
+		result := tx.Model(&models.ProductVariant{}).
+			Where("id = ? AND stock >= ?", *item.VariantID, item.Quantity).
+			Update("stock", gorm.Expr("stock - ?", item.Quantity))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return &outOfStockError{name: item.Product.Name}
+		}
+		return syncProductStock(tx, item.ProductID)
+	}
+
+	result := tx.Model(&models.Product{}).
+		Where("id = ? AND stock >= ?", item.ProductID, item.Quantity).
+		Update("stock", gorm.Expr("stock - ?", item.Quantity))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return &outOfStockError{name: item.Product.Name}
+	}
+	return refreshSoldOut(tx, item.ProductID)
+}
+
 func CreateOrder(c *fiber.Ctx) error {
 	userToken := c.Locals("user").(*jwt.Token)
 	claims := userToken.Claims.(jwt.MapClaims)
@@ -35,6 +74,7 @@ func CreateOrder(c *fiber.Ctx) error {
 	telephone := form.Value["telephone"][0]
 	address := form.Value["address"][0]
 	note := form.Value["note"][0]
+	voucherCode := normalizeVoucherCode(c.FormValue("voucher_code"))
 
 	file, err := c.FormFile("proof_payment")
 	if file == nil || err != nil {
@@ -73,7 +113,7 @@ func CreateOrder(c *fiber.Ctx) error {
 	}
 
 	var cartItems []models.CartItem
-	if err := database.DB.Preload("Product").Where("id IN ?", cartIDs).Find(&cartItems).Error; err != nil {
+	if err := database.DB.Preload("Product").Preload("Variant").Where("id IN ? AND user_id = ?", cartIDs, userID).Find(&cartItems).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch selected cart items"})
 	}
 
@@ -81,9 +121,19 @@ func CreateOrder(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "No valid cart items found"})
 	}
 
-	var totalPrice float64
-	for _, item := range cartItems {
-		totalPrice += item.Price * float64(item.Quantity)
+	// Checkout only goes ahead when the cart matches the current products,
+	// so buyers are never charged a price or quantity they did not see.
+	checks, err := revalidateCart(cartItems, time.Now())
+	if err != nil {
+		_ = os.Remove(savePath)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create order"})
+	}
+	if warnings := cartWarnings(checks); len(warnings) > 0 {
+		_ = os.Remove(savePath)
+		return c.Status(409).JSON(fiber.Map{
+			"error":    "Your cart has changed, please review and acknowledge the changes before checkout",
+			"warnings": warnings,
+		})
 	}
 
 	order := models.Order{
@@ -93,48 +143,183 @@ func CreateOrder(c *fiber.Ctx) error {
 		Telephone:      telephone,
 		Address:        address,
 		Note:           note,
-		TotalPrice:     totalPrice,
 		ProofPayment:   proofPaymentURL,
 		StatusShipping: "awaitingPayment",
 	}
 
-	if err := database.DB.Create(&order).Error; err != nil {
+	if err := placeOrder(&order, checks, voucherCode); err != nil {
+		_ = os.Remove(savePath)
+		var stockErr *outOfStockError
+		if errors.As(err, &stockErr) {
+			return c.Status(400).JSON(fiber.Map{"error": stockErr.Error()})
+		}
+		var heldErr *reservedError
+		if errors.As(err, &heldErr) {
+			return c.Status(409).JSON(fiber.Map{"error": heldErr.Error(), "availability": availabilityReserved})
+		}
+		var offerErr *offerError
+		if errors.As(err, &offerErr) {
+			return c.Status(409).JSON(fiber.Map{"error": offerErr.Error()})
+		}
+		var saleErr *flashSaleError
+		if errors.As(err, &saleErr) {
+			return c.Status(409).JSON(fiber.Map{"error": saleErr.Error()})
+		}
+		var voucherErr *voucherError
+		if errors.As(err, &voucherErr) {
+			return c.Status(400).JSON(fiber.Map{"error": voucherErr.Error()})
+		}
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to create order"})
 	}
 
-	for _, item := range cartItems {
-		orderItem := models.OrderItem{
-			OrderID:   order.ID,
-			ProductID: item.ProductID,
-			Quantity:  item.Quantity,
-			Price:     item.Price,
-			SubTotal:  item.Price * float64(item.Quantity),
+	recordAudit(c, "order.create", "order", order.ID, nil, order)
+
+	return c.Status(201).JSON(fiber.Map{
+		"message":  "Order created successfully",
+		"order_id": order.ID,
+	})
+}
+
+// placeOrder creates the order for checked cart items, which must be free of
+// warnings, in one transaction: it redeems the voucher, holds and takes the
+// stock, snapshots the items and removes them from the cart. The order
+// brings the buyer, shop and delivery details; TotalPrice is filled in.
+func placeOrder(order *models.Order, checks []cartCheck, voucherCode string) error {
+	order.TotalPrice = 0
+	for _, check := range checks {
+		order.TotalPrice += check.Quote.Price * float64(check.Item.Quantity)
+	}
+
+	categories, err := loadCategories()
+	if err != nil {
+		return err
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		// The voucher is counted in the same transaction as the order, so a
+		// failed checkout does not use it up.
+		var voucher *models.Voucher
+		if voucherCode != "" {
+			redeemed, discount, err := redeemVoucher(tx, voucherCode, order.UserID, order.ShopID, checks, time.Now())
+			if err != nil {
+				return err
+			}
+			voucher = redeemed
+			order.VoucherID = &voucher.ID
+			order.VoucherCode = voucher.Code
+			order.VoucherDiscount = discount
+			order.TotalPrice -= discount
 		}
 
-		var product models.Product
-		if err := database.DB.First(&product, item.ProductID).Error; err == nil {
-			product.Stock -= item.Quantity
-			
-			if product.Stock <= 0 {
-				product.Stock = 0 
+		if err := tx.Create(order).Error; err != nil {
+			return err
+		}
+		if voucher != nil {
+			redemption := models.VoucherRedemption{
+				VoucherID: voucher.ID,
+				UserID:    order.UserID,
+				OrderID:   order.ID,
+				Discount:  order.VoucherDiscount,
 			}
-			
-			if err := database.DB.Save(&product).Error; err != nil {
-				fmt.Println("Error saving product stock:", err)
+			if err := tx.Create(&redemption).Error; err != nil {
+				return err
 			}
 		}
 
-		database.DB.Create(&orderItem)
-	}
+		for _, check := range checks {
+			item := check.Item
+			if err := holdStock(tx, order.UserID, item, time.Now()); err != nil {
+				return err
+			}
+			if err := decrementStock(tx, item); err != nil {
+				return err
+			}
 
-	database.DB.Where("id IN ?", cartIDs).Delete(&models.CartItem{})
+			quote := check.Quote
+			orderItem := models.OrderItem{
+				OrderID:       order.ID,
+				ProductID:     item.ProductID,
+				VariantID:     item.VariantID,
+				Quantity:      item.Quantity,
+				Price:         quote.Price,
+				OriginalPrice: quote.OriginalPrice,
+				SubTotal:      quote.Price * float64(item.Quantity),
+			}
+			if quote.Discount != nil {
+				orderItem.DiscountID = &quote.Discount.ID
+			}
+			if quote.AuctionID != nil {
+				if err := claimAuction(tx, *quote.AuctionID, order.ID); err != nil {
+					return err
+				}
+			}
+			if quote.OfferID != nil {
+				orderItem.OfferID = quote.OfferID
+				if err := claimOffer(tx, *quote.OfferID, order.ID, time.Now()); err != nil {
+					return err
+				}
+			}
+			if quote.FlashClaimID != nil {
+				orderItem.FlashClaimID = quote.FlashClaimID
+				if err := purchaseFlashClaim(tx, *quote.FlashClaimID, order.ID, time.Now()); err != nil {
+					return err
+				}
+			}
+			snapshotOrderItem(&orderItem, item, categories)
+			if err := tx.Create(&orderItem).Error; err != nil {
+				return err
+			}
+		}
 
-	return c.Status(201).JSON(fiber.Map{
-		"message":  "Order created successfully",
-		"order_id": order.ID,
+		orderedIDs := make([]uint, 0, len(checks))
+		for _, check := range checks {
+			orderedIDs = append(orderedIDs, check.Item.ID)
+		}
+		if err := releaseReservations(tx, orderedIDs); err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", orderedIDs).Delete(&models.CartItem{}).Error
 	})
 }
 
+// UploadProofPayment attaches the payment proof to an order of the buyer
+// that was created without one, such as the order of a won auction.
+func UploadProofPayment(c *fiber.Ctx) error {
+	var order models.Order
+	if err := database.DB.First(&order, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Order not found"})
+	}
+	if order.UserID != sessionUserID(c) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Kamu tidak memiliki izin untuk mengubah pesanan ini"})
+	}
+	if order.StatusShipping != "awaitingPayment" {
+		return c.Status(409).JSON(fiber.Map{"error": "The payment of this order has already been reviewed"})
+	}
+
+	file, err := c.FormFile("proof_payment")
+	if file == nil || err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Proof of payment image is required"})
+	}
+	if err := validateProductImage(file.Filename, file.Size); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	os.MkdirAll("./assets/payments", os.ModePerm)
+	filename := strconv.FormatInt(time.Now().UnixNano(), 10) + strings.ToLower(filepath.Ext(file.Filename))
+	if err := c.SaveFile(file, "./assets/payments/"+filename); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save proof of payment image"})
+	}
+
+	before := order
+	order.ProofPayment = "http://127.0.0.1:3000/assets/payments/" + filename
+	if err := database.DB.Model(&order).Update("proof_payment", order.ProofPayment).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save proof of payment image"})
+	}
+	recordAudit(c, "order.proof_payment", "order", order.ID, before, order)
+
+	return c.JSON(fiber.Map{"message": "Proof of payment uploaded", "proof_payment": order.ProofPayment})
+}
+
 func GetAllOrder(c *fiber.Ctx) error {
 	user := c.Locals("user").(*jwt.Token)
 	claims := user.Claims.(jwt.MapClaims)
@@ -226,6 +411,8 @@ func GetOrderDetail(c *fiber.Ctx) error {
 		StatusShipping string    `json:"status_shipping"`
 		CancelBy       *string   `json:"cancel_by"`
 		TotalPrice     float64   `json:"total_price"`
+		VoucherCode     string    `json:"voucher_code"`
+		VoucherDiscount float64   `json:"voucher_discount"`
 		ProofPayment   string    `json:"proof_payment"`
 	}
 
@@ -241,6 +428,8 @@ func GetOrderDetail(c *fiber.Ctx) error {
 	"o.created_at, " +
 	"o.status_shipping, " +
 	"o.total_price, " +
+	"o.voucher_code, " +
+	"o.voucher_discount, " +
 	"o.proof_payment, " +
 	"o.cancel_by " +
 	"FROM `order` o " +
@@ -252,26 +441,29 @@ if err := database.DB.Raw(query, orderID).Scan(&order).Error; err != nil {
 }
 
 	var items []struct {
-		Name     string  `json:"name"`
-		Label    string  `json:"label"`
-		Quantity int     `json:"quantity"`
-		Price    float64 `json:"price"`
-		Image    string  `json:"image"`
-	}
-
-	queryItems := "SELECT " +
-	"p.name, " +
-	"p.label, " +
-	"oi.quantity, " +
-	"oi.price, " +
-	"p.image " +
-	"FROM orderitem oi " +
-	"JOIN products p ON oi.product_id = p.id " +
-	"WHERE oi.order_id = ?"
-
-if err := database.DB.Raw(queryItems, orderID).Scan(&items).Error; err != nil {
-	return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch order items"})
-}
+		ProductID     uint                              `json:"product_id"`
+		VariantID     *uint                             `json:"variant_id"`
+		Name          string                            `json:"name"`
+		Label         string                            `json:"label"`
+		Variant       string                            `json:"variant"`
+		SKU           string                            `json:"sku"`
+		Category      string                            `json:"category"`
+		Attributes    *models.ProductSnapshotAttributes `json:"attributes" gorm:"serializer:json"`
+		Quantity      int                               `json:"quantity"`
+		Price         float64                           `json:"price"`
+		// OriginalPrice is the regular price when Price was discounted.
+		OriginalPrice float64                           `json:"original_price"`
+		Image         string                            `json:"image"`
+	}
+
+	if err := database.DB.Table("orderitem").
+		Select("product_id, variant_id, product_name AS name, product_label AS label, variant_label AS variant, " +
+			"sku, category_name AS category, attributes, quantity, price, original_price, product_image AS image").
+		Where("order_id = ?", orderID).
+		Order("id").
+		Scan(&items).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch order items"})
+	}
 
 	return c.JSON(fiber.Map{
 		"order":       order,
@@ -293,34 +485,232 @@ func CancelOrder(c *fiber.Ctx) error {
 	if err := database.DB.First(&order, orderID).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "Order not found"})
 	}
+	before := order
 	order.StatusShipping = "cancelPending"
 	order.CancelBy = &body.CancelRole
 	if err := database.DB.Save(&order).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to cancel order"})
 	}
+	recordAudit(c, "order.cancel_request", "order", order.ID, before, order)
 	return c.JSON(fiber.Map{"message": "Order cancellation requested"})
 }
 
 func RejectCancel(c *fiber.Ctx) error {
 	orderID := c.Params("id")
+	var before models.Order
+	if err := database.DB.First(&before, orderID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Order not found"})
+	}
 	if err := database.DB.Model(&models.Order{}).
 		Where("id = ? AND status_shipping = ?", orderID, "cancelPending").
 		Updates(map[string]interface{}{"status_shipping": "prepared", "cancel_by": nil}).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to reject cancel"})
 	}
+	auditOrderUpdate(c, "order.cancel_reject", before)
 	return c.JSON(fiber.Map{"message": "Order cancel rejected"})
 }
 
+// AcceptCancel cancels an order whose cancellation was requested and gives
+// back what checkout took, like RefundOrder does.
 func AcceptCancel(c *fiber.Ctx) error {
 	orderID := c.Params("id")
-	if err := database.DB.Model(&models.Order{}).
-		Where("id = ?", orderID).
-		Update("status_shipping", "cancelled").Error; err != nil {
+	var before models.Order
+	if err := database.DB.Preload("OrderItems").First(&before, orderID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Order not found"})
+	}
+	order := before
+	before.OrderItems = nil
+
+	var flashClaims []models.FlashSaleClaim
+	err := database.DB.Transaction(func(tx *gorm.DB) (err error) {
+		result := tx.Model(&models.Order{}).
+			Where("id = ? AND status_shipping = ?", order.ID, "cancelPending").
+			Update("status_shipping", "cancelled")
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errOrderChanged
+		}
+		flashClaims, err = releaseOrder(tx, order, true)
+		return err
+	})
+	if errors.Is(err, errOrderChanged) {
+		return c.Status(409).JSON(fiber.Map{"error": "The order is not waiting for a cancellation anymore, please reload it"})
+	}
+	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to accept cancel"})
 	}
+	for _, claim := range flashClaims {
+		flashSales.Release(claim.FlashSaleID, claim.UserID, claim.Quantity)
+	}
+	auditOrderUpdate(c, "order.cancel_accept", before)
 	return c.JSON(fiber.Map{"message": "Order cancelled"})
 }
 
+// GetAllOrdersAdmin lists the orders of every shop for staff roles, optionally
+// filtered by ?status=.
+func GetAllOrdersAdmin(c *fiber.Ctx) error {
+	var orders []struct {
+		ID             uint      `json:"order_id"`
+		UserID         uint      `json:"user_id"`
+		ShopID         uint      `json:"shop_id"`
+		ShopName       string    `json:"shop_name"`
+		Recipient      string    `json:"recipient"`
+		CreatedAt      time.Time `json:"created_at"`
+		TotalPrice     float64   `json:"total_price"`
+		StatusShipping string    `json:"status_shipping"`
+		CancelBy       *string   `json:"cancel_by"`
+	}
+
+	query := database.DB.Table("`order` o").
+		Select("o.id, o.user_id, o.shop_id, s.shop_name, o.recipient, o.created_at, o.total_price, o.status_shipping, o.cancel_by").
+		Joins("JOIN shops s ON o.shop_id = s.id").
+		Order("o.created_at DESC")
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("o.status_shipping = ?", status)
+	}
+
+	if err := query.Scan(&orders).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch orders"})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   orders,
+	})
+}
+
+// RefundOrder lets staff cancel an order directly, for example after a
+// payment dispute, without the buyer/seller cancel handshake. The order is
+// marked as refunded and what checkout took is given back in the same
+// transaction: the voucher use, agreed offer prices and flash sale units,
+// and the stock when the order was not shipped yet.
+func RefundOrder(c *fiber.Ctx) error {
+	orderID := c.Params("id")
+	role, _ := c.Locals("role").(string)
+
+	var order models.Order
+	if err := database.DB.Preload("OrderItems").First(&order, orderID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Order not found"})
+	}
+
+	if order.StatusShipping == "cancelled" {
+		return c.Status(400).JSON(fiber.Map{"error": "Order is already cancelled"})
+	}
+	before := order
+	before.OrderItems = nil
+
+	now := time.Now()
+	staffID := sessionUserID(c)
+	restock := order.StatusShipping != "shipped" && order.StatusShipping != "delivered"
+	var flashClaims []models.FlashSaleClaim
+	err := database.DB.Transaction(func(tx *gorm.DB) (err error) {
+		result := tx.Model(&models.Order{}).
+			Where("id = ? AND status_shipping = ?", order.ID, order.StatusShipping).
+			Updates(map[string]interface{}{
+				"status_shipping": "cancelled",
+				"cancel_by":       role,
+				"refunded_at":     now,
+				"refunded_by":     staffID,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errOrderChanged
+		}
+
+		flashClaims, err = releaseOrder(tx, order, restock)
+		return err
+	})
+	if errors.Is(err, errOrderChanged) {
+		return c.Status(409).JSON(fiber.Map{"error": "The order was changed meanwhile, please reload it"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to refund order"})
+	}
+	for _, claim := range flashClaims {
+		flashSales.Release(claim.FlashSaleID, claim.UserID, claim.Quantity)
+	}
+
+	auditOrderUpdate(c, "order.refund", before)
+
+	return c.JSON(fiber.Map{
+		"message":        "Order cancelled and marked as refunded",
+		"refunded_at":    now,
+		"stock_restored": restock,
+	})
+}
+
+var errOrderChanged = errors.New("order changed")
+
+// releaseOrder gives back what checkout took for a cancelled order: the
+// voucher use, agreed offer prices, the flash sale claims and, when restock
+// is set, the stock of its items. The released claims are returned so the
+// caller can hand their units back to flashSales once the transaction is
+// committed.
+func releaseOrder(tx *gorm.DB, order models.Order, restock bool) ([]models.FlashSaleClaim, error) {
+	if restock {
+		for _, item := range order.OrderItems {
+			if err := restoreStock(tx, item); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if order.VoucherID != nil {
+		if err := tx.Model(&models.Voucher{}).Where("id = ? AND used_count > 0", *order.VoucherID).
+			Update("used_count", gorm.Expr("used_count - 1")).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Where("order_id = ?", order.ID).Delete(&models.VoucherRedemption{}).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	// Agreed prices can be used again until they expire.
+	if err := tx.Model(&models.Offer{}).
+		Where("order_id = ? AND status = ?", order.ID, models.OfferPurchased).
+		Updates(map[string]interface{}{"status": models.OfferAccepted, "order_id": nil}).Error; err != nil {
+		return nil, err
+	}
+
+	var claims []models.FlashSaleClaim
+	if err := tx.Where("order_id = ? AND status = ?", order.ID, models.FlashClaimPurchased).
+		Find(&claims).Error; err != nil {
+		return nil, err
+	}
+	if len(claims) > 0 {
+		if err := tx.Model(&models.FlashSaleClaim{}).
+			Where("order_id = ? AND status = ?", order.ID, models.FlashClaimPurchased).
+			Update("status", models.FlashClaimReleased).Error; err != nil {
+			return nil, err
+		}
+	}
+	return claims, nil
+}
+
+// restoreStock puts the quantity of an order item back on its variant, or on
+// the product when it has none. Items of deleted products or variants are
+// skipped.
+func restoreStock(tx *gorm.DB, item models.OrderItem) error {
+	if item.VariantID != nil {
+		if err := tx.Model(&models.ProductVariant{}).Where("id = ?", *item.VariantID).
+			Update("stock", gorm.Expr("stock + ?", item.Quantity)).Error; err != nil {
+			return err
+		}
+		return syncProductStock(tx, item.ProductID)
+	}
+
+	if err := tx.Model(&models.Product{}).Where("id = ?", item.ProductID).
+		Update("stock", gorm.Expr("stock + ?", item.Quantity)).Error; err != nil {
+		return err
+	}
+	return refreshSoldOut(tx, item.ProductID)
+}
+
 func GetAllSales(c *fiber.Ctx) error {
     shopID := c.Params("shop_id")
 
@@ -338,6 +728,7 @@ func GetAllSales(c *fiber.Ctx) error {
         TotalPrice     float64   `json:"total_price"`
         StatusShipping string    `json:"status_shipping"`
         ProductCount   int       `json:"product_count"`
+        Items          []orderItemSummary `json:"items" gorm:"-"`
     }
 
     query := "SELECT " +
@@ -360,6 +751,24 @@ func GetAllSales(c *fiber.Ctx) error {
         return c.Status(500).JSON(fiber.Map{"error": err.Error()})
     }
 
+    // Items come from the checkout snapshot, not from the live products.
+    orderIDs := make([]uint, 0, len(sales))
+    for _, sale := range sales {
+        orderIDs = append(orderIDs, sale.ID)
+    }
+    items, err := orderItemSummaries(orderIDs)
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch order items"})
+    }
+    for i := range sales {
+        sales[i].Items = []orderItemSummary{}
+        for _, item := range items {
+            if item.OrderID == sales[i].ID {
+                sales[i].Items = append(sales[i].Items, item)
+            }
+        }
+    }
+
     return c.JSON(fiber.Map{
         "status": "success",
         "data":   sales,
@@ -375,6 +784,10 @@ func AcceptPayment(c *fiber.Ctx) error {
 	if err := c.BodyParser(&body); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
 	}
+	var before models.Order
+	if err := database.DB.First(&before, orderID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Order not found"})
+	}
 	update := map[string]interface{}{}
 	if body.Status {
 		update["status_shipping"] = "prepared"
@@ -385,6 +798,7 @@ func AcceptPayment(c *fiber.Ctx) error {
 	if err := database.DB.Model(&models.Order{}).Where("id = ?", orderID).Updates(update).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to update payment"})
 	}
+	auditOrderUpdate(c, "order.payment_review", before)
 	return c.JSON(fiber.Map{"message": "Payment status updated"})
 }
 
@@ -400,10 +814,25 @@ func ChangeStatusShipping(c *fiber.Ctx) error {
 	if !valid[body.StatusShipping] {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid status"})
 	}
+	var before models.Order
+	if err := database.DB.First(&before, orderID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Order not found"})
+	}
 	if err := database.DB.Model(&models.Order{}).
 		Where("id = ?", orderID).
 		Update("status_shipping", body.StatusShipping).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to update shipping status"})
 	}
+	auditOrderUpdate(c, "order.status_change", before)
 	return c.JSON(fiber.Map{"message": "Status updated"})
 }
+
+// auditOrderUpdate reloads an order changed through an in-place update and
+// records the difference to before.
+func auditOrderUpdate(c *fiber.Ctx, action string, before models.Order) {
+	var after models.Order
+	if err := database.DB.First(&after, before.ID).Error; err != nil {
+		return
+	}
+	recordAudit(c, action, "order", before.ID, before, after)
+}
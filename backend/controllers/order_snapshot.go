@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"finpro/database"
+	"finpro/models"
+)
+
+// orderItemSummary is the short form of an order item used in order lists.
+type orderItemSummary struct {
+	OrderID       uint    `json:"-"`
+	ProductID     uint    `json:"product_id"`
+	Name          string  `json:"name"`
+	Variant       string  `json:"variant"`
+	Quantity      int     `json:"quantity"`
+	Price         float64 `json:"price"`
+	OriginalPrice float64 `json:"original_price"`
+	Image         string  `json:"image"`
+}
+
+func orderItemSummaries(orderIDs []uint) ([]orderItemSummary, error) {
+	items := []orderItemSummary{}
+	if len(orderIDs) == 0 {
+		return items, nil
+	}
+	err := database.DB.Table("orderitem").
+		Select("order_id, product_id, product_name AS name, variant_label AS variant, quantity, price, original_price, product_image AS image").
+		Where("order_id IN ?", orderIDs).
+		Order("id").
+		Scan(&items).Error
+	return items, err
+}
+
+// snapshotOrderItem copies the product data shown in order views onto the
+// order item. The product and variant of the cart item must be loaded.
+func snapshotOrderItem(orderItem *models.OrderItem, item models.CartItem, categories []models.Category) {
+	product := item.Product
+
+	orderItem.ProductName = product.Name
+	orderItem.ProductLabel = product.Label
+	orderItem.ProductImage = product.Image
+	orderItem.Attributes = &models.ProductSnapshotAttributes{
+		Condition:    product.Condition,
+		Size:         product.Size,
+		Brand:        product.Brand,
+		Material:     product.Material,
+		Measurements: product.Measurements,
+	}
+
+	if product.CategoryID != nil {
+		if path := categoryPath(categories, *product.CategoryID); len(path) > 0 {
+			orderItem.CategoryName = path[len(path)-1].Name
+		}
+	}
+
+	if item.Variant != nil {
+		orderItem.VariantLabel = item.Variant.Label()
+		orderItem.SKU = item.Variant.SKU
+		if item.Variant.Size != "" {
+			orderItem.Attributes.Size = item.Variant.Size
+		}
+	}
+}
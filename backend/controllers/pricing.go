@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"time"
+
+	"finpro/database"
+	"finpro/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// effectivePriceSQL is the price buyers pay for a product, the SQL twin of
+// priceBook.quote without variants. It takes the current time twice.
+const effectivePriceSQL = "LEAST(products.price, COALESCE((" +
+	"SELECT MIN(CASE WHEN d.sale_price IS NOT NULL THEN LEAST(d.sale_price, products.price) " +
+	"ELSE ROUND(products.price * (100 - d.percent) / 100) END) " +
+	"FROM discounts d WHERE d.starts_at <= ? AND d.ends_at > ? " +
+	"AND (d.product_id = products.id OR (d.product_id IS NULL AND d.shop_id = products.shop_id))" +
+	"), products.price))"
+
+func effectivePriceOrder(now time.Time, direction string) clause.OrderBy {
+	return clause.OrderBy{Expression: clause.Expr{
+		SQL:                effectivePriceSQL + " " + direction + ", products.id " + direction,
+		Vars:               []interface{}{now, now},
+		WithoutParentheses: true,
+	}}
+}
+
+// priceBook holds the discounts running at one moment for a set of products,
+// so every product of a listing, cart or order is priced the same way.
+type priceBook struct {
+	now       time.Time
+	byProduct map[uint][]models.Discount
+	byShop    map[uint][]models.Discount
+}
+
+func loadPriceBook(products []models.Product, now time.Time) (*priceBook, error) {
+	book := &priceBook{
+		now:       now,
+		byProduct: map[uint][]models.Discount{},
+		byShop:    map[uint][]models.Discount{},
+	}
+	if len(products) == 0 {
+		return book, nil
+	}
+
+	productIDs := make([]uint, 0, len(products))
+	shopIDs := make([]uint, 0, len(products))
+	for _, product := range products {
+		productIDs = append(productIDs, product.ID)
+		shopIDs = append(shopIDs, product.ShopID)
+	}
+
+	var discounts []models.Discount
+	if err := database.DB.
+		Where("starts_at <= ? AND ends_at > ?", now, now).
+		Where("product_id IN ? OR (product_id IS NULL AND shop_id IN ?)", productIDs, shopIDs).
+		Find(&discounts).Error; err != nil {
+		return nil, err
+	}
+
+	for _, discount := range discounts {
+		if discount.ProductID != nil {
+			book.byProduct[*discount.ProductID] = append(book.byProduct[*discount.ProductID], discount)
+		} else {
+			book.byShop[discount.ShopID] = append(book.byShop[discount.ShopID], discount)
+		}
+	}
+	return book, nil
+}
+
+// quote prices one unit of a product, or of one of its variants. Discounts
+// do not stack: the lowest discounted price wins.
+func (b *priceBook) quote(product *models.Product, variant *models.ProductVariant) models.PriceQuote {
+	original := product.Price
+	if variant != nil {
+		original = variant.UnitPrice(product)
+	}
+
+	quote := models.PriceQuote{OriginalPrice: original, Price: original}
+	candidates := append(append([]models.Discount{}, b.byProduct[product.ID]...), b.byShop[product.ShopID]...)
+	for i := range candidates {
+		discount := candidates[i]
+		if !discount.ActiveAt(b.now) {
+			continue
+		}
+		if price := discount.Apply(original); price < quote.Price {
+			quote.Price = price
+			quote.Discount = &discount
+		}
+	}
+	return quote
+}
+
+// priceProducts fills in the price buyers pay for each product and variant.
+func priceProducts(products []models.Product, now time.Time) error {
+	book, err := loadPriceBook(products, now)
+	if err != nil {
+		return err
+	}
+
+	for i := range products {
+		product := &products[i]
+		quote := book.quote(product, nil)
+		product.FinalPrice = &quote.Price
+		product.Discount = quote.Discount
+		for j := range product.Variants {
+			variant := &product.Variants[j]
+			variantQuote := book.quote(product, variant)
+			variant.FinalPrice = &variantQuote.Price
+		}
+	}
+	return nil
+}
@@ -17,58 +17,48 @@ import (
 )
 
 func GetAllProducts(c *fiber.Ctx) error {
-	var products []models.Product
-	if err := database.DB.Find(&products).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"status": "error", "message": err.Error()})
-	}
-
-	if len(products) == 0 {
-		return c.Status(200).JSON(fiber.Map{"status": "success", "message": "No products found", "data": []models.Product{}})
+	q, err := parseProductQuery(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": err.Error()})
 	}
 
-	return c.JSON(fiber.Map{"status": "success", "data": products})
+	return listProducts(c, q, "No products found")
 }
 
 func GetProductByCategory(c *fiber.Ctx) error {
-	category := strings.Title(strings.ToLower(c.Params("category")))
-
-	if category != "Fashion" && category != "Others" {
-		return c.Status(400).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Invalid category. Allowed: Fashion, Others",
-		})
-	}
-
-	var products []models.Product
-	if err := database.DB.Where("category = ?", category).Find(&products).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	q, err := parseProductQuery(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": err.Error()})
 	}
 
-	if len(products) == 0 {
-		return c.Status(200).JSON(fiber.Map{"status": "success", "message": "No products in this category", "data": []models.Product{}})
+	categoryIDs, err := categoryFilter(c.Params("category"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": err.Error()})
 	}
+	q.CategoryIDs = categoryIDs
 
-	return c.JSON(fiber.Map{"status": "success", "data": products})
+	return listProducts(c, q, "No products in this category")
 }
 
 func SearchProduct(c *fiber.Ctx) error {
-	query := c.Query("q")
-	if strings.TrimSpace(query) == "" {
+	q, err := parseProductQuery(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	if q.Search == "" {
 		return c.Status(400).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Search query cannot be empty",
 		})
 	}
 
-	var products []models.Product
-	if err := database.DB.Where("name LIKE ?", "%"+query+"%").Find(&products).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"status": "error", "message": err.Error()})
-	}
-
-	return c.JSON(fiber.Map{"status": "success", "data": products})
+	return listProducts(c, q, "No products match your search")
 }
 
-func AddProduct(c *fiber.Ctx) error {
+// GetMyProducts lists the products of the caller's shop in every status,
+// with the same parameters as GetAllProducts plus status.
+func GetMyProducts(c *fiber.Ctx) error {
 	userToken := c.Locals("user").(*jwt.Token)
 	claims := userToken.Claims.(jwt.MapClaims)
 	userID := int(claims["id"].(float64))
@@ -80,74 +70,72 @@ func AddProduct(c *fiber.Ctx) error {
 		})
 	}
 
-	price, err := strconv.ParseFloat(c.FormValue("price"), 64)
-	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid price"})
-	}
-
-	stock, err := strconv.Atoi(c.FormValue("stock"))
+	q, err := parseProductQuery(c)
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid stock"})
-	}
-
-	name := c.FormValue("name")
-	if name == "" {
-		return c.Status(400).JSON(fiber.Map{"error": "Product name is required"})
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": err.Error()})
 	}
+	q.ownShopID = shop.ID
 
-	category := c.FormValue("category")
-	if category != "Fashion" && category != "Others" {
-		return c.Status(400).JSON(fiber.Map{"error": "Category must be 'Fashion' or 'Others'"})
+	q.Statuses = parseListParam(strings.ToLower(c.Query("status")))
+	for _, status := range q.Statuses {
+		if !containsString(models.ProductStatuses, status) {
+			return c.Status(400).JSON(fiber.Map{
+				"status":  "error",
+				"message": "status must be one of " + strings.Join(models.ProductStatuses, ", "),
+			})
+		}
 	}
 
-	label := c.FormValue("label")
-	description := c.FormValue("description")
+	return listProducts(c, q, "You have no products yet")
+}
 
-	file, err := c.FormFile("image")
-	if file == nil || err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Product image is required"})
-	}
+func AddProduct(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*jwt.Token)
+	claims := userToken.Claims.(jwt.MapClaims)
+	userID := int(claims["id"].(float64))
 
-	if file.Size > 1*1024*1024 {
-		return c.Status(400).JSON(fiber.Map{"error": "Image size must be less than 1MB"})
+	var shop models.Shop
+	if err := database.DB.Where("user_id = ?", userID).First(&shop).Error; err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Seller belum memiliki toko, buat toko terlebih dahulu",
+		})
 	}
 
-	ext := strings.ToLower(filepath.Ext(file.Filename))
-	allowedExt := map[string]bool{
-		".png":  true,
-		".jpg":  true,
-		".jpeg": true,
-		".webp": true,
-	}
-	if !allowedExt[ext] {
-		return c.Status(400).JSON(fiber.Map{"error": "Image must be PNG, JPG, JPEG, or WEBP format"})
+	product, err := newProductFromForm(requestForm(c), shop.ID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	os.MkdirAll("./assets/products", os.ModePerm)
+	// Drafts may be saved without an image and get one before publishing.
+	file, err := c.FormFile("image")
+	if file == nil || err != nil {
+		if productNeedsImage(&product) {
+			return c.Status(400).JSON(fiber.Map{"error": "Product image is required"})
+		}
+	} else {
+		if err := validateProductImage(file.Filename, file.Size); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		ext := strings.ToLower(filepath.Ext(file.Filename))
 
-	filename := strconv.FormatInt(time.Now().UnixNano(), 10) + ext
-	savePath := "./assets/products/" + filename
-	if err := c.SaveFile(file, savePath); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to save product image"})
-	}
+		os.MkdirAll("./assets/products", os.ModePerm)
 
-	imageURL := "http://127.0.0.1:3000/assets/products/" + filename
+		filename := strconv.FormatInt(time.Now().UnixNano(), 10) + ext
+		savePath := "./assets/products/" + filename
+		if err := c.SaveFile(file, savePath); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to save product image"})
+		}
 
-	product := models.Product{
-		ShopID:      shop.ID,
-		Name:        name,
-		Category:    category,
-		Label:       label,
-		Description: description,
-		Image:       imageURL,
-		Price:       price,
-		Stock:       stock,
+		product.Image = "http://127.0.0.1:3000/assets/products/" + filename
 	}
 
-	if err := database.DB.Create(&product).Error; err != nil {
+	if err := database.DB.Omit("Category").Create(&product).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
+	recordAudit(c, "product.create", "product", product.ID, nil, product)
+	indexProducts(product.ID)
+
 	return c.Status(201).JSON(fiber.Map{
 		"status":  "success",
 		"message": "Product added successfully",
@@ -158,11 +146,7 @@ func AddProduct(c *fiber.Ctx) error {
 func DeleteProduct(c *fiber.Ctx) error {
 	userToken := c.Locals("user").(*jwt.Token)
 	claims := userToken.Claims.(jwt.MapClaims)
-	role := claims["role"].(string)
-
-	if role != "seller" {
-		return c.Status(403).JSON(fiber.Map{"error": "Only sellers can delete products"})
-	}
+	userID := int(claims["id"].(float64))
 
 	id := c.Params("id")
 	var product models.Product
@@ -171,36 +155,164 @@ func DeleteProduct(c *fiber.Ctx) error {
 		return c.Status(404).JSON(fiber.Map{"error": "Product not found"})
 	}
 
+	var shop models.Shop
+	if err := database.DB.Where("user_id = ?", userID).First(&shop).Error; err != nil || product.ShopID != shop.ID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Kamu tidak memiliki izin untuk menghapus produk ini",
+		})
+	}
+
+	if auctioned, err := productAuctioned(database.DB, product.ID); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to check auctions"})
+	} else if auctioned {
+		return c.Status(409).JSON(fiber.Map{"error": "Products being auctioned cannot be deleted"})
+	}
+
 	var orderItemCount int64
 	// Cek apakah ada OrderItem yang merujuk ProductID ini
 	if err := database.DB.Model(&models.OrderItem{}).Where("product_id = ?", id).Count(&orderItemCount).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to check order history"})
 	}
 
-	if orderItemCount > 0 {
-		return c.Status(400).JSON(fiber.Map{"error": "Product cannot be deleted because it is linked to existing orders."})
-	}
-    
-	return database.DB.Transaction(func(tx *gorm.DB) error {
+	// Products that were ordered are only soft deleted, so past orders can
+	// still show them. The others are removed together with their image.
+	softDelete := orderItemCount > 0
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
 		if err := tx.Where("product_id = ?", id).Delete(&models.CartItem{}).Error; err != nil {
-			fmt.Printf("⚠️ Failed to delete associated cart items: %v\n", err)
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to clean up cart items"})
+			return err
 		}
 
-		if product.Image != "" && !strings.Contains(product.Image, "pravatar.cc") {
-			oldPath := "." + strings.TrimPrefix(product.Image, "http://127.0.0.1:3000")
-			if err := os.Remove(oldPath); err != nil {
-				fmt.Printf("⚠️ Failed to delete image file: %v\n", err)
-			}
+		if softDelete {
+			return tx.Delete(&product).Error
 		}
 
-		if err := tx.Delete(&product).Error; err != nil {
-			fmt.Printf("⚠️ Failed to delete product from DB: %v\n", err)
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to delete product"})
+		if err := tx.Where("product_id = ?", id).Delete(&models.ProductVariant{}).Error; err != nil {
+			return err
 		}
+		return tx.Unscoped().Delete(&product).Error
+	})
+	if err != nil {
+		fmt.Printf("⚠️ Failed to delete product from DB: %v\n", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete product"})
+	}
+
+	if !softDelete {
+		removeProductImage(product.Image)
+	}
 
-		return c.JSON(fiber.Map{"status": "success", "message": "Product deleted successfully"})
+	recordAudit(c, "product.delete", "product", product.ID, product, nil)
+	unindexProducts(product.ID)
+
+	return c.JSON(fiber.Map{"status": "success", "message": "Product deleted successfully"})
+}
+
+// ArchiveProduct hides a product from buyers without deleting it. It is
+// removed from every cart.
+func ArchiveProduct(c *fiber.Ctx) error {
+	product, status, err := ownedProduct(c, c.Params("id"))
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	if product.ArchivedAt != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Product is already archived"})
+	}
+	if auctioned, err := productAuctioned(database.DB, product.ID); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to check auctions"})
+	} else if auctioned {
+		return c.Status(409).JSON(fiber.Map{"error": "Products being auctioned cannot be archived"})
+	}
+	before := *product
+
+	now := time.Now()
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("product_id = ?", product.ID).Delete(&models.CartItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(product).Update("archived_at", now).Error
 	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to archive product"})
+	}
+
+	recordAudit(c, "product.archive", "product", product.ID, before, product)
+	unindexProducts(product.ID)
+
+	return c.JSON(fiber.Map{"status": "success", "message": "Product archived successfully", "data": product})
+}
+
+func UnarchiveProduct(c *fiber.Ctx) error {
+	product, status, err := ownedProduct(c, c.Params("id"))
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	if product.ArchivedAt == nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Product is not archived"})
+	}
+	before := *product
+
+	if err := database.DB.Model(product).Update("archived_at", nil).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to restore product"})
+	}
+
+	recordAudit(c, "product.unarchive", "product", product.ID, before, product)
+	indexProducts(product.ID)
+
+	return c.JSON(fiber.Map{"status": "success", "message": "Product restored successfully", "data": product})
+}
+
+// GetArchivedProducts lists the archived products of the caller's shop.
+func GetArchivedProducts(c *fiber.Ctx) error {
+	userToken := c.Locals("user").(*jwt.Token)
+	claims := userToken.Claims.(jwt.MapClaims)
+	userID := int(claims["id"].(float64))
+
+	var shop models.Shop
+	if err := database.DB.Where("user_id = ?", userID).First(&shop).Error; err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Seller belum memiliki toko, buat toko terlebih dahulu",
+		})
+	}
+
+	products := []models.Product{}
+	if err := database.DB.Preload("Category").
+		Where("shop_id = ? AND archived_at IS NOT NULL", shop.ID).
+		Order("archived_at DESC").
+		Find(&products).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch products"})
+	}
+
+	return c.JSON(fiber.Map{"status": "success", "data": products})
+}
+
+// removeProductImage deletes an uploaded product image. Call it only after
+// the database change that drops the last reference has been committed.
+// Images still shown by past orders are kept.
+func removeProductImage(image string) {
+	if image == "" || strings.Contains(image, "pravatar.cc") {
+		return
+	}
+	var orderItemCount int64
+	if err := database.DB.Model(&models.OrderItem{}).Where("product_image = ?", image).Count(&orderItemCount).Error; err != nil || orderItemCount > 0 {
+		return
+	}
+	oldPath := "." + strings.TrimPrefix(image, "http://127.0.0.1:3000")
+	if err := os.Remove(oldPath); err != nil {
+		fmt.Printf("⚠️ Failed to delete image file: %v\n", err)
+	}
+}
+
+// productCategoryInput reads the category of a product form, by category_id
+// or, for older clients, a category slug. It returns nil when none was sent.
+func productCategoryInput(form formValue) (*models.Category, error) {
+	value := form("category_id")
+	if value == "" {
+		value = form("category")
+	}
+	if value == "" {
+		return nil, nil
+	}
+	return findCategory(value)
 }
 
 func GetDetailProduct(c *fiber.Ctx) error {
@@ -210,25 +322,102 @@ func GetDetailProduct(c *fiber.Ctx) error {
 	}
 
 	var product models.Product
-	if err := database.DB.Preload("Shop").First(&product, id).Error; err != nil {
+	if err := database.DB.Scopes(visibleProducts).Preload("Shop").Preload("Variants").First(&product, id).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "Product not found"})
 	}
 
+	if product.Variants == nil {
+		product.Variants = []models.ProductVariant{}
+	}
+
+	book, err := loadPriceBook([]models.Product{product}, time.Now())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch discounts"})
+	}
+	quote := book.quote(&product, nil)
+
+	// Stock held by buyers in checkout shows as reserved until it is sold
+	// or the hold expires.
+	reserved, err := reservedStock(database.DB, []uint{product.ID}, 0, time.Now())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch product"})
+	}
+	for i := range product.Variants {
+		variant := &product.Variants[i]
+		variantQuote := book.quote(&product, variant)
+		variant.FinalPrice = &variantQuote.Price
+		variant.Availability = availability(variant.Stock, reserved[cartStockKey(product.ID, &variant.ID)])
+	}
+	held := productReserved(reserved, product.ID)
+	availableStock := product.Stock - held
+	if availableStock < 0 {
+		availableStock = 0
+	}
+
+	categories, err := loadCategories()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch categories"})
+	}
+	var categoryName string
+	categoryPathList := []models.Category{}
+	if product.CategoryID != nil {
+		categoryPathList = categoryPath(categories, *product.CategoryID)
+		if len(categoryPathList) > 0 {
+			categoryName = categoryPathList[len(categoryPathList)-1].Name
+		}
+	}
+
 	responseMap := fiber.Map{
 		"id": product.ID,
 		"shop_id": product.ShopID,
 		"shop_name": product.Shop.ShopName, 
 		"name": product.Name,
-		"category": product.Category,
+		"category_id": product.CategoryID,
+		"category": categoryName,
+		"category_path": categoryPathList,
 		"label": product.Label,
 		"description": product.Description,
 		"image": product.Image,
 		"price": product.Price,
+		"original_price": quote.OriginalPrice,
+		"final_price": quote.Price,
+		"discount": quote.Discount,
 		"stock": product.Stock,
+		"available_stock": availableStock,
+		"availability": availability(product.Stock, held),
+		"condition": product.Condition,
+		"size": product.Size,
+		"brand": product.Brand,
+		"material": product.Material,
+		"measurements": product.Measurements,
+		"variants": product.Variants,
+		"listing_type": product.ListingType,
 		"created_at": product.CreatedAt,
 		"updated_at": product.UpdatedAt,
 	}
 
+	if product.ListingType == models.ListingAuction {
+		var auction models.Auction
+		if err := database.DB.Where("product_id = ? AND status IN ?", product.ID, []string{models.AuctionRunning, models.AuctionWon}).
+			Order("id DESC").First(&auction).Error; err == nil {
+			presentAuction(&auction, false)
+			responseMap["auction"] = auction
+		}
+	}
+
+	var flashSale models.FlashSale
+	if err := database.DB.Where("product_id = ? AND starts_at <= ? AND ends_at > ?", product.ID, time.Now(), time.Now()).
+		Order("id DESC").First(&flashSale).Error; err == nil {
+		presentFlashSale(&flashSale)
+		responseMap["flash_sale"] = flashSale
+	}
+
+	ratings, err := ratingSummaries(database.DB, "product_id", []uint{product.ID})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch product rating"})
+	}
+	responseMap["rating"] = ratings[product.ID]
+
 
 	return c.JSON(fiber.Map{"status": "success", "data": responseMap})
 }
@@ -261,8 +450,12 @@ func EditProduct(c *fiber.Ctx) error {
 		})
 	}
 
+	before := product
+	// Only the columns sent in the form are written, so stock sold by a
+	// checkout running meanwhile is not overwritten with the value read above.
+	columns := []string{}
+
 	name := c.FormValue("name")
-	category := c.FormValue("category")
 	label := c.FormValue("label")
 	description := c.FormValue("description")
 
@@ -270,58 +463,82 @@ func EditProduct(c *fiber.Ctx) error {
 	stockStr := c.FormValue("stock")
 
 	if priceStr != "" {
-		price, err := strconv.ParseFloat(priceStr, 64)
+		price, err := parseProductPrice(priceStr)
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid price format"})
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 		}
 		product.Price = price
+		columns = append(columns, "price")
 	}
 
 	if stockStr != "" {
-		stock, err := strconv.Atoi(stockStr)
+		var variantCount int64
+		database.DB.Model(&models.ProductVariant{}).Where("product_id = ?", product.ID).Count(&variantCount)
+		if variantCount > 0 {
+			return c.Status(400).JSON(fiber.Map{"error": "Stock of this product is managed per variant"})
+		}
+		if auctioned, err := productAuctioned(database.DB, product.ID); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to check auctions"})
+		} else if auctioned {
+			return c.Status(409).JSON(fiber.Map{"error": "Stock of products being auctioned cannot be edited"})
+		}
+
+		stock, err := parseProductStock(stockStr)
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "Invalid stock format"})
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 		}
 		product.Stock = stock
+		columns = append(columns, "stock")
 	}
 
 	if name != "" {
 		product.Name = name
+		columns = append(columns, "name")
 	}
-	if category != "" {
-		if category != "Fashion" && category != "Others" {
-			return c.Status(400).JSON(fiber.Map{"error": "Category must be 'Fashion' or 'Others'"})
-		}
-		product.Category = category
+	category, err := productCategoryInput(requestForm(c))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Category not found"})
+	}
+	if category != nil {
+		product.CategoryID = &category.ID
+		columns = append(columns, "category_id")
+	}
+	if err := applyProductAttributes(requestForm(c), &product); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
+	if err := checkProductAttributes(&product); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	columns = append(columns, "condition", "size", "brand", "material", "measurements")
 	if label != "" {
 		product.Label = label
+		columns = append(columns, "label")
 	}
 	if description != "" {
 		product.Description = description
+		columns = append(columns, "description")
+	}
+	if err := applyProductStatus(requestForm(c), &product); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if c.FormValue("status") == "" && stockStr != "" {
+		switch {
+		case product.Status == models.ProductStatusSoldOut && product.Stock > 0:
+			product.Status = models.ProductStatusPublished
+		case product.Status == models.ProductStatusPublished && product.Stock <= 0:
+			product.Status = models.ProductStatusSoldOut
+		}
+	}
+	if product.Status != before.Status || c.FormValue("status") != "" || c.FormValue("publish_at") != "" {
+		columns = append(columns, "status", "publish_at")
 	}
 
 	file, err := c.FormFile("image")
 	if file != nil && err == nil {
-		if file.Size > 1*1024*1024 {
-			return c.Status(400).JSON(fiber.Map{"error": "Image size must be less than 1MB"})
+		if err := validateProductImage(file.Filename, file.Size); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 		}
-
 		ext := strings.ToLower(filepath.Ext(file.Filename))
-		allowedExt := map[string]bool{
-			".png":  true,
-			".jpg":  true,
-			".jpeg": true,
-			".webp": true,
-		}
-		if !allowedExt[ext] {
-			return c.Status(400).JSON(fiber.Map{"error": "Image must be PNG, JPG, JPEG, WEBP format"})
-		}
-
-		if product.Image != "" && !strings.Contains(product.Image, "pravatar.cc") {
-			oldPath := "." + strings.TrimPrefix(product.Image, "http://127.0.0.1:3000")
-			_ = os.Remove(oldPath)
-		}
 
 		os.MkdirAll("./assets/products", os.ModePerm)
 		filename := fmt.Sprintf("%d_%d%s", userID, time.Now().UnixNano(), ext)
@@ -332,11 +549,26 @@ func EditProduct(c *fiber.Ctx) error {
 		}
 
 		product.Image = "http://127.0.0.1:3000/assets/products/" + filename
+		columns = append(columns, "image")
 	}
 
-	if err := database.DB.Save(&product).Error; err != nil {
+	if productNeedsImage(&product) {
+		return c.Status(400).JSON(fiber.Map{"error": "Add a product image before publishing"})
+	}
+
+	if err := database.DB.Model(&product).Select(columns).Updates(&product).Error; err != nil {
+		if product.Image != before.Image {
+			removeProductImage(product.Image)
+		}
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to update product", "details": err.Error()})
 	}
+	if product.Image != before.Image {
+		removeProductImage(before.Image)
+	}
+	database.DB.Preload("Category").First(&product, product.ID)
+
+	recordAudit(c, "product.update", "product", product.ID, before, product)
+	indexProducts(product.ID)
 
 	return c.Status(200).JSON(fiber.Map{
 		"status":  "success",
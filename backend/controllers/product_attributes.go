@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"finpro/models"
+)
+
+var measurementKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,29}$`)
+
+// applyProductAttributes copies the attribute form fields that were sent onto
+// the product. Empty fields are left unchanged, like the other fields of
+// EditProduct.
+func applyProductAttributes(form formValue, product *models.Product) error {
+	if condition := strings.ToLower(strings.TrimSpace(form("condition"))); condition != "" {
+		if !containsString(models.ProductConditions, condition) {
+			return fmt.Errorf("condition must be one of %s", strings.Join(models.ProductConditions, ", "))
+		}
+		product.Condition = condition
+	}
+
+	for _, field := range []struct {
+		name   string
+		target *string
+		max    int
+	}{{"size", &product.Size, 20}, {"brand", &product.Brand, 100}, {"material", &product.Material, 100}} {
+		value := strings.TrimSpace(form(field.name))
+		if value == "" {
+			continue
+		}
+		if len(value) > field.max {
+			return fmt.Errorf("%s must be at most %d characters", field.name, field.max)
+		}
+		*field.target = value
+	}
+
+	if raw := strings.TrimSpace(form("measurements")); raw != "" {
+		measurements := map[string]float64{}
+		if err := json.Unmarshal([]byte(raw), &measurements); err != nil {
+			return fmt.Errorf("measurements must be a JSON object of numbers, e.g. {\"chest_cm\": 52}")
+		}
+		for key, value := range measurements {
+			if !measurementKeyPattern.MatchString(key) {
+				return fmt.Errorf("measurement %q must be lowercase letters, digits or underscores", key)
+			}
+			if value <= 0 || value > 10000 || math.IsNaN(value) {
+				return fmt.Errorf("measurement %q must be a positive number", key)
+			}
+		}
+		product.Measurements = measurements
+	}
+
+	return nil
+}
+
+// validateProductAttributes checks the product against the attribute schema
+// of its category.
+func validateProductAttributes(product *models.Product, schema *models.AttributeSchema) error {
+	if schema == nil {
+		return nil
+	}
+
+	for _, field := range schema.Required {
+		missing := false
+		switch field {
+		case "condition":
+			missing = product.Condition == ""
+		case "size":
+			missing = product.Size == ""
+		case "brand":
+			missing = product.Brand == ""
+		case "material":
+			missing = product.Material == ""
+		case "measurements":
+			missing = len(product.Measurements) == 0
+		}
+		if missing {
+			return fmt.Errorf("%s is required for this category", field)
+		}
+	}
+
+	if product.Size != "" && len(schema.Sizes) > 0 {
+		found := false
+		for _, size := range schema.Sizes {
+			if strings.EqualFold(size, product.Size) {
+				product.Size = size
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("size must be one of %s", strings.Join(schema.Sizes, ", "))
+		}
+	}
+
+	if len(schema.Measurements) > 0 {
+		for key := range product.Measurements {
+			if !containsString(schema.Measurements, key) {
+				return fmt.Errorf("measurement %q is not used in this category, allowed: %s", key, strings.Join(schema.Measurements, ", "))
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkProductAttributes validates the product against the schema of the
+// category it is assigned to.
+func checkProductAttributes(product *models.Product) error {
+	categories, err := loadCategories()
+	if err != nil {
+		return fmt.Errorf("Failed to fetch categories")
+	}
+	return validateProductAttributes(product, categorySchema(categories, product.CategoryID))
+}
+
+func validateAttributeSchema(schema *models.AttributeSchema) error {
+	if schema == nil {
+		return nil
+	}
+	for _, field := range schema.Required {
+		if !containsString(models.ProductAttributeFields, field) {
+			return fmt.Errorf("Unknown attribute %q, allowed: %s", field, strings.Join(models.ProductAttributeFields, ", "))
+		}
+	}
+	for _, key := range schema.Measurements {
+		if !measurementKeyPattern.MatchString(key) {
+			return fmt.Errorf("Measurement %q must be lowercase letters, digits or underscores", key)
+		}
+	}
+	return nil
+}
+
+// normalizeAttributeSchema turns an empty schema into nil, so the category
+// inherits the schema of its parent again.
+func normalizeAttributeSchema(schema *models.AttributeSchema) *models.AttributeSchema {
+	if schema == nil || (len(schema.Required) == 0 && len(schema.Sizes) == 0 && len(schema.Measurements) == 0) {
+		return nil
+	}
+	return schema
+}
+
+// categorySchema returns the attribute schema of a category, inherited from
+// the closest ancestor that has one.
+func categorySchema(categories []models.Category, categoryID *uint) *models.AttributeSchema {
+	if categoryID == nil {
+		return nil
+	}
+	path := categoryPath(categories, *categoryID)
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i].AttributeSchema != nil {
+			return path[i].AttributeSchema
+		}
+	}
+	return nil
+}
+
+// parseListParam splits a comma separated query parameter.
+func parseListParam(value string) []string {
+	values := []string{}
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"finpro/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// formValue returns a field of a product form, or "" when it is missing. It
+// lets the multipart form of AddProduct and the rows of a product import
+// share the same validation.
+type formValue func(key string) string
+
+func requestForm(c *fiber.Ctx) formValue {
+	return func(key string) string {
+		return c.FormValue(key)
+	}
+}
+
+const maxProductImageSize = 1 * 1024 * 1024
+
+var productImageExtensions = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".webp": true,
+}
+
+// newProductFromForm validates every field of a new product except the
+// image and returns the product ready to be saved.
+func newProductFromForm(form formValue, shopID uint) (models.Product, error) {
+	price, err := parseProductPrice(form("price"))
+	if err != nil {
+		return models.Product{}, err
+	}
+
+	stock, err := parseProductStock(form("stock"))
+	if err != nil {
+		return models.Product{}, err
+	}
+
+	name := form("name")
+	if name == "" {
+		return models.Product{}, fmt.Errorf("Product name is required")
+	}
+
+	category, err := productCategoryInput(form)
+	if err != nil || category == nil {
+		return models.Product{}, fmt.Errorf("A valid category_id is required")
+	}
+
+	product := models.Product{
+		ShopID:      shopID,
+		Name:        name,
+		CategoryID:  &category.ID,
+		Category:    category,
+		Label:       form("label"),
+		Description: form("description"),
+		Price:       price,
+		Stock:       stock,
+	}
+
+	if err := applyProductAttributes(form, &product); err != nil {
+		return models.Product{}, err
+	}
+	if err := checkProductAttributes(&product); err != nil {
+		return models.Product{}, err
+	}
+
+	product.Status = models.ProductStatusPublished
+	if err := applyProductStatus(form, &product); err != nil {
+		return models.Product{}, err
+	}
+	if product.Status == models.ProductStatusPublished && product.Stock == 0 {
+		product.Status = models.ProductStatusSoldOut
+	}
+
+	return product, nil
+}
+
+// parseProductPrice reads the price of a product form, which must be a
+// finite number above 0.
+func parseProductPrice(value string) (float64, error) {
+	price, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(price) || math.IsInf(price, 0) {
+		return 0, fmt.Errorf("Invalid price")
+	}
+	if price <= 0 {
+		return 0, fmt.Errorf("Price must be greater than 0")
+	}
+	return price, nil
+}
+
+// parseProductStock reads the stock of a product form.
+func parseProductStock(value string) (int, error) {
+	stock, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid stock")
+	}
+	if stock < 0 {
+		return 0, fmt.Errorf("Stock cannot be negative")
+	}
+	return stock, nil
+}
+
+func validateProductImage(filename string, size int64) error {
+	if size > maxProductImageSize {
+		return fmt.Errorf("Image size must be less than 1MB")
+	}
+	if !productImageExtensions[strings.ToLower(filepath.Ext(filename))] {
+		return fmt.Errorf("Image must be PNG, JPG, JPEG, or WEBP format")
+	}
+	return nil
+}
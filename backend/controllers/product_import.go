@@ -0,0 +1,464 @@
+package controllers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"finpro/database"
+	"finpro/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/xuri/excelize/v2"
+)
+
+const maxImportRows = 1000
+
+// productColumns are the columns of a catalog file, in export order. Import
+// needs name, category, price and stock; the others are optional.
+var productColumns = []string{
+	"name", "category", "price", "stock", "label", "description",
+	"condition", "size", "brand", "material", "measurements",
+	"status", "publish_at", "image",
+}
+
+type importRowResult struct {
+	Row       int      `json:"row"`
+	Name      string   `json:"name"`
+	Status    string   `json:"status"`
+	ProductID uint     `json:"product_id,omitempty"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// ImportProducts creates products from a CSV or XLSX file. Images are taken
+// from an optional zip archive by file name, or from an image URL of this
+// server as written by ExportProducts. Every row is validated like
+// AddProduct; valid rows are created and invalid rows reported. With
+// dry_run=true nothing is saved.
+func ImportProducts(c *fiber.Ctx) error {
+	shop, err := sellerShop(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	dryRun, _ := strconv.ParseBool(c.FormValue("dry_run"))
+
+	file, err := c.FormFile("file")
+	if err != nil || file == nil {
+		return c.Status(400).JSON(fiber.Map{"error": "A CSV or XLSX file is required"})
+	}
+
+	rows, err := readCatalogFile(file)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if len(rows) < 2 {
+		return c.Status(400).JSON(fiber.Map{"error": "The file has no product rows"})
+	}
+	if len(rows)-1 > maxImportRows {
+		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("At most %d products can be imported at once", maxImportRows)})
+	}
+
+	columns, err := catalogColumns(rows[0])
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	images := map[string]*zip.File{}
+	if archive, err := c.FormFile("images"); err == nil && archive != nil {
+		images, err = readImageArchive(archive)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
+	results := []importRowResult{}
+	created := 0
+	for i, row := range rows[1:] {
+		if rowEmpty(row) {
+			continue
+		}
+
+		form := func(key string) string {
+			if key == "category_id" {
+				key = "category"
+			}
+			index, ok := columns[key]
+			if !ok || index >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[index])
+		}
+
+		// Row numbers count the header, like a spreadsheet does.
+		result := importRowResult{Row: i + 2, Name: form("name"), Status: "ok"}
+
+		product, image, err := importProduct(form, shop.ID, images)
+		if err != nil {
+			result.Status = "error"
+			result.Errors = []string{err.Error()}
+			results = append(results, result)
+			continue
+		}
+
+		if !dryRun {
+			if err := saveImportedProduct(&product, image); err != nil {
+				result.Status = "error"
+				result.Errors = []string{err.Error()}
+				results = append(results, result)
+				continue
+			}
+			result.ProductID = product.ID
+			recordAudit(c, "product.create", "product", product.ID, nil, product)
+			indexProducts(product.ID)
+		}
+
+		created++
+		results = append(results, result)
+	}
+
+	message := fmt.Sprintf("%d of %d products imported", created, len(results))
+	if dryRun {
+		message = fmt.Sprintf("%d of %d products are valid, nothing was saved", created, len(results))
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": message,
+		"dry_run": dryRun,
+		"total":   len(results),
+		"valid":   created,
+		"failed":  len(results) - created,
+		"rows":    results,
+	})
+}
+
+// ExportProducts writes the caller's catalog in the import format, as CSV
+// (default) or XLSX with ?format=xlsx.
+func ExportProducts(c *fiber.Ctx) error {
+	shop, err := sellerShop(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	format := strings.ToLower(c.Query("format", "csv"))
+	if format != "csv" && format != "xlsx" {
+		return c.Status(400).JSON(fiber.Map{"error": "format must be csv or xlsx"})
+	}
+
+	var products []models.Product
+	if err := database.DB.Preload("Category").
+		Where("shop_id = ? AND archived_at IS NULL", shop.ID).
+		Order("id").
+		Find(&products).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch products"})
+	}
+
+	rows := [][]string{productColumns}
+	for _, product := range products {
+		rows = append(rows, catalogRow(product))
+	}
+
+	filename := fmt.Sprintf("products-%s", time.Now().Format("20060102-150405"))
+
+	if format == "xlsx" {
+		book := excelize.NewFile()
+		defer book.Close()
+		sheet := book.GetSheetName(0)
+		for i, row := range rows {
+			cell, _ := excelize.CoordinatesToCellName(1, i+1)
+			values := make([]interface{}, len(row))
+			for j, value := range row {
+				values[j] = value
+			}
+			if err := book.SetSheetRow(sheet, cell, &values); err != nil {
+				return c.Status(500).JSON(fiber.Map{"error": "Failed to write export"})
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := book.Write(&buf); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to write export"})
+		}
+		c.Set(fiber.HeaderContentType, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+filename+`.xlsx"`)
+		return c.Send(buf.Bytes())
+	}
+
+	// XLSX cells above are typed as text; CSV cells are not, so seller
+	// entered values that look like formulas are escaped.
+	for _, row := range rows[1:] {
+		for i := range row {
+			row[i] = spreadsheetCell(row[i])
+		}
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.WriteAll(rows); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to write export"})
+	}
+	c.Set(fiber.HeaderContentType, "text/csv")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+filename+`.csv"`)
+	return c.Send(buf.Bytes())
+}
+
+func sellerShop(c *fiber.Ctx) (*models.Shop, error) {
+	userToken := c.Locals("user").(*jwt.Token)
+	claims := userToken.Claims.(jwt.MapClaims)
+	userID := int(claims["id"].(float64))
+
+	var shop models.Shop
+	if err := database.DB.Where("user_id = ?", userID).First(&shop).Error; err != nil {
+		return nil, fmt.Errorf("Seller belum memiliki toko, buat toko terlebih dahulu")
+	}
+	return &shop, nil
+}
+
+func catalogRow(product models.Product) []string {
+	category := ""
+	if product.Category != nil {
+		category = product.Category.Slug
+	}
+	measurements := ""
+	if len(product.Measurements) > 0 {
+		raw, _ := json.Marshal(product.Measurements)
+		measurements = string(raw)
+	}
+	publishAt := ""
+	if product.PublishAt != nil {
+		publishAt = product.PublishAt.Format(time.RFC3339)
+	}
+
+	return []string{
+		product.Name,
+		category,
+		strconv.FormatFloat(product.Price, 'f', -1, 64),
+		strconv.Itoa(product.Stock),
+		product.Label,
+		product.Description,
+		product.Condition,
+		product.Size,
+		product.Brand,
+		product.Material,
+		measurements,
+		product.Status,
+		publishAt,
+		product.Image,
+	}
+}
+
+func readCatalogFile(file *multipart.FileHeader) ([][]string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read the file")
+	}
+	defer src.Close()
+
+	switch strings.ToLower(filepath.Ext(file.Filename)) {
+	case ".csv":
+		reader := csv.NewReader(src)
+		reader.FieldsPerRecord = -1
+		rows, err := reader.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CSV file: %v", err)
+		}
+		if len(rows) > 0 && len(rows[0]) > 0 {
+			rows[0][0] = strings.TrimPrefix(rows[0][0], "\ufeff")
+		}
+		// Exports escape formula-like values, see spreadsheetCell.
+		for _, row := range rows {
+			for i := range row {
+				row[i] = spreadsheetValue(row[i])
+			}
+		}
+		return rows, nil
+	case ".xlsx":
+		book, err := excelize.OpenReader(src)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid XLSX file")
+		}
+		defer book.Close()
+		rows, err := book.GetRows(book.GetSheetName(0))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid XLSX file")
+		}
+		return rows, nil
+	}
+	return nil, fmt.Errorf("The file must be a .csv or .xlsx file")
+}
+
+func catalogColumns(header []string) (map[string]int, error) {
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "category_id" {
+			name = "category"
+		}
+		if name == "" {
+			continue
+		}
+		if !containsString(productColumns, name) {
+			return nil, fmt.Errorf("Unknown column %q, allowed: %s", name, strings.Join(productColumns, ", "))
+		}
+		columns[name] = i
+	}
+
+	for _, required := range []string{"name", "category", "price", "stock"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("Column %q is required", required)
+		}
+	}
+	return columns, nil
+}
+
+// readImageArchive indexes the images of a zip archive by lower case file
+// name. Folders inside the archive are ignored.
+func readImageArchive(file *multipart.FileHeader) (map[string]*zip.File, error) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read the image archive")
+	}
+	// The archive is small enough to keep in memory, the request body
+	// already is.
+	data, err := io.ReadAll(src)
+	src.Close()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read the image archive")
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("images must be a zip archive")
+	}
+
+	images := map[string]*zip.File{}
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		images[strings.ToLower(path.Base(entry.Name))] = entry
+	}
+	return images, nil
+}
+
+type importImage struct {
+	ext  string
+	data []byte
+}
+
+// importProduct validates one row. The returned image is nil when the row
+// has none.
+func importProduct(form formValue, shopID uint, images map[string]*zip.File) (models.Product, *importImage, error) {
+	product, err := newProductFromForm(form, shopID)
+	if err != nil {
+		return product, nil, err
+	}
+
+	reference := form("image")
+	if reference == "" {
+		if productNeedsImage(&product) {
+			return product, nil, fmt.Errorf("Product image is required")
+		}
+		return product, nil, nil
+	}
+
+	image, err := loadImportImage(reference, shopID, images)
+	if err != nil {
+		return product, nil, err
+	}
+	return product, image, nil
+}
+
+// loadImportImage reads the image of a row from the image archive, or from
+// the product images of this server when the row refers to the image of one
+// of the shop's own products.
+func loadImportImage(reference string, shopID uint, images map[string]*zip.File) (*importImage, error) {
+	// An image URL of this server, as written by the export.
+	if prefix := "http://127.0.0.1:3000/assets/products/"; strings.HasPrefix(reference, prefix) {
+		name := path.Base(strings.TrimPrefix(reference, prefix))
+		var owned int64
+		if err := database.DB.Unscoped().Model(&models.Product{}).
+			Where("shop_id = ? AND image = ?", shopID, prefix+name).Count(&owned).Error; err != nil {
+			return nil, fmt.Errorf("Failed to check image %s", reference)
+		}
+		if owned == 0 {
+			return nil, fmt.Errorf("Image %s does not belong to a product of your shop", reference)
+		}
+		data, err := os.ReadFile(filepath.Join("./assets/products", name))
+		if err != nil {
+			return nil, fmt.Errorf("Image %s does not exist", reference)
+		}
+		if err := validateProductImage(name, int64(len(data))); err != nil {
+			return nil, err
+		}
+		return &importImage{ext: strings.ToLower(filepath.Ext(name)), data: data}, nil
+	}
+
+	entry, ok := images[strings.ToLower(path.Base(reference))]
+	if !ok {
+		return nil, fmt.Errorf("Image %s is not in the image archive", reference)
+	}
+	if err := validateProductImage(entry.Name, int64(entry.UncompressedSize64)); err != nil {
+		return nil, err
+	}
+
+	src, err := entry.Open()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read image %s", reference)
+	}
+	defer src.Close()
+
+	// The declared size in a zip can lie, so never read past the limit.
+	data, err := io.ReadAll(io.LimitReader(src, maxProductImageSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read image %s", reference)
+	}
+	if len(data) > maxProductImageSize {
+		return nil, fmt.Errorf("Image size must be less than 1MB")
+	}
+	return &importImage{ext: strings.ToLower(filepath.Ext(entry.Name)), data: data}, nil
+}
+
+// saveImportedProduct writes the image, then the product. Every product gets
+// its own image file so deleting one product never breaks another.
+func saveImportedProduct(product *models.Product, image *importImage) error {
+	savePath := ""
+	if image != nil {
+		os.MkdirAll("./assets/products", os.ModePerm)
+		filename := strconv.FormatInt(time.Now().UnixNano(), 10) + image.ext
+		savePath = "./assets/products/" + filename
+		if err := os.WriteFile(savePath, image.data, 0644); err != nil {
+			return fmt.Errorf("Failed to save product image")
+		}
+		product.Image = "http://127.0.0.1:3000/assets/products/" + filename
+	}
+
+	if err := database.DB.Omit("Category").Create(product).Error; err != nil {
+		if savePath != "" {
+			_ = os.Remove(savePath)
+		}
+		return fmt.Errorf("Failed to save product")
+	}
+	return nil
+}
+
+func rowEmpty(row []string) bool {
+	for _, value := range row {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
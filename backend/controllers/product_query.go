@@ -0,0 +1,306 @@
+package controllers
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"finpro/database"
+	"finpro/models"
+	"finpro/search"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultProductLimit = 20
+	maxProductLimit     = 100
+)
+
+var productSorts = map[string]string{
+	"newest": "products.created_at DESC, products.id DESC",
+	// price sorts use the discounted price, see listProducts.
+	"price_asc":  "",
+	"price_desc": "",
+	"name":       "products.name ASC, products.id ASC",
+	// relevance is ordered by the search ranking, see listProducts.
+	"relevance": "",
+}
+
+// productQuery holds the validated listing parameters shared by
+// GetAllProducts, GetProductByCategory and SearchProduct.
+type productQuery struct {
+	Page        int
+	Limit       int
+	Sort        string
+	MinPrice    *float64
+	MaxPrice    *float64
+	ShopID      uint
+	Label       string
+	InStock     bool
+	CategoryIDs []uint
+	Conditions  []string
+	Sizes       []string
+	Brands      []string
+	Materials   []string
+	Search      string
+	// ownShopID lists every product of this shop, drafts included, instead
+	// of only the published products of all shops.
+	ownShopID uint
+	Statuses  []string
+	// searchIDs holds the ranked search hits once listProducts ran the search.
+	searchIDs []uint
+	// now is the moment discounts are evaluated at for price filters,
+	// sorting and the prices in the response.
+	now time.Time
+}
+
+func parseProductQuery(c *fiber.Ctx) (productQuery, error) {
+	q := productQuery{
+		Page:  1,
+		Limit: defaultProductLimit,
+		now:   time.Now(),
+	}
+
+	if page := c.Query("page"); page != "" {
+		n, err := strconv.Atoi(page)
+		if err != nil || n < 1 {
+			return q, fmt.Errorf("page must be a positive number")
+		}
+		q.Page = n
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxProductLimit {
+			return q, fmt.Errorf("limit must be between 1 and %d", maxProductLimit)
+		}
+		q.Limit = n
+	}
+
+	if sort := c.Query("sort"); sort != "" {
+		if _, ok := productSorts[sort]; !ok {
+			return q, fmt.Errorf("sort must be one of newest, price_asc, price_desc, name, relevance")
+		}
+		q.Sort = sort
+	}
+
+	for _, bound := range []struct {
+		name   string
+		target **float64
+	}{{"min_price", &q.MinPrice}, {"max_price", &q.MaxPrice}} {
+		value := c.Query(bound.name)
+		if value == "" {
+			continue
+		}
+		price, err := strconv.ParseFloat(value, 64)
+		if err != nil || price < 0 || math.IsInf(price, 0) {
+			return q, fmt.Errorf("%s must be a non-negative number", bound.name)
+		}
+		*bound.target = &price
+	}
+	if q.MinPrice != nil && q.MaxPrice != nil && *q.MinPrice > *q.MaxPrice {
+		return q, fmt.Errorf("min_price cannot be greater than max_price")
+	}
+
+	if shopID := c.Query("shop_id"); shopID != "" {
+		n, err := strconv.ParseUint(shopID, 10, 64)
+		if err != nil || n == 0 {
+			return q, fmt.Errorf("shop_id must be a positive number")
+		}
+		q.ShopID = uint(n)
+	}
+
+	q.Label = strings.TrimSpace(c.Query("label"))
+
+	if inStock := c.Query("in_stock"); inStock != "" {
+		b, err := strconv.ParseBool(inStock)
+		if err != nil {
+			return q, fmt.Errorf("in_stock must be true or false")
+		}
+		q.InStock = b
+	}
+
+	if category := c.Query("category"); category != "" {
+		ids, err := categoryFilter(category)
+		if err != nil {
+			return q, err
+		}
+		q.CategoryIDs = ids
+	}
+
+	q.Conditions = parseListParam(strings.ToLower(c.Query("condition")))
+	for _, condition := range q.Conditions {
+		if !containsString(models.ProductConditions, condition) {
+			return q, fmt.Errorf("condition must be one of %s", strings.Join(models.ProductConditions, ", "))
+		}
+	}
+	q.Sizes = parseListParam(c.Query("size"))
+	q.Brands = parseListParam(c.Query("brand"))
+	q.Materials = parseListParam(c.Query("material"))
+
+	q.Search = strings.TrimSpace(c.Query("q"))
+
+	switch {
+	case q.Sort == "relevance" && q.Search == "":
+		return q, fmt.Errorf("sort=relevance requires a search query")
+	case q.Sort == "" && q.Search != "":
+		q.Sort = "relevance"
+	case q.Sort == "":
+		q.Sort = "newest"
+	}
+
+	return q, nil
+}
+
+// filters applies every filter of the query, but not sorting or paging.
+func (q productQuery) filters(db *gorm.DB) *gorm.DB {
+	if q.ownShopID != 0 {
+		db = db.Where("products.shop_id = ? AND products.archived_at IS NULL", q.ownShopID)
+		if len(q.Statuses) > 0 {
+			db = db.Where("products.status IN ?", q.Statuses)
+		}
+	} else {
+		db = db.Scopes(visibleProducts)
+	}
+
+	if q.MinPrice != nil {
+		db = db.Where(effectivePriceSQL+" >= ?", q.now, q.now, *q.MinPrice)
+	}
+	if q.MaxPrice != nil {
+		db = db.Where(effectivePriceSQL+" <= ?", q.now, q.now, *q.MaxPrice)
+	}
+	if q.ShopID != 0 {
+		db = db.Where("products.shop_id = ?", q.ShopID)
+	}
+	if q.Label != "" {
+		db = db.Where("products.label = ?", q.Label)
+	}
+	if q.InStock {
+		db = db.Where("products.stock > 0")
+	}
+	if len(q.CategoryIDs) > 0 {
+		db = db.Where("products.category_id IN ?", q.CategoryIDs)
+	}
+	// The default collation compares these case insensitively.
+	if len(q.Conditions) > 0 {
+		db = db.Where("products.condition IN ?", q.Conditions)
+	}
+	if len(q.Sizes) > 0 {
+		db = db.Where("products.size IN ?", q.Sizes)
+	}
+	if len(q.Brands) > 0 {
+		db = db.Where("products.brand IN ?", q.Brands)
+	}
+	if len(q.Materials) > 0 {
+		db = db.Where("products.material IN ?", q.Materials)
+	}
+	if q.Search != "" {
+		db = db.Where("products.id IN ?", q.searchIDs)
+	}
+
+	return db
+}
+
+// listProducts runs the query with paging and writes the standard listing
+// response, including the total count for the filters.
+func listProducts(c *fiber.Ctx, q productQuery, emptyMessage string) error {
+	products, total, corrected, err := findProducts(q)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	response := fiber.Map{
+		"status": "success",
+		"data":   products,
+		"pagination": fiber.Map{
+			"page":        q.Page,
+			"limit":       q.Limit,
+			"total":       total,
+			"total_pages": int(math.Ceil(float64(total) / float64(q.Limit))),
+		},
+	}
+	if total == 0 {
+		response["message"] = emptyMessage
+	}
+	if corrected != "" {
+		response["corrected_query"] = corrected
+	}
+
+	return c.JSON(response)
+}
+
+// findProducts returns one page of the products matching the query with
+// their prices, the total count for the filters and the corrected search
+// text, if any.
+func findProducts(q productQuery) ([]models.Product, int64, string, error) {
+	corrected := ""
+	if q.Search != "" {
+		result, err := search.Default().Search(q.Search, maxSearchHits)
+		if err != nil {
+			return nil, 0, "", err
+		}
+		corrected = result.Corrected
+
+		q.searchIDs = make([]uint, 0, len(result.Hits))
+		for _, hit := range result.Hits {
+			q.searchIDs = append(q.searchIDs, hit.ID)
+		}
+		if len(q.searchIDs) == 0 {
+			// Keeps "IN ?" valid SQL while matching nothing.
+			q.searchIDs = []uint{0}
+		}
+	}
+
+	query := database.DB.Model(&models.Product{}).Scopes(q.filters).Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, "", err
+	}
+
+	products := []models.Product{}
+	var order interface{} = productSorts[q.Sort]
+	switch q.Sort {
+	case "relevance":
+		order = clause.OrderBy{Expression: clause.Expr{
+			SQL:                "FIELD(products.id, ?)",
+			Vars:               []interface{}{q.searchIDs},
+			WithoutParentheses: true,
+		}}
+	case "price_asc":
+		order = effectivePriceOrder(q.now, "ASC")
+	case "price_desc":
+		order = effectivePriceOrder(q.now, "DESC")
+	}
+
+	if err := query.Preload("Category").Order(order).
+		Offset((q.Page - 1) * q.Limit).
+		Limit(q.Limit).
+		Find(&products).Error; err != nil {
+		return nil, 0, "", err
+	}
+	if err := priceProducts(products, q.now); err != nil {
+		return nil, 0, "", err
+	}
+	if q.ownShopID != 0 {
+		ids := make([]uint, 0, len(products))
+		for _, product := range products {
+			ids = append(ids, product.ID)
+		}
+		counts, err := wishlistCounts(ids)
+		if err != nil {
+			return nil, 0, "", err
+		}
+		for i := range products {
+			count := counts[products[i].ID]
+			products[i].WishlistCount = &count
+		}
+	}
+
+	return products, total, corrected, nil
+}
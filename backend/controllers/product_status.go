@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"finpro/database"
+	"finpro/models"
+	"gorm.io/gorm"
+)
+
+// applyProductStatus reads the status and publish_at form fields. A
+// publish_at makes the product a scheduled draft; "none" removes the
+// schedule again.
+func applyProductStatus(form formValue, product *models.Product) error {
+	status := strings.ToLower(strings.TrimSpace(form("status")))
+	publishAt := strings.TrimSpace(form("publish_at"))
+
+	if status != "" {
+		if !containsString(models.ProductStatuses, status) {
+			return fmt.Errorf("status must be one of %s", strings.Join(models.ProductStatuses, ", "))
+		}
+		product.Status = status
+		if status != models.ProductStatusDraft {
+			product.PublishAt = nil
+		}
+	}
+
+	switch publishAt {
+	case "":
+	case "none":
+		product.PublishAt = nil
+	default:
+		at, err := time.Parse(time.RFC3339, publishAt)
+		if err != nil {
+			return fmt.Errorf("publish_at must be an RFC 3339 time, e.g. 2025-01-31T09:00:00+07:00")
+		}
+		if !at.After(time.Now()) {
+			return fmt.Errorf("publish_at must be in the future")
+		}
+		if status != "" && status != models.ProductStatusDraft {
+			return fmt.Errorf("Only drafts can be scheduled for publishing")
+		}
+		product.Status = models.ProductStatusDraft
+		product.PublishAt = &at
+	}
+
+	return nil
+}
+
+// productNeedsImage reports whether the product is or will become visible to
+// buyers, which requires an image.
+func productNeedsImage(product *models.Product) bool {
+	if product.Image != "" {
+		return false
+	}
+	return product.Status != models.ProductStatusDraft || product.PublishAt != nil
+}
+
+// publishScheduledProducts publishes drafts whose publish time has passed.
+// Listings already show them from that moment, this makes the status and
+// the search index catch up.
+func publishScheduledProducts() {
+	var products []models.Product
+	if err := database.DB.Where("status = ? AND publish_at <= ?", models.ProductStatusDraft, time.Now()).
+		Find(&products).Error; err != nil {
+		fmt.Printf("⚠️ Failed to load scheduled products: %v\n", err)
+		return
+	}
+
+	for _, product := range products {
+		before := product
+		if err := database.DB.Model(&product).Updates(map[string]interface{}{
+			"status":     models.ProductStatusPublished,
+			"publish_at": nil,
+		}).Error; err != nil {
+			fmt.Printf("⚠️ Failed to publish product %d: %v\n", product.ID, err)
+			continue
+		}
+		recordSystemAudit("product.publish", "product", product.ID, before, product)
+		indexProducts(product.ID)
+	}
+}
+
+// refreshSoldOut switches a published product whose stock ran out to sold
+// out, and a sold out product that got stock again back to published.
+func refreshSoldOut(tx *gorm.DB, productID uint) error {
+	if err := tx.Model(&models.Product{}).
+		Where("id = ? AND stock <= 0 AND status = ?", productID, models.ProductStatusPublished).
+		Update("status", models.ProductStatusSoldOut).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.Product{}).
+		Where("id = ? AND stock > 0 AND status = ?", productID, models.ProductStatusSoldOut).
+		Update("status", models.ProductStatusPublished).Error
+}
@@ -0,0 +1,258 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	"finpro/database"
+	"finpro/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+)
+
+func GetProductVariants(c *fiber.Ctx) error {
+	var product models.Product
+	if err := database.DB.Scopes(visibleProducts).First(&product, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Product not found"})
+	}
+
+	var variants []models.ProductVariant
+	if err := database.DB.Where("product_id = ?", product.ID).Order("id").Find(&variants).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch variants"})
+	}
+
+	return c.JSON(fiber.Map{"status": "success", "data": variants})
+}
+
+func AddProductVariant(c *fiber.Ctx) error {
+	product, status, err := ownedProduct(c, c.Params("id"))
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var input models.ProductVariantInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	variant := models.ProductVariant{ProductID: product.ID}
+	if status, err := applyVariantInput(product, &variant, input); err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&variant).Error; err != nil {
+			return err
+		}
+		if variant.SKU == "" {
+			variant.SKU = fmt.Sprintf("P%d-V%d", product.ID, variant.ID)
+			if err := tx.Model(&variant).Update("sku", variant.SKU).Error; err != nil {
+				return err
+			}
+		}
+		return syncProductStock(tx, product.ID)
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to add variant"})
+	}
+
+	recordAudit(c, "product_variant.create", "product_variant", variant.ID, nil, variant)
+
+	return c.Status(201).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Variant added successfully",
+		"data":    variant,
+	})
+}
+
+func EditProductVariant(c *fiber.Ctx) error {
+	product, status, err := ownedProduct(c, c.Params("id"))
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var variant models.ProductVariant
+	if err := database.DB.Where("product_id = ?", product.ID).First(&variant, c.Params("variant_id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Variant not found"})
+	}
+	before := variant
+
+	var input models.ProductVariantInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	if status, err := applyVariantInput(product, &variant, input); err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	if variant.SKU == "" {
+		variant.SKU = before.SKU
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&variant).Error; err != nil {
+			return err
+		}
+		return syncProductStock(tx, product.ID)
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update variant"})
+	}
+
+	recordAudit(c, "product_variant.update", "product_variant", variant.ID, before, variant)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Variant updated successfully",
+		"data":    variant,
+	})
+}
+
+func DeleteProductVariant(c *fiber.Ctx) error {
+	product, status, err := ownedProduct(c, c.Params("id"))
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var variant models.ProductVariant
+	if err := database.DB.Where("product_id = ?", product.ID).First(&variant, c.Params("variant_id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Variant not found"})
+	}
+
+	var orderItemCount int64
+	if err := database.DB.Model(&models.OrderItem{}).Where("variant_id = ?", variant.ID).Count(&orderItemCount).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to check order history"})
+	}
+	if orderItemCount > 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Variant cannot be deleted because it is linked to existing orders, set its stock to 0 instead."})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("variant_id = ?", variant.ID).Delete(&models.CartItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&variant).Error; err != nil {
+			return err
+		}
+		return syncProductStock(tx, product.ID)
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete variant"})
+	}
+
+	recordAudit(c, "product_variant.delete", "product_variant", variant.ID, variant, nil)
+
+	return c.JSON(fiber.Map{"status": "success", "message": "Variant deleted successfully"})
+}
+
+// ownedProduct loads a product of the caller's shop.
+func ownedProduct(c *fiber.Ctx, productID string) (*models.Product, int, error) {
+	userToken := c.Locals("user").(*jwt.Token)
+	claims := userToken.Claims.(jwt.MapClaims)
+	userID := int(claims["id"].(float64))
+
+	var shop models.Shop
+	if err := database.DB.Where("user_id = ?", userID).First(&shop).Error; err != nil {
+		return nil, fiber.StatusBadRequest, fmt.Errorf("Seller belum memiliki toko, buat toko terlebih dahulu")
+	}
+
+	var product models.Product
+	if err := database.DB.First(&product, productID).Error; err != nil {
+		return nil, 404, fmt.Errorf("Product not found")
+	}
+	if product.ShopID != shop.ID {
+		return nil, fiber.StatusForbidden, fmt.Errorf("Kamu tidak memiliki izin untuk mengubah produk ini")
+	}
+
+	return &product, 0, nil
+}
+
+func applyVariantInput(product *models.Product, variant *models.ProductVariant, input models.ProductVariantInput) (int, error) {
+	if input.SKU != nil {
+		sku := strings.TrimSpace(*input.SKU)
+		if len(sku) > 64 {
+			return 400, fmt.Errorf("SKU must be at most 64 characters")
+		}
+		if sku != "" {
+			var count int64
+			database.DB.Model(&models.ProductVariant{}).Where("sku = ? AND id <> ?", sku, variant.ID).Count(&count)
+			if count > 0 {
+				return 409, fmt.Errorf("SKU already exists")
+			}
+		}
+		variant.SKU = sku
+	}
+	if input.Size != nil {
+		variant.Size = strings.TrimSpace(*input.Size)
+	}
+	if input.Color != nil {
+		variant.Color = strings.TrimSpace(*input.Color)
+	}
+	if input.Price != nil {
+		if *input.Price < 0 {
+			return 400, fmt.Errorf("Price cannot be negative")
+		}
+		variant.Price = input.Price
+	}
+	if input.ClearPrice {
+		variant.Price = nil
+	}
+	if input.Stock != nil {
+		if *input.Stock < 0 {
+			return 400, fmt.Errorf("Stock cannot be negative")
+		}
+		variant.Stock = *input.Stock
+	}
+
+	if len(variant.Size) > 20 || len(variant.Color) > 50 {
+		return 400, fmt.Errorf("Size must be at most 20 and color at most 50 characters")
+	}
+	if variant.Size == "" && variant.Color == "" {
+		return 400, fmt.Errorf("A variant needs a size or a color")
+	}
+
+	// Variant sizes follow the same category schema as product sizes.
+	if variant.Size != "" {
+		categories, err := loadCategories()
+		if err != nil {
+			return 500, fmt.Errorf("Failed to fetch categories")
+		}
+		check := models.Product{Size: variant.Size}
+		if schema := categorySchema(categories, product.CategoryID); schema != nil {
+			if err := validateProductAttributes(&check, &models.AttributeSchema{Sizes: schema.Sizes}); err != nil {
+				return 400, err
+			}
+		}
+		variant.Size = check.Size
+	}
+
+	var count int64
+	database.DB.Model(&models.ProductVariant{}).
+		Where("product_id = ? AND size = ? AND color = ? AND id <> ?", product.ID, variant.Size, variant.Color, variant.ID).
+		Count(&count)
+	if count > 0 {
+		return 409, fmt.Errorf("This product already has a variant with the same size and color")
+	}
+
+	return 0, nil
+}
+
+// syncProductStock keeps the product stock at the sum of its variants, so
+// listings and the in_stock filter work the same for products with variants.
+func syncProductStock(tx *gorm.DB, productID uint) error {
+	var count int64
+	if err := tx.Model(&models.ProductVariant{}).Where("product_id = ?", productID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+
+	if err := tx.Model(&models.Product{}).Where("id = ?", productID).
+		Update("stock", tx.Model(&models.ProductVariant{}).Select("COALESCE(SUM(stock), 0)").Where("product_id = ?", productID)).Error; err != nil {
+		return err
+	}
+	return refreshSoldOut(tx, productID)
+}
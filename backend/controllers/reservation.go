@@ -0,0 +1,250 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"finpro/database"
+	"finpro/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultReservationMinutes = 15
+	maxReservationMinutes     = 120
+)
+
+const (
+	availabilityInStock  = "in_stock"
+	availabilityReserved = "reserved"
+	availabilitySoldOut  = "sold_out"
+)
+
+type reservedError struct {
+	name string
+}
+
+func (e *reservedError) Error() string {
+	return e.name + " is reserved by another buyer, please try again later"
+}
+
+// reservationWindow is how long starting checkout holds the stock, set by
+// admins in the settings.
+func reservationWindow() time.Duration {
+	minutes, err := strconv.Atoi(getSetting(models.SettingReservationMinutes, strconv.Itoa(defaultReservationMinutes)))
+	if err != nil || minutes < 1 || minutes > maxReservationMinutes {
+		minutes = defaultReservationMinutes
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// stockKey identifies the stock a cart item is sold from: the variant, or
+// the product itself when VariantID is 0.
+type stockKey struct {
+	ProductID uint
+	VariantID uint
+}
+
+func cartStockKey(productID uint, variantID *uint) stockKey {
+	key := stockKey{ProductID: productID}
+	if variantID != nil {
+		key.VariantID = *variantID
+	}
+	return key
+}
+
+// reservedStock sums the running holds of everyone except exceptUserID on
+// the given products.
+func reservedStock(db *gorm.DB, productIDs []uint, exceptUserID uint, now time.Time) (map[stockKey]int, error) {
+	reserved := map[stockKey]int{}
+	if len(productIDs) == 0 {
+		return reserved, nil
+	}
+
+	var rows []struct {
+		ProductID uint
+		VariantID *uint
+		Quantity  int
+	}
+	if err := db.Model(&models.StockReservation{}).
+		Select("product_id, variant_id, SUM(quantity) AS quantity").
+		Where("product_id IN ? AND user_id <> ? AND expires_at > ?", productIDs, exceptUserID, now).
+		Group("product_id, variant_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		reserved[cartStockKey(row.ProductID, row.VariantID)] += row.Quantity
+	}
+	return reserved, nil
+}
+
+// productReserved is the stock held on a product over all its variants.
+func productReserved(reserved map[stockKey]int, productID uint) int {
+	total := 0
+	for key, quantity := range reserved {
+		if key.ProductID == productID {
+			total += quantity
+		}
+	}
+	return total
+}
+
+func availability(stock, reserved int) string {
+	switch {
+	case stock <= 0:
+		return availabilitySoldOut
+	case stock-reserved <= 0:
+		return availabilityReserved
+	}
+	return availabilityInStock
+}
+
+// holdStock locks the product of a cart item and makes sure its stock,
+// minus what other buyers hold, covers the item. Every path that reserves
+// or sells stock goes through it, so two buyers can never both get the
+// last piece.
+func holdStock(tx *gorm.DB, userID uint, item models.CartItem, now time.Time) error {
+	var product models.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, item.ProductID).Error; err != nil {
+		return &outOfStockError{name: item.Product.Name}
+	}
+
+	stock := product.Stock
+	if item.VariantID != nil {
+		var variant models.ProductVariant
+		if err := tx.First(&variant, *item.VariantID).Error; err != nil {
+			return &outOfStockError{name: product.Name}
+		}
+		stock = variant.Stock
+	}
+	if stock < item.Quantity {
+		return &outOfStockError{name: product.Name}
+	}
+
+	reserved, err := reservedStock(tx, []uint{item.ProductID}, userID, now)
+	if err != nil {
+		return err
+	}
+	if stock-reserved[cartStockKey(item.ProductID, item.VariantID)] < item.Quantity {
+		return &reservedError{name: product.Name}
+	}
+	return nil
+}
+
+// releaseReservations drops the holds of cart items, e.g. when they are
+// removed, changed or ordered.
+func releaseReservations(db *gorm.DB, cartItemIDs []uint) error {
+	if len(cartItemIDs) == 0 {
+		return nil
+	}
+	return db.Where("cart_item_id IN ?", cartItemIDs).Delete(&models.StockReservation{}).Error
+}
+
+// StartCheckout holds the stock of the selected cart items for the
+// reservation window, so the buyer can pay without someone else buying the
+// items in the meantime. Calling it again renews the hold.
+func StartCheckout(c *fiber.Ctx) error {
+	type Request struct {
+		CartIDs []uint `json:"cart_ids"`
+	}
+
+	var body Request
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if len(body.CartIDs) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "No cart items selected"})
+	}
+
+	userToken := c.Locals("user").(*jwt.Token)
+	claims := userToken.Claims.(jwt.MapClaims)
+	userID := uint(claims["id"].(float64))
+
+	var cartItems []models.CartItem
+	if err := database.DB.Preload("Product").Preload("Variant").
+		Where("id IN ? AND user_id = ?", body.CartIDs, userID).
+		Find(&cartItems).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch selected cart items"})
+	}
+	if len(cartItems) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "No valid cart items found"})
+	}
+
+	now := time.Now()
+	checks, err := revalidateCart(cartItems, now)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to check cart items"})
+	}
+	if warnings := cartWarnings(checks); len(warnings) > 0 {
+		return c.Status(409).JSON(fiber.Map{
+			"error":    "Your cart has changed, please review and acknowledge the changes before checkout",
+			"warnings": warnings,
+		})
+	}
+
+	expiresAt := now.Add(reservationWindow())
+	reservations := []models.StockReservation{}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, check := range checks {
+			item := check.Item
+			if err := holdStock(tx, userID, item, now); err != nil {
+				return err
+			}
+			if err := releaseReservations(tx, []uint{item.ID}); err != nil {
+				return err
+			}
+
+			reservation := models.StockReservation{
+				UserID:     userID,
+				CartItemID: item.ID,
+				ProductID:  item.ProductID,
+				VariantID:  item.VariantID,
+				Quantity:   item.Quantity,
+				ExpiresAt:  expiresAt,
+			}
+			if err := tx.Create(&reservation).Error; err != nil {
+				return err
+			}
+			reservations = append(reservations, reservation)
+		}
+		return nil
+	})
+	if err != nil {
+		var stockErr *outOfStockError
+		var heldErr *reservedError
+		switch {
+		case errors.As(err, &stockErr):
+			return c.Status(400).JSON(fiber.Map{"error": stockErr.Error()})
+		case errors.As(err, &heldErr):
+			return c.Status(409).JSON(fiber.Map{"error": heldErr.Error(), "availability": availabilityReserved})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to reserve cart items"})
+	}
+
+	for _, reservation := range reservations {
+		recordAudit(c, "stock.reserve", "stock_reservation", reservation.ID, nil, reservation)
+	}
+
+	return c.JSON(fiber.Map{
+		"status":       "success",
+		"message":      fmt.Sprintf("Items are reserved until %s", expiresAt.Format(time.RFC3339)),
+		"expires_at":   expiresAt,
+		"reservations": reservations,
+	})
+}
+
+// releaseExpiredReservations is the background sweeper that gives the stock
+// of abandoned checkouts back to other buyers.
+func releaseExpiredReservations() {
+	if err := database.DB.Where("expires_at <= ?", time.Now()).Delete(&models.StockReservation{}).Error; err != nil {
+		fmt.Printf("⚠️ Failed to release expired reservations: %v\n", err)
+	}
+}
@@ -0,0 +1,380 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"finpro/database"
+	"finpro/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	maxReviewPhotos  = 5
+	maxReviewComment = 2000
+	maxReviewReply   = 1000
+)
+
+// CreateReview rates an item of one of the buyer's delivered orders. Each
+// order item can be reviewed once. The form takes order_item_id, rating
+// (1 to 5), comment and up to 5 photos.
+func CreateReview(c *fiber.Ctx) error {
+	userID := sessionUserID(c)
+
+	itemID, _ := strconv.ParseUint(c.FormValue("order_item_id"), 10, 64)
+	rating, err := strconv.Atoi(c.FormValue("rating"))
+	if err != nil || rating < 1 || rating > 5 {
+		return c.Status(400).JSON(fiber.Map{"error": "rating must be a number from 1 to 5"})
+	}
+	comment := strings.TrimSpace(c.FormValue("comment"))
+	if utf8.RuneCountInString(comment) > maxReviewComment {
+		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("comment must be at most %d characters", maxReviewComment)})
+	}
+
+	var item models.OrderItem
+	if err := database.DB.Preload("Order").First(&item, itemID).Error; err != nil || item.Order.UserID != userID {
+		return c.Status(404).JSON(fiber.Map{"error": "Order item not found"})
+	}
+	if item.Order.StatusShipping != "delivered" {
+		return c.Status(400).JSON(fiber.Map{"error": "Items can be reviewed once the order is delivered"})
+	}
+
+	var count int64
+	database.DB.Model(&models.Review{}).Where("order_item_id = ?", item.ID).Count(&count)
+	if count > 0 {
+		return c.Status(409).JSON(fiber.Map{"error": "You already reviewed this item"})
+	}
+
+	photos := []string{}
+	if form, err := c.MultipartForm(); err == nil {
+		files := form.File["photos"]
+		if len(files) > maxReviewPhotos {
+			return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("A review can have at most %d photos", maxReviewPhotos)})
+		}
+		for _, file := range files {
+			if err := validateProductImage(file.Filename, file.Size); err != nil {
+				return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+			}
+		}
+
+		os.MkdirAll("./assets/reviews", os.ModePerm)
+		for i, file := range files {
+			filename := strconv.FormatInt(time.Now().UnixNano(), 10) + "-" + strconv.Itoa(i) + strings.ToLower(filepath.Ext(file.Filename))
+			if err := c.SaveFile(file, "./assets/reviews/"+filename); err != nil {
+				removeReviewPhotos(photos)
+				return c.Status(500).JSON(fiber.Map{"error": "Failed to save review photo"})
+			}
+			photos = append(photos, "http://127.0.0.1:3000/assets/reviews/"+filename)
+		}
+	}
+
+	review := models.Review{
+		OrderItemID:  item.ID,
+		OrderID:      item.OrderID,
+		UserID:       userID,
+		ShopID:       item.Order.ShopID,
+		ProductID:    item.ProductID,
+		VariantLabel: item.VariantLabel,
+		Rating:       rating,
+		Comment:      comment,
+		Photos:       photos,
+		Status:       models.ReviewPublished,
+	}
+	if err := database.DB.Create(&review).Error; err != nil {
+		removeReviewPhotos(photos)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create review"})
+	}
+
+	recordAudit(c, "review.create", "review", review.ID, nil, review)
+
+	return c.Status(201).JSON(fiber.Map{"status": "success", "data": review})
+}
+
+// GetReviews lists the published reviews of a product (?product_id=) or a
+// shop (?shop_id=) with their rating summary, newest first. ?rating=
+// filters by stars.
+func GetReviews(c *fiber.Ctx) error {
+	query := database.DB.Model(&models.Review{}).Where("status = ?", models.ReviewPublished)
+
+	var summary models.RatingSummary
+	switch {
+	case c.Query("product_id") != "":
+		id, _ := strconv.ParseUint(c.Query("product_id"), 10, 64)
+		query = query.Where("product_id = ?", id)
+		summaries, err := ratingSummaries(database.DB, "product_id", []uint{uint(id)})
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch reviews"})
+		}
+		summary = summaries[uint(id)]
+	case c.Query("shop_id") != "":
+		id, _ := strconv.ParseUint(c.Query("shop_id"), 10, 64)
+		if shopHidden(uint(id)) {
+			return c.Status(404).JSON(fiber.Map{"error": "Shop not found"})
+		}
+		query = query.Where("shop_id = ?", id)
+		summaries, err := ratingSummaries(database.DB, "shop_id", []uint{uint(id)})
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch reviews"})
+		}
+		summary = summaries[uint(id)]
+	default:
+		return c.Status(400).JSON(fiber.Map{"error": "product_id or shop_id is required"})
+	}
+	if rating := c.Query("rating"); rating != "" {
+		query = query.Where("rating = ?", rating)
+	}
+
+	return listReviews(c, query, false, fiber.Map{"summary": summary})
+}
+
+// ReplyReview lets the seller answer a review of the own shop, once.
+func ReplyReview(c *fiber.Ctx) error {
+	shop, err := sellerShop(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var body struct {
+		Reply string `json:"reply"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	body.Reply = strings.TrimSpace(body.Reply)
+	if body.Reply == "" || utf8.RuneCountInString(body.Reply) > maxReviewReply {
+		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("reply must be 1 to %d characters", maxReviewReply)})
+	}
+
+	var review models.Review
+	if err := database.DB.First(&review, c.Params("id")).Error; err != nil || review.ShopID != shop.ID {
+		return c.Status(404).JSON(fiber.Map{"error": "Review not found"})
+	}
+
+	before := review
+	now := time.Now()
+	result := database.DB.Model(&models.Review{}).Where("id = ? AND replied_at IS NULL", review.ID).
+		Updates(map[string]interface{}{"reply": body.Reply, "replied_at": now})
+	if result.Error != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to reply to review"})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(409).JSON(fiber.Map{"error": "This review already has a reply"})
+	}
+	review.Reply, review.RepliedAt = body.Reply, &now
+
+	notification := models.Notification{
+		UserID:    review.UserID,
+		Type:      models.NotificationReview,
+		Title:     "The seller replied to your review",
+		Message:   fmt.Sprintf("%s replied to your review", shop.ShopName),
+		ProductID: &review.ProductID,
+	}
+	if err := database.DB.Create(&notification).Error; err != nil {
+		fmt.Printf("⚠️ Failed to notify user %d: %v\n", review.UserID, err)
+	}
+
+	recordAudit(c, "review.reply", "review", review.ID, before, review)
+	hideModeration(&review)
+
+	return c.JSON(fiber.Map{"status": "success", "data": review})
+}
+
+// GetReviewsAdmin lists every review for moderation, hidden ones included
+// (?status=, ?shop_id=, ?product_id=, ?rating=).
+func GetReviewsAdmin(c *fiber.Ctx) error {
+	query := database.DB.Model(&models.Review{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if shopID := c.Query("shop_id"); shopID != "" {
+		query = query.Where("shop_id = ?", shopID)
+	}
+	if productID := c.Query("product_id"); productID != "" {
+		query = query.Where("product_id = ?", productID)
+	}
+	if rating := c.Query("rating"); rating != "" {
+		query = query.Where("rating = ?", rating)
+	}
+
+	return listReviews(c, query, true, fiber.Map{})
+}
+
+// HideReview takes a review down, with the reason shown to moderators.
+func HideReview(c *fiber.Ctx) error {
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	body.Reason = strings.TrimSpace(body.Reason)
+	if body.Reason == "" || len(body.Reason) > 255 {
+		return c.Status(400).JSON(fiber.Map{"error": "reason is required and must be at most 255 characters"})
+	}
+
+	return moderateReview(c, "review.hide", models.ReviewHidden, body.Reason)
+}
+
+// RestoreReview publishes a hidden review again.
+func RestoreReview(c *fiber.Ctx) error {
+	return moderateReview(c, "review.restore", models.ReviewPublished, "")
+}
+
+// DeleteReview removes a review and its photos for good.
+func DeleteReview(c *fiber.Ctx) error {
+	var review models.Review
+	if err := database.DB.First(&review, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Review not found"})
+	}
+
+	if err := database.DB.Delete(&review).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete review"})
+	}
+	removeReviewPhotos(review.Photos)
+
+	recordAudit(c, "review.delete", "review", review.ID, review, nil)
+
+	return c.JSON(fiber.Map{"status": "success", "message": "Review deleted successfully"})
+}
+
+func moderateReview(c *fiber.Ctx, action, status, reason string) error {
+	var review models.Review
+	if err := database.DB.First(&review, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Review not found"})
+	}
+
+	before := review
+	now := time.Now()
+	moderatorID := sessionUserID(c)
+	// Only the moderation columns are written, so a reply stored meanwhile
+	// is kept.
+	if err := database.DB.Model(&review).Updates(map[string]interface{}{
+		"status":            status,
+		"moderation_reason": reason,
+		"moderated_by":      moderatorID,
+		"moderated_at":      now,
+	}).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update review"})
+	}
+	database.DB.First(&review, review.ID)
+
+	recordAudit(c, action, "review", review.ID, before, review)
+
+	return c.JSON(fiber.Map{"status": "success", "data": review})
+}
+
+// listReviews writes one page of the reviews of query with the masked
+// reviewer names, merging extra into the response. Who moderated a review
+// and why is left out unless moderation is set.
+func listReviews(c *fiber.Ctx, query *gorm.DB, moderation bool, extra fiber.Map) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to count reviews"})
+	}
+
+	reviews := []models.Review{}
+	if err := query.Preload("User").Order("created_at DESC, id DESC").
+		Offset((page - 1) * limit).Limit(limit).Find(&reviews).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch reviews"})
+	}
+	for i := range reviews {
+		if reviews[i].User != nil {
+			reviews[i].Reviewer = maskName(reviews[i].User.Username)
+		}
+		if !moderation {
+			hideModeration(&reviews[i])
+		}
+	}
+
+	response := fiber.Map{
+		"status": "success",
+		"data":   reviews,
+		"pagination": fiber.Map{
+			"page":        page,
+			"limit":       limit,
+			"total":       total,
+			"total_pages": int(math.Ceil(float64(total) / float64(limit))),
+		},
+	}
+	for key, value := range extra {
+		response[key] = value
+	}
+	return c.JSON(response)
+}
+
+// hideModeration clears who moderated a review and why, which only
+// moderators see.
+func hideModeration(review *models.Review) {
+	review.ModerationReason = ""
+	review.ModeratedBy = nil
+	review.ModeratedAt = nil
+}
+
+// ratingSummaries aggregates the published reviews per product or shop;
+// column is "product_id" or "shop_id". Every id gets a summary, empty when
+// it has no reviews.
+func ratingSummaries(db *gorm.DB, column string, ids []uint) (map[uint]models.RatingSummary, error) {
+	if column != "product_id" && column != "shop_id" {
+		return nil, errors.New("unknown rating column " + column)
+	}
+
+	summaries := map[uint]models.RatingSummary{}
+	for _, id := range ids {
+		summaries[id] = models.RatingSummary{Stars: map[int]int64{1: 0, 2: 0, 3: 0, 4: 0, 5: 0}}
+	}
+	if len(ids) == 0 {
+		return summaries, nil
+	}
+
+	var rows []struct {
+		ID     uint
+		Rating int
+		Count  int64
+	}
+	if err := db.Model(&models.Review{}).
+		Select(column+" AS id, rating, COUNT(*) AS count").
+		Where(column+" IN ? AND status = ?", ids, models.ReviewPublished).
+		Group(column + ", rating").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	sums := map[uint]int64{}
+	for _, row := range rows {
+		summary := summaries[row.ID]
+		summary.Stars[row.Rating] = row.Count
+		summary.Count += row.Count
+		summaries[row.ID] = summary
+		sums[row.ID] += int64(row.Rating) * row.Count
+	}
+	for id, summary := range summaries {
+		if summary.Count > 0 {
+			summary.Average = math.Round(float64(sums[id])/float64(summary.Count)*10) / 10
+			summaries[id] = summary
+		}
+	}
+	return summaries, nil
+}
+
+func removeReviewPhotos(photos []string) {
+	for _, photo := range photos {
+		_ = os.Remove("." + strings.TrimPrefix(photo, "http://127.0.0.1:3000"))
+	}
+}
@@ -0,0 +1,232 @@
+package controllers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"finpro/database"
+	"finpro/models"
+	"finpro/rbac"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,49}$`)
+
+func GetAllRoles(c *fiber.Ctx) error {
+	var roles []models.Role
+	if err := database.DB.Preload("Permissions").Order("name").Find(&roles).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch roles",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   roles,
+	})
+}
+
+func GetAllPermissions(c *fiber.Ctx) error {
+	var permissions []models.Permission
+	if err := database.DB.Order("name").Find(&permissions).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch permissions",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   permissions,
+	})
+}
+
+func CreateRole(c *fiber.Ctx) error {
+	var body struct {
+		Name        string   `json:"name"`
+		Description string   `json:"description"`
+		Permissions []string `json:"permissions"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	name := strings.ToLower(strings.TrimSpace(body.Name))
+	if !roleNamePattern.MatchString(name) {
+		return c.Status(400).JSON(fiber.Map{"error": "Role name must be 2-50 lowercase letters, digits or underscores"})
+	}
+
+	if err := validatePermissions(body.Permissions); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var count int64
+	database.DB.Model(&models.Role{}).Where("name = ?", name).Count(&count)
+	if count > 0 {
+		return c.Status(409).JSON(fiber.Map{"error": "Role already exists"})
+	}
+
+	role := models.Role{Name: name, Description: body.Description}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&role).Error; err != nil {
+			return err
+		}
+		return replaceRolePermissions(tx, name, body.Permissions)
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create role"})
+	}
+
+	if err := rbac.Load(database.DB); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to reload permissions"})
+	}
+
+	database.DB.Preload("Permissions").First(&role, "name = ?", name)
+
+	recordAudit(c, "role.create", "role", role.Name, nil, role)
+
+	return c.Status(201).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Role created successfully",
+		"data":    role,
+	})
+}
+
+func UpdateRolePermissions(c *fiber.Ctx) error {
+	name := c.Params("name")
+
+	var body struct {
+		Description *string  `json:"description"`
+		Permissions []string `json:"permissions"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	var role models.Role
+	if err := database.DB.Preload("Permissions").First(&role, "name = ?", name).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Role not found"})
+	}
+	before := role
+
+	if err := validatePermissions(body.Permissions); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// Without this an admin could lock every admin out of role management.
+	if role.Name == "admin" && !containsString(body.Permissions, rbac.RoleManage) {
+		return c.Status(400).JSON(fiber.Map{"error": "The admin role must keep the " + rbac.RoleManage + " permission"})
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if body.Description != nil {
+			if err := tx.Model(&role).Update("description", *body.Description).Error; err != nil {
+				return err
+			}
+		}
+		return replaceRolePermissions(tx, role.Name, body.Permissions)
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update role"})
+	}
+
+	if err := rbac.Load(database.DB); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to reload permissions"})
+	}
+
+	role.Permissions = nil
+	database.DB.Preload("Permissions").First(&role, "name = ?", role.Name)
+
+	recordAudit(c, "role.update", "role", role.Name, before, role)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Role updated successfully",
+		"data":    role,
+	})
+}
+
+func DeleteRole(c *fiber.Ctx) error {
+	name := c.Params("name")
+
+	if rbac.BuiltinRoles[name] {
+		return c.Status(400).JSON(fiber.Map{"error": "Built-in roles cannot be deleted"})
+	}
+
+	var role models.Role
+	if err := database.DB.Preload("Permissions").First(&role, "name = ?", name).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Role not found"})
+	}
+
+	var userCount int64
+	if err := database.DB.Model(&models.User{}).Where("role = ?", name).Count(&userCount).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to check role usage"})
+	}
+	if userCount > 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Role is still assigned to users"})
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role = ?", name).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&role).Error
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete role"})
+	}
+
+	if err := rbac.Load(database.DB); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to reload permissions"})
+	}
+
+	recordAudit(c, "role.delete", "role", role.Name, role, nil)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Role deleted successfully",
+	})
+}
+
+func validatePermissions(permissions []string) error {
+	for _, permission := range permissions {
+		if !rbac.Known(permission) {
+			return fmt.Errorf("Unknown permission: %s", permission)
+		}
+	}
+	return nil
+}
+
+func replaceRolePermissions(tx *gorm.DB, role string, permissions []string) error {
+	if err := tx.Where("role = ?", role).Delete(&models.RolePermission{}).Error; err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	for _, permission := range permissions {
+		if seen[permission] {
+			continue
+		}
+		seen[permission] = true
+
+		if err := tx.Create(&models.RolePermission{Role: role, Permission: permission}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"time"
+
+	"finpro/database"
+	"finpro/models"
+
+	"gorm.io/gorm"
+)
+
+// hiddenOwnerStatuses are account states whose shop and listings must not be
+// shown to other users.
+var hiddenOwnerStatuses = []string{models.UserStatusBanned, models.UserStatusDeleted}
+
+func hiddenShopIDs() *gorm.DB {
+	return database.DB.Table("shops").
+		Select("shops.id").
+		Joins("JOIN `user` owner ON owner.id = shops.user_id").
+		Where("owner.status IN ?", hiddenOwnerStatuses).
+		// A timed ban is lifted once status_until passed, like User.IsBlocked.
+		Where("owner.status = ? OR owner.status_until IS NULL OR owner.status_until > ?", models.UserStatusDeleted, time.Now())
+}
+
+// visibleProducts keeps published products only, see Product.IsPublished,
+// and drops products of shops whose owner is banned or deleted. Soft deleted
+// products are dropped by GORM itself.
+func visibleProducts(db *gorm.DB) *gorm.DB {
+	return db.Where("products.archived_at IS NULL AND products.shop_id NOT IN (?)", hiddenShopIDs()).
+		Where("(products.status = ? OR (products.status = ? AND products.publish_at <= ?))",
+			models.ProductStatusPublished, models.ProductStatusDraft, time.Now())
+}
+
+// wishlistProducts keeps the products buyers can wishlist: visible ones and
+// sold out ones, which are wishlisted to hear when they are back in stock.
+func wishlistProducts(db *gorm.DB) *gorm.DB {
+	return db.Where("products.archived_at IS NULL AND products.shop_id NOT IN (?)", hiddenShopIDs()).
+		Where("(products.status IN ? OR (products.status = ? AND products.publish_at <= ?))",
+			[]string{models.ProductStatusPublished, models.ProductStatusSoldOut}, models.ProductStatusDraft, time.Now())
+}
+
+func shopHidden(shopID uint) bool {
+	var count int64
+	database.DB.Table("(?) AS hidden", hiddenShopIDs()).Where("hidden.id = ?", shopID).Count(&count)
+	return count > 0
+}
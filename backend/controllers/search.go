@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"finpro/database"
+	"finpro/models"
+	"finpro/search"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// maxSearchHits caps how many ranked IDs a search hands to the listing query.
+const maxSearchHits = 1000
+
+// InitSearch selects the engine from SEARCH_ENGINE (mysql by default, or
+// memory) and rebuilds its index from the products table.
+func InitSearch() error {
+	switch engine := strings.ToLower(os.Getenv("SEARCH_ENGINE")); engine {
+	case "", "mysql":
+		mysql, err := search.NewMySQL(database.DB)
+		if err != nil {
+			return err
+		}
+		search.Init(mysql)
+	case "memory":
+		search.Init(search.NewMemory())
+	default:
+		return fmt.Errorf("unknown SEARCH_ENGINE %q", engine)
+	}
+
+	return RebuildSearchIndex()
+}
+
+// RebuildSearchIndex indexes every product again. Stale entries are harmless
+// since search results are always looked up in the products table.
+func RebuildSearchIndex() error {
+	var products []models.Product
+	return database.DB.Preload("Shop").Where("archived_at IS NULL").FindInBatches(&products, 500, func(*gorm.DB, int) error {
+		docs := make([]search.Document, 0, len(products))
+		for _, product := range products {
+			docs = append(docs, productDocument(product))
+		}
+		return search.Default().Index(docs...)
+	}).Error
+}
+
+func productDocument(product models.Product) search.Document {
+	return search.Document{
+		ID:          product.ID,
+		Name:        product.Name,
+		Description: product.Description,
+		// Brand and material are searched like the label.
+		Label:    strings.Join([]string{product.Label, product.Brand, product.Material}, " "),
+		ShopName: product.Shop.ShopName,
+	}
+}
+
+// indexProducts refreshes the search index for the given products. A failure
+// only makes search results stale, so it is logged and not returned.
+func indexProducts(ids ...uint) {
+	if len(ids) == 0 {
+		return
+	}
+
+	var products []models.Product
+	if err := database.DB.Preload("Shop").Find(&products, ids).Error; err != nil {
+		fmt.Printf("⚠️ Failed to load products for search index: %v\n", err)
+		return
+	}
+
+	docs := make([]search.Document, 0, len(products))
+	archived := []uint{}
+	for _, product := range products {
+		if product.ArchivedAt != nil {
+			archived = append(archived, product.ID)
+			continue
+		}
+		docs = append(docs, productDocument(product))
+	}
+	if err := search.Default().Index(docs...); err != nil {
+		fmt.Printf("⚠️ Failed to update search index: %v\n", err)
+	}
+	if len(archived) > 0 {
+		unindexProducts(archived...)
+	}
+}
+
+func unindexProducts(ids ...uint) {
+	if err := search.Default().Remove(ids...); err != nil {
+		fmt.Printf("⚠️ Failed to update search index: %v\n", err)
+	}
+}
+
+// indexShopProducts refreshes every product of a shop, e.g. after a rename.
+func indexShopProducts(shopID uint) {
+	var ids []uint
+	if err := database.DB.Model(&models.Product{}).Where("shop_id = ?", shopID).Pluck("id", &ids).Error; err != nil {
+		fmt.Printf("⚠️ Failed to load products for search index: %v\n", err)
+		return
+	}
+	indexProducts(ids...)
+}
+
+func SuggestProducts(c *fiber.Ctx) error {
+	prefix := strings.TrimSpace(c.Query("q"))
+	if prefix == "" {
+		return c.JSON(fiber.Map{"status": "success", "data": []search.Suggestion{}})
+	}
+
+	limit := 8
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > 20 {
+			return c.Status(400).JSON(fiber.Map{"status": "error", "message": "limit must be between 1 and 20"})
+		}
+		limit = n
+	}
+
+	// Ask for extra suggestions since some may belong to hidden shops.
+	suggestions, err := search.Default().Suggest(prefix, limit*2)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	ids := make([]uint, 0, len(suggestions))
+	for _, suggestion := range suggestions {
+		ids = append(ids, suggestion.ID)
+	}
+
+	var visible []uint
+	if len(ids) > 0 {
+		if err := database.DB.Model(&models.Product{}).Scopes(visibleProducts).
+			Where("products.id IN ?", ids).Pluck("products.id", &visible).Error; err != nil {
+			return c.Status(500).JSON(fiber.Map{"status": "error", "message": err.Error()})
+		}
+	}
+	allowed := map[uint]bool{}
+	for _, id := range visible {
+		allowed[id] = true
+	}
+
+	data := []fiber.Map{}
+	for _, suggestion := range suggestions {
+		if !allowed[suggestion.ID] {
+			continue
+		}
+		data = append(data, fiber.Map{"product_id": suggestion.ID, "text": suggestion.Text})
+		if len(data) == limit {
+			break
+		}
+	}
+
+	return c.JSON(fiber.Map{"status": "success", "data": data})
+}
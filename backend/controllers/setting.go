@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"finpro/database"
+	"finpro/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm/clause"
+)
+
+func GetSettings(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data": fiber.Map{
+			"require_admin_2fa":   adminTwoFactorRequired(),
+			"reservation_minutes": int(reservationWindow() / time.Minute),
+		},
+	})
+}
+
+func UpdateSettings(c *fiber.Ctx) error {
+	var body struct {
+		RequireAdmin2FA    *bool `json:"require_admin_2fa"`
+		ReservationMinutes *int  `json:"reservation_minutes"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if body.ReservationMinutes != nil && (*body.ReservationMinutes < 1 || *body.ReservationMinutes > maxReservationMinutes) {
+		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("reservation_minutes must be between 1 and %d", maxReservationMinutes)})
+	}
+
+	if body.RequireAdmin2FA != nil {
+		before := adminTwoFactorRequired()
+		if err := saveSetting(models.SettingRequireAdmin2FA, strconv.FormatBool(*body.RequireAdmin2FA)); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to update settings"})
+		}
+		recordAudit(c, "setting.update", "setting", models.SettingRequireAdmin2FA,
+			fiber.Map{"value": before}, fiber.Map{"value": *body.RequireAdmin2FA})
+	}
+
+	if body.ReservationMinutes != nil {
+		minutes := *body.ReservationMinutes
+		before := int(reservationWindow() / time.Minute)
+		if err := saveSetting(models.SettingReservationMinutes, strconv.Itoa(minutes)); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to update settings"})
+		}
+		recordAudit(c, "setting.update", "setting", models.SettingReservationMinutes,
+			fiber.Map{"value": before}, fiber.Map{"value": minutes})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Settings updated successfully",
+		"data": fiber.Map{
+			"require_admin_2fa":   adminTwoFactorRequired(),
+			"reservation_minutes": int(reservationWindow() / time.Minute),
+		},
+	})
+}
+
+func getSetting(key string, fallback string) string {
+	var setting models.Setting
+	if err := database.DB.First(&setting, "`key` = ?", key).Error; err != nil {
+		return fallback
+	}
+	return setting.Value
+}
+
+func saveSetting(key string, value string) error {
+	return database.DB.Clauses(clause.OnConflict{
+		UpdateAll: true,
+	}).Create(&models.Setting{Key: key, Value: value}).Error
+}
+
+func adminTwoFactorRequired() bool {
+	required, err := strconv.ParseBool(getSetting(models.SettingRequireAdmin2FA, "false"))
+	return err == nil && required
+}
@@ -3,9 +3,11 @@ package controllers
 import (
 	"finpro/database"
 	"finpro/models"
+	"finpro/rbac"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -38,6 +40,7 @@ func GetAllShopApprove(c *fiber.Ctx) error {
 			ID:            shop.ID,
 			UserID:        shop.UserID,
 			ShopName:      shop.ShopName,
+			Slug:          shop.Slug,
 			ShopTelephone: shop.ShopTelephone,
 			ShopAddress:   shop.ShopAddress,
 			AccountNumber: shop.AccountNumber,
@@ -78,6 +81,7 @@ func GetAllShopPending(c *fiber.Ctx) error {
 			ID:            shop.ID,
 			UserID:        shop.UserID,
 			ShopName:      shop.ShopName,
+			Slug:          shop.Slug,
 			ShopTelephone: shop.ShopTelephone,
 			ShopAddress:   shop.ShopAddress,
 			AccountNumber: shop.AccountNumber,
@@ -114,6 +118,9 @@ func AcceptRequestShop(c *fiber.Ctx) error {
 		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
 	}
 
+	beforeShop := shop
+	beforeUser := user
+
 	if body.Status {
 		shop.StatusAdmin = "approve"
 		if err := database.DB.Save(&shop).Error; err != nil {
@@ -125,6 +132,9 @@ func AcceptRequestShop(c *fiber.Ctx) error {
 			return c.Status(500).JSON(fiber.Map{"error": "Failed to update user role"})
 		}
 
+		recordAudit(c, "shop.approve", "shop", shop.ID, beforeShop, shop)
+		recordAudit(c, "user.role_change", "user", user.ID, beforeUser, user)
+
 		return c.Status(200).JSON(fiber.Map{
 			"status":  "success",
 			"message": "Shop has been accepted and user role updated to seller",
@@ -140,6 +150,8 @@ func AcceptRequestShop(c *fiber.Ctx) error {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete rejected shop"})
 	}
 
+	recordAudit(c, "shop.reject", "shop", shop.ID, beforeShop, nil)
+
 	return c.Status(200).JSON(fiber.Map{
 		"status":  "success",
 		"message": "Shop request has been rejected and deleted",
@@ -200,6 +212,7 @@ func CreateShop(c *fiber.Ctx) error {
 	shop := models.Shop{
 		UserID:        uint(userID),
 		ShopName:      shopName,
+		Slug:          models.ShopSlug(shopName, shopSlugTaken(0)),
 		ShopTelephone: shopTelephone,
 		ShopAddress:   shopAddress,
 		AccountNumber: accountNumber,
@@ -215,6 +228,7 @@ func CreateShop(c *fiber.Ctx) error {
 		ID:            shop.ID,
 		UserID:        shop.UserID,
 		ShopName:      shop.ShopName,
+		Slug:          shop.Slug,
 		ShopTelephone: shop.ShopTelephone,
 		ShopAddress:   shop.ShopAddress,
 		AccountNumber: shop.AccountNumber,
@@ -223,6 +237,8 @@ func CreateShop(c *fiber.Ctx) error {
 		CreatedAt:     shop.CreatedAt,
 	}	
 
+	recordAudit(c, "shop.create", "shop", shop.ID, nil, shopResponse)
+
 	return c.Status(201).JSON(fiber.Map{
 		"status":  "success",
 		"message": "Shop created successfully, waiting for admin approval",
@@ -238,26 +254,49 @@ func GetDetailShop(c *fiber.Ctx) error {
 		return c.Status(404).JSON(fiber.Map{"error": "Shop not found"})
 	}
 
+	role, _ := c.Locals("role").(string)
+	if shopHidden(shop.ID) && !rbac.Can(role, rbac.UserManage) {
+		return c.Status(404).JSON(fiber.Map{"error": "Shop not found"})
+	}
+
+	// Everyone else sees the storefront profile, without contact and
+	// payment details.
+	if shop.UserID != sessionUserID(c) && !rbac.Can(role, rbac.ShopApprove) {
+		if shop.StatusAdmin != "approve" {
+			return c.Status(404).JSON(fiber.Map{"error": "Shop not found"})
+		}
+		profile, err := storefrontProfile(&shop)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch shop"})
+		}
+		return c.Status(200).JSON(fiber.Map{
+			"status":  "success",
+			"message": "Shop details retrieved successfully",
+			"data":    profile,
+		})
+	}
+
+	detail, err := storefrontProfile(&shop)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch shop"})
+	}
+	detail["user_id"] = shop.UserID
+	detail["username"] = shop.User.Username
+	detail["email"] = shop.User.Email
+	detail["shop_telephone"] = shop.ShopTelephone
+	detail["shop_address"] = shop.ShopAddress
+	detail["account_number"] = shop.AccountNumber
+	detail["qris_picture"] = shop.QrisPicture
+	detail["created_at"] = shop.CreatedAt
+	detail["status_admin"] = shop.StatusAdmin
+
 	return c.Status(200).JSON(fiber.Map{
 		"status":  "success",
 		"message": "Shop details retrieved successfully",
-		"data": fiber.Map{
-			"id":             shop.ID,
-			"user_id":        shop.UserID,
-			"username":       shop.User.Username,
-			"email":          shop.User.Email,
-			"shop_name":      shop.ShopName,
-			"shop_telephone": shop.ShopTelephone,
-			"shop_address":   shop.ShopAddress,
-			"account_number": shop.AccountNumber,
-			"qris_picture":   shop.QrisPicture,
-			"created_at":     shop.CreatedAt,
-			"status_admin":   shop.StatusAdmin,
-		},
+		"data":    detail,
 	})
 }
 
-
 func EditShop(c *fiber.Ctx) error {
 	shopID := c.Params("id")
 
@@ -274,6 +313,8 @@ func EditShop(c *fiber.Ctx) error {
 		return c.Status(403).JSON(fiber.Map{"error": "Cant edit other user's shop"})
 	}
 
+	before := shop
+
 	shopName := c.FormValue("shop_name")
 	shopTelephone := c.FormValue("shop_telephone")
 	shopAddress := c.FormValue("shop_address")
@@ -291,6 +332,18 @@ func EditShop(c *fiber.Ctx) error {
 	if accountNumber != "" {
 		shop.AccountNumber = accountNumber
 	}
+	// The slug stays on rename so storefront links keep working; it only
+	// changes when one is sent.
+	if slug := c.FormValue("slug"); slug != "" {
+		slug = models.Slugify(slug)
+		if _, err := strconv.Atoi(slug); err == nil || slug == "" || len(slug) > 120 {
+			return c.Status(400).JSON(fiber.Map{"error": "Shop slug must contain letters and be at most 120 characters"})
+		}
+		if shopSlugTaken(shop.ID)(slug) {
+			return c.Status(409).JSON(fiber.Map{"error": "Shop slug already exists"})
+		}
+		shop.Slug = slug
+	}
 
 	file, err := c.FormFile("qris_picture")
 	if file != nil && err == nil {
@@ -327,10 +380,16 @@ func EditShop(c *fiber.Ctx) error {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to update shop", "details": err.Error()})
 	}
 
+	recordAudit(c, "shop.update", "shop", shop.ID, before, shop)
+	if shop.ShopName != before.ShopName {
+		indexShopProducts(shop.ID)
+	}
+
 	shopResponse := models.ShopResponse{
 		ID:            shop.ID,
 		UserID:        shop.UserID,
 		ShopName:      shop.ShopName,
+		Slug:          shop.Slug,
 		ShopTelephone: shop.ShopTelephone,
 		ShopAddress:   shop.ShopAddress,
 		AccountNumber: shop.AccountNumber,
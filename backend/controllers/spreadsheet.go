@@ -0,0 +1,26 @@
+package controllers
+
+import "strings"
+
+// formulaPrefixes are the first characters that make spreadsheet apps read
+// a CSV cell as a formula.
+const formulaPrefixes = "=+-@\t\r"
+
+// spreadsheetCell escapes user supplied text for CSV exports: a value that
+// would start a formula gets a leading apostrophe, so spreadsheet apps show
+// it as text instead of running it.
+func spreadsheetCell(value string) string {
+	if value != "" && strings.ContainsRune(formulaPrefixes, rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// spreadsheetValue undoes spreadsheetCell for files exported by us and
+// imported again.
+func spreadsheetValue(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"finpro/database"
+	"finpro/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetStorefront is the public page of an approved shop, by ID or slug: the
+// shop profile with its rating, total sales and join date, and one page of
+// its published products. It takes the product listing parameters (page,
+// limit, sort, price, category and attribute filters).
+func GetStorefront(c *fiber.Ctx) error {
+	shop, err := findShop(c.Params("shop"))
+	if err != nil || shop.StatusAdmin != "approve" || shopHidden(shop.ID) {
+		return c.Status(404).JSON(fiber.Map{"error": "Shop not found"})
+	}
+
+	q, err := parseProductQuery(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+	q.ShopID = shop.ID
+
+	profile, err := storefrontProfile(shop)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch shop"})
+	}
+	products, total, corrected, err := findProducts(q)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+	profile["products"] = products
+
+	response := fiber.Map{
+		"status": "success",
+		"data":   profile,
+		"pagination": fiber.Map{
+			"page":        q.Page,
+			"limit":       q.Limit,
+			"total":       total,
+			"total_pages": int(math.Ceil(float64(total) / float64(q.Limit))),
+		},
+	}
+	if corrected != "" {
+		response["corrected_query"] = corrected
+	}
+	return c.JSON(response)
+}
+
+// storefrontProfile returns the public fields of a shop. Contact and
+// payment details are left out; GetDetailShop adds them for the owner and
+// admins.
+func storefrontProfile(shop *models.Shop) (fiber.Map, error) {
+	now := time.Now()
+	sales := []models.Discount{}
+	if err := database.DB.Where("shop_id = ? AND product_id IS NULL AND starts_at <= ? AND ends_at > ?", shop.ID, now, now).
+		Order("ends_at").Find(&sales).Error; err != nil {
+		return nil, err
+	}
+
+	ratings, err := ratingSummaries(database.DB, "shop_id", []uint{shop.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	// Units of delivered orders; cancelled and open orders do not count.
+	var totalSales int64
+	if err := database.DB.Table("orderitem").
+		Joins("JOIN `order` o ON o.id = orderitem.order_id").
+		Where("o.shop_id = ? AND o.status_shipping = ? AND o.deleted_at IS NULL", shop.ID, "delivered").
+		Select("COALESCE(SUM(orderitem.quantity), 0)").Scan(&totalSales).Error; err != nil {
+		return nil, err
+	}
+
+	return fiber.Map{
+		"id":          shop.ID,
+		"slug":        shop.Slug,
+		"shop_name":   shop.ShopName,
+		"joined_at":   shop.CreatedAt,
+		"rating":      ratings[shop.ID],
+		"total_sales": totalSales,
+		"sales":       sales,
+	}, nil
+}
+
+// findShop accepts a shop ID or slug.
+func findShop(value string) (*models.Shop, error) {
+	value = strings.TrimSpace(value)
+
+	var shop models.Shop
+	if id, err := strconv.ParseUint(value, 10, 64); err == nil {
+		if err := database.DB.First(&shop, id).Error; err != nil {
+			return nil, err
+		}
+		return &shop, nil
+	}
+
+	if err := database.DB.Where("slug = ?", models.Slugify(value)).First(&shop).Error; err != nil {
+		return nil, err
+	}
+	return &shop, nil
+}
+
+// shopSlugTaken reports whether a slug belongs to a shop other than exceptID.
+func shopSlugTaken(exceptID uint) func(slug string) bool {
+	return func(slug string) bool {
+		var count int64
+		database.DB.Model(&models.Shop{}).Where("slug = ? AND id <> ?", slug, exceptID).Count(&count)
+		return count > 0
+	}
+}
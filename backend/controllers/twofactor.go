@@ -0,0 +1,455 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"finpro/database"
+	"finpro/models"
+	"finpro/totp"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/skip2/go-qrcode"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	twoFactorIssuer       = "Thriftoria"
+	recoveryCodeCount     = 10
+	maxTwoFactorAttempts  = 5
+	twoFactorLockDuration = 15 * time.Minute
+)
+
+// twoFactorFailures counts wrong second-factor codes per user so the six
+// digit code cannot be brute forced within the lifetime of an mfa_token.
+var twoFactorFailures = struct {
+	sync.Mutex
+	count       map[int]int
+	lockedUntil map[int]time.Time
+}{count: map[int]int{}, lockedUntil: map[int]time.Time{}}
+
+func Setup2FA(c *fiber.Ctx) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	if user.TwoFactorEnabled {
+		return c.Status(400).JSON(fiber.Map{"error": "Two-factor authentication is already enabled"})
+	}
+
+	setup, err := startTwoFactorSetup(user)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to start two-factor setup"})
+	}
+
+	recordAudit(c, "user.2fa_setup", "user", user.ID, nil, nil)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Scan the QR code with your authenticator app, then confirm with a code",
+		"data":    setup,
+	})
+}
+
+func Enable2FA(c *fiber.Ctx) error {
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	codes, status, err := enableTwoFactor(user, body.Code)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	recordAudit(c, "user.2fa_enable", "user", user.ID, fiber.Map{"two_factor_enabled": false}, fiber.Map{"two_factor_enabled": true})
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Two-factor authentication enabled. Store the recovery codes somewhere safe",
+		"data":    fiber.Map{"recovery_codes": codes},
+	})
+}
+
+func Disable2FA(c *fiber.Ctx) error {
+	var body struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	if !user.TwoFactorEnabled {
+		return c.Status(400).JSON(fiber.Map{"error": "Two-factor authentication is not enabled"})
+	}
+
+	if user.Role == "admin" && adminTwoFactorRequired() {
+		return c.Status(403).JSON(fiber.Map{"error": "Two-factor authentication is required for admin accounts"})
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(body.Password)); err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Incorrect password"})
+	}
+
+	if !useTwoFactorCode(user, body.Code) {
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid two-factor code"})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"two_factor_enabled": false,
+			"two_factor_secret":  "",
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to disable two-factor authentication"})
+	}
+
+	recordAudit(c, "user.2fa_disable", "user", user.ID, fiber.Map{"two_factor_enabled": true}, fiber.Map{"two_factor_enabled": false})
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+func RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	if !user.TwoFactorEnabled {
+		return c.Status(400).JSON(fiber.Map{"error": "Two-factor authentication is not enabled"})
+	}
+
+	if !useTwoFactorCode(user, body.Code) {
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid two-factor code"})
+	}
+
+	codes, err := generateRecoveryCodes(database.DB, user.ID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to generate recovery codes"})
+	}
+
+	recordAudit(c, "user.2fa_recovery_codes", "user", user.ID, nil, nil)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "New recovery codes generated, the old ones no longer work",
+		"data":    fiber.Map{"recovery_codes": codes},
+	})
+}
+
+// VerifyLogin2FA is the second login step for users with 2FA enabled. It
+// accepts either a TOTP code or one unused recovery code.
+func VerifyLogin2FA(c *fiber.Ctx) error {
+	input := new(models.TwoFactorLoginInput)
+	if err := c.BodyParser(input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid request body"})
+	}
+
+	userID, err := parsePendingToken(input.MfaToken, "2fa")
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Login session expired, please login again"})
+	}
+
+	var user models.User
+	if err := database.DB.Preload("Shop").First(&user, userID).Error; err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Login session expired, please login again"})
+	}
+
+	if twoFactorLocked(user.ID) {
+		return c.Status(429).JSON(fiber.Map{"error": "Too many invalid codes, try again later"})
+	}
+
+	verified := false
+	if input.Code != "" {
+		verified = useTwoFactorCode(&user, input.Code)
+	} else if input.RecoveryCode != "" {
+		verified = useRecoveryCode(user.ID, input.RecoveryCode)
+	}
+
+	if !verified {
+		recordTwoFactorFailure(user.ID)
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid two-factor code"})
+	}
+	clearTwoFactorFailures(user.ID)
+
+	if user.IsBlocked(time.Now()) {
+		return accountBlockedResponse(c, &user)
+	}
+
+	return completeLogin(c, &user, nil)
+}
+
+// SetupLogin2FA lets an admin who is forced to use 2FA enroll during login,
+// before any session exists.
+func SetupLogin2FA(c *fiber.Ctx) error {
+	input := new(models.TwoFactorLoginInput)
+	if err := c.BodyParser(input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid request body"})
+	}
+
+	userID, err := parsePendingToken(input.MfaToken, "2fa_setup")
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Login session expired, please login again"})
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Login session expired, please login again"})
+	}
+
+	// The setup token stays valid for its whole lifetime, so it must not
+	// replace the secret once 2FA is enabled.
+	if user.TwoFactorEnabled {
+		return c.Status(400).JSON(fiber.Map{"error": "Two-factor authentication is already enabled"})
+	}
+	if user.IsBlocked(time.Now()) {
+		return accountBlockedResponse(c, &user)
+	}
+
+	setup, err := startTwoFactorSetup(&user)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to start two-factor setup"})
+	}
+
+	recordAuditAs(c, &user, "user.2fa_setup", "user", user.ID, nil, nil)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Scan the QR code with your authenticator app, then confirm with a code",
+		"data":    setup,
+	})
+}
+
+func EnableLogin2FA(c *fiber.Ctx) error {
+	input := new(models.TwoFactorLoginInput)
+	if err := c.BodyParser(input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid request body"})
+	}
+
+	userID, err := parsePendingToken(input.MfaToken, "2fa_setup")
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Login session expired, please login again"})
+	}
+
+	var user models.User
+	if err := database.DB.Preload("Shop").First(&user, userID).Error; err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Login session expired, please login again"})
+	}
+
+	if user.IsBlocked(time.Now()) {
+		return accountBlockedResponse(c, &user)
+	}
+
+	codes, status, err := enableTwoFactor(&user, input.Code)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	recordAuditAs(c, &user, "user.2fa_enable", "user", user.ID, fiber.Map{"two_factor_enabled": false}, fiber.Map{"two_factor_enabled": true})
+
+	return completeLogin(c, &user, fiber.Map{"recovery_codes": codes})
+}
+
+func currentUser(c *fiber.Ctx) (*models.User, error) {
+	userToken := c.Locals("user").(*jwt.Token)
+	claims := userToken.Claims.(jwt.MapClaims)
+
+	var user models.User
+	if err := database.DB.First(&user, claims["id"]).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// startTwoFactorSetup stores a fresh, not yet enabled secret on the user and
+// returns what the authenticator app needs to import it.
+func startTwoFactorSetup(user *models.User) (fiber.Map, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := database.DB.Model(user).Update("two_factor_secret", secret).Error; err != nil {
+		return nil, err
+	}
+
+	uri := totp.URI(twoFactorIssuer, user.Email, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		return nil, err
+	}
+
+	return fiber.Map{
+		"secret":      secret,
+		"otpauth_url": uri,
+		"qr_code":     "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	}, nil
+}
+
+func enableTwoFactor(user *models.User, code string) ([]string, int, error) {
+	if user.TwoFactorEnabled {
+		return nil, 400, fmt.Errorf("Two-factor authentication is already enabled")
+	}
+
+	if user.TwoFactorSecret == "" {
+		return nil, 400, fmt.Errorf("Two-factor setup has not been started")
+	}
+
+	if !useTwoFactorCode(user, code) {
+		return nil, 401, fmt.Errorf("Invalid two-factor code")
+	}
+
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("two_factor_enabled", true).Error; err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = generateRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, 500, fmt.Errorf("Failed to enable two-factor authentication")
+	}
+
+	return codes, 200, nil
+}
+
+// useTwoFactorCode checks a TOTP code of the user and uses it up: the time
+// step of the code is stored, and codes of that step or earlier are refused
+// from then on. The conditional update keeps two requests with the same
+// code from both passing.
+func useTwoFactorCode(user *models.User, code string) bool {
+	step, ok := totp.ValidateAfter(user.TwoFactorSecret, code, time.Now(), user.TwoFactorLastStep)
+	if !ok {
+		return false
+	}
+
+	result := database.DB.Model(&models.User{}).
+		Where("id = ? AND two_factor_last_step < ?", user.ID, step).
+		Update("two_factor_last_step", step)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false
+	}
+	user.TwoFactorLastStep = step
+	return true
+}
+
+// generateRecoveryCodes replaces every recovery code of the user. Only a
+// bcrypt hash is stored, the plain codes are shown to the user once.
+func generateRecoveryCodes(tx *gorm.DB, userID int) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw := make([]byte, 5)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		code := hex.EncodeToString(raw)
+		code = code[:5] + "-" + code[5:]
+
+		hash, err := bcrypt.GenerateFromPassword([]byte(normalizeRecoveryCode(code)), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Create(&models.RecoveryCode{
+			UserID:   userID,
+			CodeHash: string(hash),
+		}).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+// useRecoveryCode marks the unused recovery code of the user matching code
+// as used. The hashes are salted, so each one is compared in turn.
+func useRecoveryCode(userID int, code string) bool {
+	var codes []models.RecoveryCode
+	if err := database.DB.Where("user_id = ? AND used_at IS NULL", userID).Find(&codes).Error; err != nil {
+		return false
+	}
+
+	normalized := []byte(normalizeRecoveryCode(code))
+	for _, stored := range codes {
+		if bcrypt.CompareHashAndPassword([]byte(stored.CodeHash), normalized) != nil {
+			continue
+		}
+		result := database.DB.Model(&models.RecoveryCode{}).
+			Where("id = ? AND used_at IS NULL", stored.ID).
+			Update("used_at", time.Now())
+		return result.Error == nil && result.RowsAffected == 1
+	}
+	return false
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+func twoFactorLocked(userID int) bool {
+	twoFactorFailures.Lock()
+	defer twoFactorFailures.Unlock()
+
+	return time.Now().Before(twoFactorFailures.lockedUntil[userID])
+}
+
+func recordTwoFactorFailure(userID int) {
+	twoFactorFailures.Lock()
+	defer twoFactorFailures.Unlock()
+
+	twoFactorFailures.count[userID]++
+	if twoFactorFailures.count[userID] >= maxTwoFactorAttempts {
+		twoFactorFailures.lockedUntil[userID] = time.Now().Add(twoFactorLockDuration)
+		twoFactorFailures.count[userID] = 0
+	}
+}
+
+func clearTwoFactorFailures(userID int) {
+	twoFactorFailures.Lock()
+	defer twoFactorFailures.Unlock()
+
+	delete(twoFactorFailures.count, userID)
+	delete(twoFactorFailures.lockedUntil, userID)
+}
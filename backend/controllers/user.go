@@ -1,8 +1,11 @@
 package controllers
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"finpro/database"
 	"finpro/models"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -12,6 +15,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v4"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 func GetUserById(c *fiber.Ctx) error {
@@ -38,7 +42,12 @@ func GetUserById(c *fiber.Ctx) error {
 func GetAllUsers(c *fiber.Ctx) error {
 	var users []models.User
 
-	if err := database.DB.Where("role != ?", "admin").Find(&users).Error; err != nil {
+	query := database.DB.Where("role != ?", "admin")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Find(&users).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Failed to fetch users",
@@ -84,6 +93,8 @@ func UpdateProfile(c *fiber.Ctx) error {
 		})
 	}
 
+		before := user
+
 		username := c.FormValue("username")
 		email := c.FormValue("email")
 		address := c.FormValue("address")
@@ -167,9 +178,234 @@ func UpdateProfile(c *fiber.Ctx) error {
 		})
 	}
 
+	recordAudit(c, "user.update_profile", "user", user.ID, before, user)
+
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"status":  "success",
 		"message": "Profile updated successfully",
 		"data":    user,
 	})
-}
\ No newline at end of file
+}
+func SuspendUser(c *fiber.Ctx) error {
+	return changeUserStatus(c, models.UserStatusSuspended)
+}
+
+func BanUser(c *fiber.Ctx) error {
+	return changeUserStatus(c, models.UserStatusBanned)
+}
+
+// changeUserStatus suspends or bans a user. Suspensions need an end time, a
+// ban without "until" is permanent.
+func changeUserStatus(c *fiber.Ctx, newStatus string) error {
+	var body struct {
+		Reason string     `json:"reason"`
+		Until  *time.Time `json:"until"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body, until must be RFC 3339"})
+	}
+
+	if strings.TrimSpace(body.Reason) == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Reason is required"})
+	}
+
+	if newStatus == models.UserStatusSuspended && body.Until == nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Suspension end time (until) is required"})
+	}
+
+	if body.Until != nil && !body.Until.After(time.Now()) {
+		return c.Status(400).JSON(fiber.Map{"error": "until must be in the future"})
+	}
+
+	user, status, err := managedUser(c)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	before := *user
+
+	if err := database.DB.Model(user).Updates(map[string]interface{}{
+		"status":        newStatus,
+		"status_reason": body.Reason,
+		"status_until":  body.Until,
+	}).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update user status"})
+	}
+
+	recordAudit(c, "user."+newStatus, "user", user.ID, before, user)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "User has been " + newStatus,
+		"data":    user,
+	})
+}
+
+func ReinstateUser(c *fiber.Ctx) error {
+	user, status, err := managedUser(c)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	before := *user
+
+	if user.Status == models.UserStatusDeleted {
+		return c.Status(400).JSON(fiber.Map{"error": "Deleted users cannot be reinstated"})
+	}
+
+	if err := database.DB.Model(user).Updates(map[string]interface{}{
+		"status":        models.UserStatusActive,
+		"status_reason": "",
+		"status_until":  nil,
+	}).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to reinstate user"})
+	}
+
+	recordAudit(c, "user.reinstate", "user", user.ID, before, user)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "User has been reinstated",
+		"data":    user,
+	})
+}
+
+func ChangeUserRole(c *fiber.Ctx) error {
+	var body struct {
+		Role string `json:"role"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	var role models.Role
+	if err := database.DB.First(&role, "name = ?", body.Role).Error; err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Unknown role"})
+	}
+
+	user, status, err := managedUser(c)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	before := *user
+
+	if role.Name == "seller" {
+		var shopCount int64
+		database.DB.Model(&models.Shop{}).Where("user_id = ? AND status_admin = ?", user.ID, "approve").Count(&shopCount)
+		if shopCount == 0 {
+			return c.Status(400).JSON(fiber.Map{"error": "User needs an approved shop to become a seller"})
+		}
+	}
+
+	if err := database.DB.Model(user).Update("role", role.Name).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to change user role"})
+	}
+
+	recordAudit(c, "user.role_change", "user", user.ID, before, user)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "User role changed to " + role.Name,
+		"data":    user,
+	})
+}
+
+// ForcePasswordReset ends the sessions of a user and makes the next login
+// ask for a new password.
+func ForcePasswordReset(c *fiber.Ctx) error {
+	user, status, err := managedUser(c)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	before := *user
+
+	if err := database.DB.Model(user).Update("must_reset_password", true).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to force password reset"})
+	}
+
+	recordAudit(c, "user.force_password_reset", "user", user.ID, before, user)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "User must reset the password on next login",
+		"data":    user,
+	})
+}
+
+// DeleteUser anonymizes the account instead of removing the row, so orders
+// and sales of other users keep their history.
+func DeleteUser(c *fiber.Ctx) error {
+	user, status, err := managedUser(c)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	before := *user
+
+	if user.Status == models.UserStatusDeleted {
+		return c.Status(400).JSON(fiber.Map{"error": "User is already deleted"})
+	}
+
+	placeholder := make([]byte, 16)
+	if _, err := rand.Read(placeholder); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete user"})
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(hex.EncodeToString(placeholder)), bcrypt.DefaultCost)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete user"})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"username":            "deleted-user-" + strconv.Itoa(user.ID),
+			"email":               "deleted-" + strconv.Itoa(user.ID) + "@deleted.invalid",
+			"password":            string(hashedPassword),
+			"address":             "",
+			"telephone":           "",
+			"profile_picture":     "https://i.pravatar.cc/150",
+			"two_factor_enabled":  false,
+			"two_factor_secret":   "",
+			"must_reset_password": false,
+			"status":              models.UserStatusDeleted,
+			"status_reason":       "Deleted by admin",
+			"status_until":        nil,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.CartItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete user"})
+	}
+
+	recordAudit(c, "user.delete", "user", user.ID, before, user)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "User has been deleted",
+	})
+}
+
+// managedUser loads the user an admin action targets, refusing the admin's
+// own account.
+func managedUser(c *fiber.Ctx) (*models.User, int, error) {
+	userToken := c.Locals("user").(*jwt.Token)
+	claims := userToken.Claims.(jwt.MapClaims)
+	adminID := int(claims["id"].(float64))
+
+	targetID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, 400, fmt.Errorf("Invalid user ID")
+	}
+
+	if targetID == adminID {
+		return nil, 400, fmt.Errorf("You cannot change your own account here")
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, targetID).Error; err != nil {
+		return nil, 404, fmt.Errorf("User not found")
+	}
+
+	return &user, 200, nil
+}
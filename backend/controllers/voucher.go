@@ -0,0 +1,493 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"finpro/database"
+	"finpro/models"
+	"finpro/rbac"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var voucherCodePattern = regexp.MustCompile(`^[A-Z0-9_-]{3,32}$`)
+
+type voucherError struct {
+	message string
+}
+
+func (e *voucherError) Error() string {
+	return e.message
+}
+
+func normalizeVoucherCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// GetVouchers lists the vouchers the caller manages: every voucher for
+// admins, optionally narrowed with ?shop_id= or ?platform=true, and the
+// vouchers of the own shop for sellers. ?active=true only returns vouchers
+// that can be redeemed now.
+func GetVouchers(c *fiber.Ctx) error {
+	shop, err := voucherShop(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	query := database.DB.Model(&models.Voucher{})
+	switch {
+	case shop != nil:
+		query = query.Where("shop_id = ?", shop.ID)
+	case c.QueryBool("platform"):
+		query = query.Where("shop_id IS NULL")
+	case c.Query("shop_id") != "":
+		query = query.Where("shop_id = ?", c.Query("shop_id"))
+	}
+	if c.QueryBool("active") {
+		now := time.Now()
+		query = query.Where("active = ? AND starts_at <= ? AND ends_at > ?", true, now, now)
+	}
+
+	vouchers := []models.Voucher{}
+	if err := query.Order("created_at DESC, id DESC").Find(&vouchers).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch vouchers"})
+	}
+
+	return c.JSON(fiber.Map{"status": "success", "data": vouchers})
+}
+
+// CreateVoucher adds a voucher to the caller's shop. Admins create platform
+// vouchers, or vouchers of the shop given in shop_id.
+func CreateVoucher(c *fiber.Ctx) error {
+	shop, err := voucherShop(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var input models.VoucherInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	voucher := models.Voucher{Active: true, StartsAt: time.Now()}
+	if shop != nil {
+		voucher.ShopID = &shop.ID
+	}
+	if status, err := applyVoucherInput(&voucher, input, shop); err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := database.DB.Create(&voucher).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create voucher"})
+	}
+
+	recordAudit(c, "voucher.create", "voucher", voucher.ID, nil, voucher)
+
+	return c.Status(201).JSON(fiber.Map{"status": "success", "data": voucher})
+}
+
+func UpdateVoucher(c *fiber.Ctx) error {
+	voucher, shop, status, err := ownedVoucher(c)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var input models.VoucherInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	before := *voucher
+	if status, err := applyVoucherInput(voucher, input, shop); err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// used_count is left out so that redemptions made in the meantime are
+	// not overwritten.
+	if err := database.DB.Omit("used_count").Save(voucher).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update voucher"})
+	}
+
+	recordAudit(c, "voucher.update", "voucher", voucher.ID, before, voucher)
+
+	return c.JSON(fiber.Map{"status": "success", "data": voucher})
+}
+
+// DeleteVoucher removes a voucher nobody has redeemed yet. Redeemed vouchers
+// stay for the orders that used them and can only be deactivated.
+func DeleteVoucher(c *fiber.Ctx) error {
+	voucher, _, status, err := ownedVoucher(c)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if voucher.UsedCount > 0 {
+		return c.Status(409).JSON(fiber.Map{"error": "Voucher has already been used, deactivate it instead"})
+	}
+
+	if err := database.DB.Delete(voucher).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete voucher"})
+	}
+
+	recordAudit(c, "voucher.delete", "voucher", voucher.ID, voucher, nil)
+
+	return c.JSON(fiber.Map{"status": "success", "message": "Voucher deleted successfully"})
+}
+
+// ValidateVoucher previews the discount of a voucher on the selected cart
+// items without redeeming it. The voucher is checked again at checkout.
+func ValidateVoucher(c *fiber.Ctx) error {
+	type Request struct {
+		Code    string `json:"code"`
+		ShopID  uint   `json:"shop_id"`
+		CartIDs []uint `json:"cart_ids"`
+	}
+
+	var body Request
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	code := normalizeVoucherCode(body.Code)
+	if code == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Voucher code is required"})
+	}
+	if len(body.CartIDs) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "No cart items selected"})
+	}
+
+	userToken := c.Locals("user").(*jwt.Token)
+	claims := userToken.Claims.(jwt.MapClaims)
+	userID := uint(claims["id"].(float64))
+
+	var cartItems []models.CartItem
+	if err := database.DB.Preload("Product").Preload("Variant").
+		Where("id IN ? AND user_id = ?", body.CartIDs, userID).
+		Find(&cartItems).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch selected cart items"})
+	}
+	if len(cartItems) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "No valid cart items found"})
+	}
+
+	now := time.Now()
+	checks, err := revalidateCart(cartItems, now)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to check cart items"})
+	}
+
+	var voucher models.Voucher
+	if err := database.DB.Where("code = ?", code).First(&voucher).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Voucher not found"})
+	}
+
+	eligible, discount, err := quoteVoucher(database.DB, &voucher, userID, body.ShopID, checks, now)
+	if err != nil {
+		var voucherErr *voucherError
+		if errors.As(err, &voucherErr) {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to check voucher"})
+	}
+
+	var subtotal float64
+	for _, check := range checks {
+		if check.Available {
+			subtotal += check.Quote.Price * float64(check.Item.Quantity)
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data": fiber.Map{
+			"code":              voucher.Code,
+			"name":              voucher.Name,
+			"subtotal":          subtotal,
+			"eligible_subtotal": eligible,
+			"discount":          discount,
+			"total":             subtotal - discount,
+		},
+		"warnings": cartWarnings(checks),
+	})
+}
+
+// redeemVoucher locks the voucher, checks it against the order and counts
+// the use. It must run in the checkout transaction, so that the count is
+// rolled back with a failed order and concurrent checkouts cannot exceed
+// the limits.
+func redeemVoucher(tx *gorm.DB, code string, userID, shopID uint, checks []cartCheck, now time.Time) (*models.Voucher, float64, error) {
+	var voucher models.Voucher
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("code = ?", code).First(&voucher).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, 0, &voucherError{message: "Voucher not found"}
+		}
+		return nil, 0, err
+	}
+
+	_, discount, err := quoteVoucher(tx, &voucher, userID, shopID, checks, now)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := tx.Model(&voucher).Update("used_count", gorm.Expr("used_count + 1")).Error; err != nil {
+		return nil, 0, err
+	}
+	return &voucher, discount, nil
+}
+
+// quoteVoucher returns the subtotal of the items the voucher applies to and
+// the discount on them, or a voucherError when the buyer cannot use it.
+func quoteVoucher(db *gorm.DB, voucher *models.Voucher, userID, shopID uint, checks []cartCheck, now time.Time) (float64, float64, error) {
+	if !voucher.ActiveAt(now) {
+		return 0, 0, &voucherError{message: "This voucher is not valid at the moment"}
+	}
+	if voucher.ShopID != nil && *voucher.ShopID != shopID {
+		return 0, 0, &voucherError{message: "This voucher can only be used in the shop that issued it"}
+	}
+	if voucher.UsageLimit != nil && voucher.UsedCount >= *voucher.UsageLimit {
+		return 0, 0, &voucherError{message: "This voucher has been fully redeemed"}
+	}
+	if voucher.PerUserLimit != nil {
+		var used int64
+		if err := db.Model(&models.VoucherRedemption{}).
+			Where("voucher_id = ? AND user_id = ?", voucher.ID, userID).
+			Count(&used).Error; err != nil {
+			return 0, 0, err
+		}
+		if used >= int64(*voucher.PerUserLimit) {
+			return 0, 0, &voucherError{message: "You have already used this voucher the maximum number of times"}
+		}
+	}
+
+	var categoryIDs []uint
+	if len(voucher.CategoryIDs) > 0 {
+		categories, err := loadCategories()
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, id := range voucher.CategoryIDs {
+			categoryIDs = append(categoryIDs, categoryDescendants(categories, id)...)
+		}
+	}
+
+	var eligible float64
+	for _, check := range checks {
+		if !check.Available || !voucherCovers(voucher, &check.Item.Product, categoryIDs) {
+			continue
+		}
+		eligible += check.Quote.Price * float64(check.Item.Quantity)
+	}
+
+	if eligible == 0 {
+		return 0, 0, &voucherError{message: "This voucher does not apply to any of the selected items"}
+	}
+	if eligible < voucher.MinSpend {
+		return 0, 0, &voucherError{message: fmt.Sprintf("Spend at least %.0f on eligible items to use this voucher", voucher.MinSpend)}
+	}
+
+	return eligible, voucher.Amount(eligible), nil
+}
+
+// voucherCovers reports whether an item counts towards the voucher.
+// categoryIDs are the restricted categories with their subcategories.
+func voucherCovers(voucher *models.Voucher, product *models.Product, categoryIDs []uint) bool {
+	if voucher.ShopID != nil && product.ShopID != *voucher.ShopID {
+		return false
+	}
+	if !voucher.Restricted() {
+		return true
+	}
+	if containsUint(voucher.ProductIDs, product.ID) {
+		return true
+	}
+	return product.CategoryID != nil && containsUint(categoryIDs, *product.CategoryID)
+}
+
+// voucherShop returns the shop whose vouchers the caller manages, or nil for
+// admins, who manage every voucher.
+func voucherShop(c *fiber.Ctx) (*models.Shop, error) {
+	if role, _ := c.Locals("role").(string); rbac.Can(role, rbac.VoucherManage) {
+		return nil, nil
+	}
+	return sellerShop(c)
+}
+
+func ownedVoucher(c *fiber.Ctx) (*models.Voucher, *models.Shop, int, error) {
+	shop, err := voucherShop(c)
+	if err != nil {
+		return nil, nil, fiber.StatusBadRequest, err
+	}
+
+	var voucher models.Voucher
+	if err := database.DB.First(&voucher, c.Params("id")).Error; err != nil {
+		return nil, nil, 404, fmt.Errorf("Voucher not found")
+	}
+	if shop != nil && (voucher.ShopID == nil || *voucher.ShopID != shop.ID) {
+		return nil, nil, fiber.StatusForbidden, fmt.Errorf("Kamu tidak memiliki izin untuk mengubah voucher ini")
+	}
+	return &voucher, shop, 0, nil
+}
+
+// applyVoucherInput validates the input onto the voucher. shop is the
+// seller's shop, or nil for admins.
+func applyVoucherInput(voucher *models.Voucher, input models.VoucherInput, shop *models.Shop) (int, error) {
+	if input.ShopID != nil {
+		switch {
+		case shop != nil:
+			if *input.ShopID != shop.ID {
+				return fiber.StatusForbidden, fmt.Errorf("Sellers can only manage vouchers of their own shop")
+			}
+		case *input.ShopID == 0:
+			voucher.ShopID = nil
+		default:
+			var target models.Shop
+			if err := database.DB.First(&target, *input.ShopID).Error; err != nil {
+				return 404, fmt.Errorf("Shop not found")
+			}
+			voucher.ShopID = &target.ID
+		}
+	}
+
+	if input.Code != nil {
+		code := normalizeVoucherCode(*input.Code)
+		if !voucherCodePattern.MatchString(code) {
+			return 400, fmt.Errorf("code must be 3 to 32 letters, digits, dashes or underscores")
+		}
+		var count int64
+		if err := database.DB.Model(&models.Voucher{}).Where("code = ? AND id <> ?", code, voucher.ID).Count(&count).Error; err != nil {
+			return 500, fmt.Errorf("Failed to check voucher code")
+		}
+		if count > 0 {
+			return 409, fmt.Errorf("Voucher code is already taken")
+		}
+		voucher.Code = code
+	}
+	if voucher.Code == "" {
+		return 400, fmt.Errorf("code is required")
+	}
+
+	if input.Name != nil {
+		name := strings.TrimSpace(*input.Name)
+		if len(name) > 100 {
+			return 400, fmt.Errorf("Name must be at most 100 characters")
+		}
+		voucher.Name = name
+	}
+	if input.Type != nil {
+		voucher.Type = *input.Type
+	}
+	if input.Value != nil {
+		voucher.Value = *input.Value
+	}
+	switch voucher.Type {
+	case models.VoucherPercent:
+		if voucher.Value <= 0 || voucher.Value > 100 {
+			return 400, fmt.Errorf("A percent voucher needs a value between 0 and 100")
+		}
+	case models.VoucherFixed:
+		if voucher.Value <= 0 {
+			return 400, fmt.Errorf("value must be greater than 0")
+		}
+	default:
+		return 400, fmt.Errorf("type must be percent or fixed")
+	}
+
+	// Zero clears the optional caps and limits.
+	if input.MaxDiscount != nil {
+		if *input.MaxDiscount < 0 {
+			return 400, fmt.Errorf("max_discount cannot be negative")
+		}
+		voucher.MaxDiscount = input.MaxDiscount
+		if *input.MaxDiscount == 0 {
+			voucher.MaxDiscount = nil
+		}
+	}
+	if input.MinSpend != nil {
+		if *input.MinSpend < 0 {
+			return 400, fmt.Errorf("min_spend cannot be negative")
+		}
+		voucher.MinSpend = *input.MinSpend
+	}
+	if input.UsageLimit != nil {
+		if *input.UsageLimit < 0 {
+			return 400, fmt.Errorf("usage_limit cannot be negative")
+		}
+		voucher.UsageLimit = input.UsageLimit
+		if *input.UsageLimit == 0 {
+			voucher.UsageLimit = nil
+		}
+	}
+	if input.PerUserLimit != nil {
+		if *input.PerUserLimit < 0 {
+			return 400, fmt.Errorf("per_user_limit cannot be negative")
+		}
+		voucher.PerUserLimit = input.PerUserLimit
+		if *input.PerUserLimit == 0 {
+			voucher.PerUserLimit = nil
+		}
+	}
+
+	if input.CategoryIDs != nil {
+		ids := uniqueUints(*input.CategoryIDs)
+		if len(ids) > 0 {
+			var count int64
+			if err := database.DB.Model(&models.Category{}).Where("id IN ?", ids).Count(&count).Error; err != nil {
+				return 500, fmt.Errorf("Failed to check categories")
+			}
+			if int(count) != len(ids) {
+				return 404, fmt.Errorf("Category not found")
+			}
+		}
+		voucher.CategoryIDs = ids
+	}
+	if input.ProductIDs != nil {
+		voucher.ProductIDs = uniqueUints(*input.ProductIDs)
+	}
+	if len(voucher.ProductIDs) > 0 {
+		query := database.DB.Model(&models.Product{}).Where("id IN ?", voucher.ProductIDs)
+		if voucher.ShopID != nil {
+			query = query.Where("shop_id = ?", *voucher.ShopID)
+		}
+		var count int64
+		if err := query.Count(&count).Error; err != nil {
+			return 500, fmt.Errorf("Failed to check products")
+		}
+		if int(count) != len(voucher.ProductIDs) {
+			return 404, fmt.Errorf("Product not found in the shop of this voucher")
+		}
+	}
+
+	if input.StartsAt != nil {
+		voucher.StartsAt = *input.StartsAt
+	}
+	if input.EndsAt != nil {
+		voucher.EndsAt = *input.EndsAt
+	}
+	if voucher.EndsAt.IsZero() {
+		return 400, fmt.Errorf("ends_at is required")
+	}
+	if !voucher.EndsAt.After(voucher.StartsAt) {
+		return 400, fmt.Errorf("ends_at must be after starts_at")
+	}
+	if input.Active != nil {
+		voucher.Active = *input.Active
+	}
+
+	return 0, nil
+}
+
+func uniqueUints(values []uint) []uint {
+	unique := []uint{}
+	for _, value := range values {
+		if !containsUint(unique, value) {
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
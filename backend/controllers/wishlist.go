@@ -0,0 +1,376 @@
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"finpro/database"
+	"finpro/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+)
+
+// GetWishlist lists the caller's wishlist with current prices and
+// availability. ?saved_for_later=true lists only items saved from the cart,
+// false only bookmarked ones.
+func GetWishlist(c *fiber.Ctx) error {
+	userID := sessionUserID(c)
+
+	query := database.DB.
+		Preload("Product", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Preload("Variant").
+		Where("user_id = ?", userID)
+	if saved := c.Query("saved_for_later"); saved != "" {
+		b, err := strconv.ParseBool(saved)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "saved_for_later must be true or false"})
+		}
+		query = query.Where("saved_for_later = ?", b)
+	}
+
+	var items []models.WishlistItem
+	if err := query.Order("created_at DESC, id DESC").Find(&items).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch wishlist"})
+	}
+
+	products := make([]models.Product, 0, len(items))
+	for _, item := range items {
+		products = append(products, item.Product)
+	}
+	now := time.Now()
+	book, err := loadPriceBook(products, now)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch discounts"})
+	}
+
+	data := []fiber.Map{}
+	for _, item := range items {
+		quote := book.quote(&item.Product, item.Variant)
+
+		stock := item.Product.Stock
+		variantLabel := ""
+		if item.Variant != nil {
+			stock = item.Variant.Stock
+			variantLabel = item.Variant.Label()
+		}
+		available := availability(stock, 0)
+		if item.Product.DeletedAt.Valid || (item.Product.Status != models.ProductStatusSoldOut && !item.Product.IsPublished(now)) {
+			available = "unavailable"
+		}
+
+		data = append(data, fiber.Map{
+			"id":              item.ID,
+			"product_id":      item.ProductID,
+			"variant_id":      item.VariantID,
+			"variant":         variantLabel,
+			"name":            item.Product.Name,
+			"image":           item.Product.Image,
+			"price":           quote.Price,
+			"original_price":  quote.OriginalPrice,
+			"discount":        quote.Discount,
+			"availability":    available,
+			"quantity":        item.Quantity,
+			"saved_for_later": item.SavedForLater,
+			"created_at":      item.CreatedAt,
+		})
+	}
+
+	return c.JSON(fiber.Map{"status": "success", "data": data})
+}
+
+func AddToWishlist(c *fiber.Ctx) error {
+	type Request struct {
+		ProductID uint  `json:"product_id"`
+		VariantID *uint `json:"variant_id"`
+	}
+
+	var body Request
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	userID := sessionUserID(c)
+
+	var product models.Product
+	if err := database.DB.Scopes(wishlistProducts).First(&product, body.ProductID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Product not found"})
+	}
+
+	var variant *models.ProductVariant
+	if body.VariantID != nil {
+		variant = &models.ProductVariant{}
+		if err := database.DB.Where("product_id = ?", product.ID).First(variant, *body.VariantID).Error; err != nil {
+			return c.Status(404).JSON(fiber.Map{"error": "Variant not found"})
+		}
+	}
+
+	var existing models.WishlistItem
+	if err := wishlistEntry(database.DB, userID, product.ID, body.VariantID).First(&existing).Error; err == nil {
+		if existing.SavedForLater {
+			database.DB.Model(&existing).Update("saved_for_later", false)
+		}
+		return c.JSON(fiber.Map{"status": "success", "message": "Product is already in your wishlist", "data": existing})
+	}
+
+	item := models.WishlistItem{
+		UserID:    userID,
+		ProductID: product.ID,
+		VariantID: body.VariantID,
+		Quantity:  1,
+	}
+	if err := trackWishlistState(&item, &product, variant); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch discounts"})
+	}
+
+	if err := database.DB.Create(&item).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to add to wishlist"})
+	}
+
+	recordAudit(c, "wishlist.add", "wishlist_item", item.ID, nil, item)
+
+	return c.Status(201).JSON(fiber.Map{"status": "success", "message": "Product added to wishlist", "data": item})
+}
+
+func RemoveFromWishlist(c *fiber.Ctx) error {
+	var item models.WishlistItem
+	if err := database.DB.Where("user_id = ?", sessionUserID(c)).First(&item, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Wishlist item not found"})
+	}
+
+	if err := database.DB.Delete(&item).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to remove wishlist item"})
+	}
+
+	recordAudit(c, "wishlist.remove", "wishlist_item", item.ID, item, nil)
+
+	return c.JSON(fiber.Map{"status": "success", "message": "Product removed from wishlist"})
+}
+
+// MoveWishlistToCart adds a wishlist item to the cart with its quantity and
+// removes it from the wishlist. A variant_id can be sent for bookmarks of a
+// product that has variants.
+func MoveWishlistToCart(c *fiber.Ctx) error {
+	type Request struct {
+		VariantID *uint `json:"variant_id"`
+	}
+
+	var body Request
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+	}
+
+	userID := sessionUserID(c)
+
+	var item models.WishlistItem
+	if err := database.DB.Where("user_id = ?", userID).First(&item, c.Params("id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Wishlist item not found"})
+	}
+
+	variantID := item.VariantID
+	if variantID == nil {
+		variantID = body.VariantID
+	}
+
+	quote, _, status, err := addToCart(c, cartOwner{UserID: userID}, item.ProductID, variantID, item.Quantity)
+	if err != nil {
+		response := fiber.Map{"error": err.Error()}
+		if status == fiber.StatusConflict {
+			response["availability"] = availabilityReserved
+		}
+		return c.Status(status).JSON(response)
+	}
+
+	if err := database.DB.Delete(&item).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to remove wishlist item"})
+	}
+	recordAudit(c, "wishlist.remove", "wishlist_item", item.ID, item, nil)
+
+	return c.JSON(fiber.Map{"status": "success", "message": "Product moved to cart", "data": quote})
+}
+
+// SaveForLater moves a cart item to the wishlist, keeping its quantity, so it
+// no longer counts for checkout.
+func SaveForLater(c *fiber.Ctx) error {
+	userID := sessionUserID(c)
+
+	var cartItem models.CartItem
+	if err := database.DB.Preload("Product").Preload("Variant").
+		Where("user_id = ?", userID).First(&cartItem, c.Params("cart_id")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Cart item not found"})
+	}
+
+	var item models.WishlistItem
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		err := wishlistEntry(tx, userID, cartItem.ProductID, cartItem.VariantID).First(&item).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+
+		if err == gorm.ErrRecordNotFound {
+			item = models.WishlistItem{
+				UserID:    userID,
+				ProductID: cartItem.ProductID,
+				VariantID: cartItem.VariantID,
+			}
+			if err := trackWishlistState(&item, &cartItem.Product, cartItem.Variant); err != nil {
+				return err
+			}
+		}
+		item.Quantity = cartItem.Quantity
+		item.SavedForLater = true
+		if err := tx.Save(&item).Error; err != nil {
+			return err
+		}
+
+		if err := releaseReservations(tx, []uint{cartItem.ID}); err != nil {
+			return err
+		}
+		return tx.Delete(&cartItem).Error
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save item for later"})
+	}
+
+	recordAudit(c, "cart.save_for_later", "cart_item", cartItem.ID, cartItem, item)
+
+	return c.JSON(fiber.Map{"status": "success", "message": "Item saved for later", "data": item})
+}
+
+func sessionUserID(c *fiber.Ctx) uint {
+	userToken := c.Locals("user").(*jwt.Token)
+	claims := userToken.Claims.(jwt.MapClaims)
+	return uint(claims["id"].(float64))
+}
+
+func wishlistEntry(db *gorm.DB, userID, productID uint, variantID *uint) *gorm.DB {
+	query := db.Where("user_id = ? AND product_id = ?", userID, productID)
+	if variantID != nil {
+		return query.Where("variant_id = ?", *variantID)
+	}
+	return query.Where("variant_id IS NULL")
+}
+
+// trackWishlistState remembers the current price and stock of a new wishlist
+// item, the baseline for price drop and restock notifications.
+func trackWishlistState(item *models.WishlistItem, product *models.Product, variant *models.ProductVariant) error {
+	book, err := loadPriceBook([]models.Product{*product}, time.Now())
+	if err != nil {
+		return err
+	}
+	item.NotifiedPrice = book.quote(product, variant).Price
+
+	stock := product.Stock
+	if variant != nil {
+		stock = variant.Stock
+	}
+	item.NotifiedInStock = stock > 0
+	return nil
+}
+
+// wishlistCounts returns how many users wishlisted each product.
+func wishlistCounts(productIDs []uint) (map[uint]int64, error) {
+	counts := map[uint]int64{}
+	if len(productIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		ProductID uint
+		Count     int64
+	}
+	if err := database.DB.Model(&models.WishlistItem{}).
+		Select("product_id, COUNT(DISTINCT user_id) AS count").
+		Where("product_id IN ?", productIDs).
+		Group("product_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		counts[row.ProductID] = row.Count
+	}
+	return counts, nil
+}
+
+// notifyWishlistChanges is the background job that tells users when a
+// wishlisted product got cheaper or is back in stock. Each change is
+// notified once; a price rise only moves the baseline.
+func notifyWishlistChanges() {
+	now := time.Now()
+	// Sold out products are checked too, so the item goes out of stock and
+	// is notified when the product is restocked.
+	var items []models.WishlistItem
+	err := database.DB.Preload("Product").Preload("Variant").
+		Where("product_id IN (?)", database.DB.Model(&models.Product{}).Select("products.id").Scopes(wishlistProducts)).
+		FindInBatches(&items, 500, func(tx *gorm.DB, batch int) error {
+			products := make([]models.Product, 0, len(items))
+			for _, item := range items {
+				products = append(products, item.Product)
+			}
+			book, err := loadPriceBook(products, now)
+			if err != nil {
+				return err
+			}
+
+			for _, item := range items {
+				notifyWishlistItem(item, book)
+			}
+			return nil
+		}).Error
+	if err != nil {
+		fmt.Printf("⚠️ Failed to check wishlists: %v\n", err)
+	}
+}
+
+func notifyWishlistItem(item models.WishlistItem, book *priceBook) {
+	price := book.quote(&item.Product, item.Variant).Price
+	stock := item.Product.Stock
+	if item.Variant != nil {
+		stock = item.Variant.Stock
+	}
+	inStock := stock > 0
+	if price == item.NotifiedPrice && inStock == item.NotifiedInStock {
+		return
+	}
+
+	productID := item.ProductID
+	var notifications []models.Notification
+	if price < item.NotifiedPrice {
+		notifications = append(notifications, models.Notification{
+			UserID:    item.UserID,
+			Type:      models.NotificationPriceDrop,
+			Title:     "Price drop on your wishlist",
+			Message:   fmt.Sprintf("%s is now %.0f, down from %.0f", item.Product.Name, price, item.NotifiedPrice),
+			ProductID: &productID,
+		})
+	}
+	if inStock && !item.NotifiedInStock {
+		notifications = append(notifications, models.Notification{
+			UserID:    item.UserID,
+			Type:      models.NotificationBackInStock,
+			Title:     "Back in stock",
+			Message:   fmt.Sprintf("%s from your wishlist is available again", item.Product.Name),
+			ProductID: &productID,
+		})
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if len(notifications) > 0 {
+			if err := tx.Create(&notifications).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.WishlistItem{}).Where("id = ?", item.ID).Updates(map[string]interface{}{
+			"notified_price":    price,
+			"notified_in_stock": inStock,
+		}).Error
+	})
+	if err != nil {
+		fmt.Printf("⚠️ Failed to notify wishlist item %d: %v\n", item.ID, err)
+	}
+}
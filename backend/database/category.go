@@ -0,0 +1,54 @@
+package database
+
+import (
+	"finpro/models"
+
+	"gorm.io/gorm"
+)
+
+// defaultCategories are created on a fresh database so products can be
+// added right away. They match the categories that used to be hardcoded.
+var defaultCategories = []string{"Fashion", "Others"}
+
+// migrateCategories moves products from the old free-text category column to
+// category IDs and drops that column afterwards.
+func migrateCategories(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Category{}).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			for _, name := range defaultCategories {
+				if err := tx.Create(&models.Category{Name: name, Slug: models.Slugify(name)}).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		if !tx.Migrator().HasColumn(&models.Product{}, "category") {
+			return nil
+		}
+
+		var names []string
+		if err := tx.Table("products").Where("category <> ''").Distinct().Pluck("category", &names).Error; err != nil {
+			return err
+		}
+
+		for _, name := range names {
+			category := models.Category{Slug: models.Slugify(name)}
+			if err := tx.Where(models.Category{Slug: category.Slug}).
+				Attrs(models.Category{Name: name}).
+				FirstOrCreate(&category).Error; err != nil {
+				return err
+			}
+			if err := tx.Table("products").
+				Where("category = ? AND category_id IS NULL", name).
+				Update("category_id", category.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Migrator().DropColumn(&models.Product{}, "category")
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"finpro/models"
+	"finpro/rbac"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -33,7 +34,22 @@ func Init() {
 }
 
 func Migrate() {
-	if err := DB.Debug().AutoMigrate(&models.User{}, &models.Shop{}, &models.Product{}, &models.Order{}, &models.OrderItem{}, &models.CartItem{}); err != nil {
+	if err := DB.Debug().AutoMigrate(&models.User{}, &models.Shop{}, &models.Product{}, &models.Order{}, &models.OrderItem{}, &models.CartItem{}, &models.RecoveryCode{}, &models.Setting{}, &models.Role{}, &models.Permission{}, &models.RolePermission{}, &models.AuditLog{}, &models.APIKey{}, &models.Category{}, &models.ProductVariant{}, &models.Discount{}, &models.StockReservation{}, &models.GuestCart{}, &models.WishlistItem{}, &models.Notification{}, &models.Voucher{}, &models.VoucherRedemption{}, &models.Offer{}, &models.Auction{}, &models.Bid{}, &models.FlashSale{}, &models.FlashSaleClaim{}, &models.Review{}); err != nil {
+		panic(err)
+	}
+	if err := migrateCategories(DB); err != nil {
+		panic(err)
+	}
+	if err := backfillOrderItemSnapshots(DB); err != nil {
+		panic(err)
+	}
+	if err := backfillOrderItemOriginalPrices(DB); err != nil {
+		panic(err)
+	}
+	if err := backfillShopSlugs(DB); err != nil {
+		panic(err)
+	}
+	if err := rbac.Seed(DB); err != nil {
 		panic(err)
 	}
 	fmt.Println("Migrate Successfuly")
@@ -0,0 +1,27 @@
+package database
+
+import "gorm.io/gorm"
+
+// backfillOrderItemSnapshots fills the product snapshot of order items
+// created before snapshots existed, from the current product data.
+func backfillOrderItemSnapshots(db *gorm.DB) error {
+	return db.Exec("UPDATE orderitem oi " +
+		"JOIN products p ON oi.product_id = p.id " +
+		"LEFT JOIN categories cat ON p.category_id = cat.id " +
+		"LEFT JOIN product_variants pv ON oi.variant_id = pv.id " +
+		"SET oi.product_name = p.name, " +
+		"oi.product_label = p.label, " +
+		"oi.product_image = p.image, " +
+		"oi.category_name = COALESCE(cat.name, ''), " +
+		"oi.variant_label = COALESCE(CONCAT_WS(' / ', NULLIF(pv.size, ''), NULLIF(pv.color, '')), ''), " +
+		"oi.sku = COALESCE(pv.sku, ''), " +
+		"oi.attributes = JSON_OBJECT('condition', p.`condition`, 'size', COALESCE(NULLIF(pv.size, ''), p.size), " +
+		"'brand', p.brand, 'material', p.material, 'measurements', p.measurements) " +
+		"WHERE oi.product_name IS NULL OR oi.product_name = ''").Error
+}
+
+// backfillOrderItemOriginalPrices sets the regular price of order items
+// created before discounts existed, which were all sold at that price.
+func backfillOrderItemOriginalPrices(db *gorm.DB) error {
+	return db.Exec("UPDATE orderitem SET original_price = price WHERE original_price = 0").Error
+}
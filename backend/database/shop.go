@@ -0,0 +1,28 @@
+package database
+
+import (
+	"finpro/models"
+
+	"gorm.io/gorm"
+)
+
+// backfillShopSlugs gives shops created before slugs existed a slug from
+// their name.
+func backfillShopSlugs(db *gorm.DB) error {
+	var shops []models.Shop
+	if err := db.Where("slug IS NULL OR slug = ''").Order("id").Find(&shops).Error; err != nil {
+		return err
+	}
+
+	for _, shop := range shops {
+		slug := models.ShopSlug(shop.ShopName, func(slug string) bool {
+			var count int64
+			db.Model(&models.Shop{}).Where("slug = ?", slug).Count(&count)
+			return count > 0
+		})
+		if err := db.Model(&models.Shop{}).Where("id = ?", shop.ID).Update("slug", slug).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+// Package flashsale hands out the limited units of flash sales from memory,
+// so the rush at the start of a sale is decided without touching the
+// database. Only buyers who get units go on to write their claim.
+//
+// The counters live in one process. The database stays the record: loading
+// a sale rebuilds its counters from the stored claims, and Reconcile lowers
+// a counter that has more units left than the database allows.
+package flashsale
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	ErrNotLoaded = errors.New("flash sale is not running")
+	ErrSoldOut   = errors.New("flash sale is sold out")
+	ErrLimit     = errors.New("purchase limit of the flash sale reached")
+)
+
+type pool struct {
+	remaining atomic.Int64
+	limit     int64
+
+	mu    sync.Mutex
+	taken map[uint]int64
+}
+
+// Allocator keeps a counter of remaining units per flash sale and how many
+// units each user holds. It is safe for concurrent use.
+type Allocator struct {
+	mu    sync.RWMutex
+	pools map[uint]*pool
+}
+
+func NewAllocator() *Allocator {
+	return &Allocator{pools: map[uint]*pool{}}
+}
+
+// Load sets up the counters of a sale: the units not yet claimed, the limit
+// per user (0 for none) and the units each user already claimed. Loading a
+// sale again replaces its counters.
+func (a *Allocator) Load(saleID uint, remaining int, perUserLimit int, taken map[uint]int) {
+	p := &pool{limit: int64(perUserLimit), taken: map[uint]int64{}}
+	p.remaining.Store(int64(remaining))
+	for userID, quantity := range taken {
+		p.taken[userID] = int64(quantity)
+	}
+
+	a.mu.Lock()
+	a.pools[saleID] = p
+	a.mu.Unlock()
+}
+
+// Loaded reports whether the sale has counters.
+func (a *Allocator) Loaded(saleID uint) bool {
+	return a.pool(saleID) != nil
+}
+
+// Forget drops the counters of a sale that ended.
+func (a *Allocator) Forget(saleID uint) {
+	a.mu.Lock()
+	delete(a.pools, saleID)
+	a.mu.Unlock()
+}
+
+// Claim takes quantity units of the sale for the user. The remaining units
+// are taken with one atomic add, so concurrent claims can never take more
+// than there are; a claim that overshoots puts its units back and fails.
+func (a *Allocator) Claim(saleID, userID uint, quantity int) error {
+	p := a.pool(saleID)
+	if p == nil {
+		return ErrNotLoaded
+	}
+	q := int64(quantity)
+
+	if p.remaining.Add(-q) < 0 {
+		p.remaining.Add(q)
+		return ErrSoldOut
+	}
+
+	p.mu.Lock()
+	if p.limit > 0 && p.taken[userID]+q > p.limit {
+		p.mu.Unlock()
+		p.remaining.Add(q)
+		return ErrLimit
+	}
+	p.taken[userID] += q
+	p.mu.Unlock()
+	return nil
+}
+
+// Release gives back units of a claim that was not bought, or whose
+// database write failed.
+func (a *Allocator) Release(saleID, userID uint, quantity int) {
+	p := a.pool(saleID)
+	if p == nil {
+		return
+	}
+	q := int64(quantity)
+
+	p.mu.Lock()
+	if p.taken[userID] < q {
+		q = p.taken[userID]
+	}
+	p.taken[userID] -= q
+	if p.taken[userID] == 0 {
+		delete(p.taken, userID)
+	}
+	p.mu.Unlock()
+	p.remaining.Add(q)
+}
+
+// Remaining returns the units left of a sale.
+func (a *Allocator) Remaining(saleID uint) (int, bool) {
+	p := a.pool(saleID)
+	if p == nil {
+		return 0, false
+	}
+	remaining := p.remaining.Load()
+	if remaining < 0 {
+		remaining = 0
+	}
+	return int(remaining), true
+}
+
+// Reconcile compares the counter with the units left according to the
+// database and lowers the counter when it has more. It never raises it:
+// claims in flight are counted in memory before they are stored, and units
+// only come back through Release. It returns how many units were removed.
+func (a *Allocator) Reconcile(saleID uint, stored int) int {
+	p := a.pool(saleID)
+	if p == nil {
+		return 0
+	}
+	for {
+		current := p.remaining.Load()
+		if current <= int64(stored) {
+			return 0
+		}
+		if p.remaining.CompareAndSwap(current, int64(stored)) {
+			return int(current - int64(stored))
+		}
+	}
+}
+
+func (a *Allocator) pool(saleID uint) *pool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.pools[saleID]
+}
@@ -0,0 +1,262 @@
+package flashsale
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+const testSale = 1
+
+// claimAll runs buyers goroutines that each try attempts claims of quantity
+// units at the same moment, and returns the units each user got.
+func claimAll(a *Allocator, buyers, attempts, quantity int) map[uint]int {
+	var mu sync.Mutex
+	got := map[uint]int{}
+
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for b := 1; b <= buyers; b++ {
+		wg.Add(1)
+		go func(userID uint) {
+			defer wg.Done()
+			<-start
+			for i := 0; i < attempts; i++ {
+				if a.Claim(testSale, userID, quantity) == nil {
+					mu.Lock()
+					got[userID] += quantity
+					mu.Unlock()
+				}
+			}
+		}(uint(b))
+	}
+	close(start)
+	wg.Wait()
+	return got
+}
+
+func total(taken map[uint]int) int {
+	sum := 0
+	for _, quantity := range taken {
+		sum += quantity
+	}
+	return sum
+}
+
+func TestClaimNeverOversells(t *testing.T) {
+	tests := []struct {
+		name     string
+		units    int
+		limit    int
+		buyers   int
+		attempts int
+		quantity int
+	}{
+		{"one unit each", 100, 0, 1000, 1, 1},
+		{"several units each", 100, 0, 500, 3, 3},
+		{"units do not divide evenly", 10, 0, 50, 2, 3},
+		{"more units than buyers want", 1000, 2, 100, 5, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAllocator()
+			a.Load(testSale, tt.units, tt.limit, nil)
+
+			got := claimAll(a, tt.buyers, tt.attempts, tt.quantity)
+			remaining, ok := a.Remaining(testSale)
+			if !ok {
+				t.Fatal("Remaining: sale not loaded")
+			}
+
+			sold := total(got)
+			if sold > tt.units {
+				t.Errorf("claimed %d units of a sale of %d", sold, tt.units)
+			}
+			if sold+remaining != tt.units {
+				t.Errorf("claimed %d and remaining %d, want %d together", sold, remaining, tt.units)
+			}
+			if tt.limit == 0 && remaining >= tt.quantity && sold < tt.buyers*tt.attempts*tt.quantity {
+				t.Errorf("%d units left although claims of %d were refused", remaining, tt.quantity)
+			}
+			for userID, quantity := range got {
+				if tt.limit > 0 && quantity > tt.limit {
+					t.Errorf("user %d got %d units, the limit is %d", userID, quantity, tt.limit)
+				}
+			}
+		})
+	}
+}
+
+func TestClaimPerUserLimitUnderConcurrency(t *testing.T) {
+	a := NewAllocator()
+	a.Load(testSale, 100, 3, map[uint]int{7: 1})
+
+	var granted atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if a.Claim(testSale, 7, 1) == nil {
+				granted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// One unit was already taken when the sale was loaded.
+	if got := granted.Load(); got != 2 {
+		t.Errorf("granted %d units to one user, want 2", got)
+	}
+	if remaining, _ := a.Remaining(testSale); remaining != 98 {
+		t.Errorf("Remaining = %d, want 98; refused claims must put their units back", remaining)
+	}
+}
+
+func TestClaimErrors(t *testing.T) {
+	a := NewAllocator()
+	a.Load(testSale, 2, 1, nil)
+
+	if err := a.Claim(2, 1, 1); err != ErrNotLoaded {
+		t.Errorf("Claim of an unknown sale = %v, want ErrNotLoaded", err)
+	}
+	if err := a.Claim(testSale, 1, 1); err != nil {
+		t.Fatalf("Claim = %v, want nil", err)
+	}
+	if err := a.Claim(testSale, 1, 1); err != ErrLimit {
+		t.Errorf("Claim over the limit = %v, want ErrLimit", err)
+	}
+	if err := a.Claim(testSale, 2, 2); err != ErrSoldOut {
+		t.Errorf("Claim of more than is left = %v, want ErrSoldOut", err)
+	}
+	if err := a.Claim(testSale, 2, 1); err != nil {
+		t.Errorf("Claim of the last unit = %v, want nil", err)
+	}
+
+	a.Forget(testSale)
+	if a.Loaded(testSale) {
+		t.Error("Loaded after Forget = true")
+	}
+	if err := a.Claim(testSale, 3, 1); err != ErrNotLoaded {
+		t.Errorf("Claim after Forget = %v, want ErrNotLoaded", err)
+	}
+}
+
+func TestReleaseUnderConcurrency(t *testing.T) {
+	const units = 50
+	a := NewAllocator()
+	a.Load(testSale, units, 0, nil)
+
+	// Every buyer claims and gives back in turn while others claim, like
+	// checkouts that fail or claims that expire during the rush.
+	var held sync.Map
+	var wg sync.WaitGroup
+	for b := 1; b <= 200; b++ {
+		wg.Add(1)
+		go func(userID uint) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				if a.Claim(testSale, userID, 1) != nil {
+					continue
+				}
+				if i%2 == 0 {
+					a.Release(testSale, userID, 1)
+					continue
+				}
+				count, _ := held.LoadOrStore(userID, new(atomic.Int64))
+				count.(*atomic.Int64).Add(1)
+			}
+		}(uint(b))
+	}
+	wg.Wait()
+
+	kept := 0
+	held.Range(func(_, count any) bool {
+		kept += int(count.(*atomic.Int64).Load())
+		return true
+	})
+	remaining, _ := a.Remaining(testSale)
+	if kept > units {
+		t.Errorf("kept %d units of a sale of %d", kept, units)
+	}
+	if kept+remaining != units {
+		t.Errorf("kept %d and remaining %d, want %d together", kept, remaining, units)
+	}
+}
+
+func TestReleaseGivesBackOnlyWhatWasTaken(t *testing.T) {
+	a := NewAllocator()
+	a.Load(testSale, 10, 0, nil)
+
+	if err := a.Claim(testSale, 1, 2); err != nil {
+		t.Fatalf("Claim = %v", err)
+	}
+	a.Release(testSale, 1, 5)
+	a.Release(testSale, 2, 3)
+	a.Release(99, 1, 1)
+
+	if remaining, _ := a.Remaining(testSale); remaining != 10 {
+		t.Errorf("Remaining = %d, want 10", remaining)
+	}
+}
+
+func TestReconcileOnlyLowers(t *testing.T) {
+	a := NewAllocator()
+	a.Load(testSale, 10, 0, nil)
+
+	if removed := a.Reconcile(testSale, 12); removed != 0 {
+		t.Errorf("Reconcile above the counter removed %d, want 0", removed)
+	}
+	if removed := a.Reconcile(testSale, 7); removed != 3 {
+		t.Errorf("Reconcile removed %d, want 3", removed)
+	}
+	if remaining, _ := a.Remaining(testSale); remaining != 7 {
+		t.Errorf("Remaining = %d, want 7", remaining)
+	}
+	if removed := a.Reconcile(2, 0); removed != 0 {
+		t.Errorf("Reconcile of an unknown sale removed %d, want 0", removed)
+	}
+}
+
+func TestReconcileDuringClaims(t *testing.T) {
+	const units = 200
+	a := NewAllocator()
+	a.Load(testSale, units, 0, nil)
+
+	// The stored claims trail the counter; the reconciler runs against them
+	// the whole time and must never let the claims go past the sale.
+	var stored atomic.Int64
+	done := make(chan struct{})
+	var reconciler sync.WaitGroup
+	reconciler.Add(1)
+	go func() {
+		defer reconciler.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				a.Reconcile(testSale, units-int(stored.Load()))
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for b := 1; b <= 400; b++ {
+		wg.Add(1)
+		go func(userID uint) {
+			defer wg.Done()
+			if a.Claim(testSale, userID, 1) == nil {
+				stored.Add(1)
+			}
+		}(uint(b))
+	}
+	wg.Wait()
+	close(done)
+	reconciler.Wait()
+
+	remaining, _ := a.Remaining(testSale)
+	if got := int(stored.Load()); got > units || got+remaining > units {
+		t.Errorf("stored %d with %d remaining for a sale of %d", got, remaining, units)
+	}
+}
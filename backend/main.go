@@ -2,7 +2,9 @@ package main
 
 import (
 	"finpro/config"
+	"finpro/controllers"
 	"finpro/database"
+	"finpro/rbac"
 	"finpro/routes"
 
 	"github.com/gofiber/fiber/v2"
@@ -13,7 +15,17 @@ func main() {
 	config.ENVLoad()
 	database.Init()
 	database.Migrate()
-	app := fiber.New()
+	if err := rbac.Load(database.DB); err != nil {
+		panic(err)
+	}
+	if err := controllers.InitSearch(); err != nil {
+		panic(err)
+	}
+	controllers.StartBackgroundJobs()
+	app := fiber.New(fiber.Config{
+		// Large enough for a product import with its zip of images.
+		BodyLimit: 32 * 1024 * 1024,
+	})
 
 		app.Use(cors.New(cors.Config{
 		AllowOrigins: "http://localhost:3000,http://localhost:5173",
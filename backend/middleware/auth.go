@@ -2,16 +2,22 @@ package middleware
 
 import (
 	"os"
+	"time"
+
+	"finpro/database"
+	"finpro/models"
 
 	"github.com/gofiber/fiber/v2"
 	jwtware "github.com/gofiber/jwt/v3"
+	"github.com/golang-jwt/jwt/v4"
 )
 
 func Protected() fiber.Handler {
 	return jwtware.New(jwtware.Config{
-		SigningKey:   []byte(os.Getenv("JWT_SECRET")),
-		TokenLookup:  "cookie:token",
-		ErrorHandler: jwtError,
+		SigningKey:     []byte(os.Getenv("JWT_SECRET")),
+		TokenLookup:    "cookie:token",
+		ErrorHandler:   jwtError,
+		SuccessHandler: activeSession,
 	})
 }
 
@@ -19,4 +25,53 @@ func jwtError(c *fiber.Ctx, err error) error {
 	return c.Status(401).JSON(fiber.Map{
 		"error": "Unauthorized: Need to be logged in to access this resource",
 	})
-}
\ No newline at end of file
+}
+
+// activeSession rejects the short-lived login step tokens (they carry a
+// purpose claim) and sessions of accounts that were blocked or flagged for a
+// password reset after the token was issued. The role claim is refreshed
+// from the database so role changes apply without a new login.
+func activeSession(c *fiber.Ctx) error {
+	claims := c.Locals("user").(*jwt.Token).Claims.(jwt.MapClaims)
+	if _, ok := claims["purpose"]; ok {
+		return jwtError(c, nil)
+	}
+
+	var user models.User
+	if err := database.DB.Select("id", "role", "status", "status_reason", "status_until", "must_reset_password").
+		First(&user, claims["id"]).Error; err != nil {
+		return jwtError(c, err)
+	}
+
+	if user.IsBlocked(time.Now()) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":  "Account is " + user.Status,
+			"reason": user.StatusReason,
+			"until":  user.StatusUntil,
+		})
+	}
+
+	if user.MustResetPassword {
+		return c.Status(401).JSON(fiber.Map{
+			"error": "Password reset required, please login again",
+		})
+	}
+
+	claims["role"] = user.Role
+	return c.Next()
+}
+
+// ProtectedOrGuest lets visitors without a session through, for endpoints
+// like the cart that also work anonymously. A session cookie is still fully
+// checked, so an expired or blocked session is not silently treated as a
+// guest.
+func ProtectedOrGuest() fiber.Handler {
+	session := Protected()
+
+	return func(c *fiber.Ctx) error {
+		if c.Cookies("token") == "" {
+			return c.Next()
+		}
+		return session(c)
+	}
+}
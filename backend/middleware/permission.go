@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"strings"
+
+	"finpro/rbac"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func RequirePermission(permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userToken := c.Locals("user").(*jwt.Token)
+		claims := userToken.Claims.(jwt.MapClaims)
+		role, _ := claims["role"].(string)
+
+		c.Locals("role", role)
+
+		if rbac.Can(role, permission) && scopeAllows(claims, permission) {
+			return c.Next()
+		}
+
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Forbidden: Missing permission " + permission,
+		})
+	}
+}
+
+// RequireAnyPermission lets the request through when the role has at least
+// one of the permissions, for endpoints that admins and sellers share.
+func RequireAnyPermission(permissions ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userToken := c.Locals("user").(*jwt.Token)
+		claims := userToken.Claims.(jwt.MapClaims)
+		role, _ := claims["role"].(string)
+
+		c.Locals("role", role)
+
+		for _, permission := range permissions {
+			if rbac.Can(role, permission) && scopeAllows(claims, permission) {
+				return c.Next()
+			}
+		}
+
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Forbidden: Missing permission " + strings.Join(permissions, " or "),
+		})
+	}
+}
+
+// scopeAllows narrows API key requests to the scopes chosen for the key.
+// Sessions have no scopes claim and are limited by the role only.
+func scopeAllows(claims jwt.MapClaims, permission string) bool {
+	scopes, ok := claims["scopes"].([]interface{})
+	if !ok {
+		return true
+	}
+
+	for _, scope := range scopes {
+		if scope == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -1,26 +0,0 @@
-package middleware
-
-import (
-	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v4"
-)
-
-func RequireRole(roles ...string) fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		userToken := c.Locals("user").(*jwt.Token)
-		claims := userToken.Claims.(jwt.MapClaims)
-		role := claims["role"]
-
-		c.Locals("role", role)
-
-		for _, r := range roles {
-			if role == r {
-				return c.Next()
-			}
-		}
-
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"error": "Forbidden: Division access denied",
-		})
-	}
-}
\ No newline at end of file
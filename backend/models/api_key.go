@@ -0,0 +1,50 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// APIKeyPrefix starts every key so it is easy to recognize in headers and
+// secret scanners. Keys look like thr_<prefix>_<secret>.
+const APIKeyPrefix = "thr_"
+
+type APIKey struct {
+	ID         uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID     int        `json:"user_id" gorm:"index"`
+	Name       string     `json:"name" gorm:"type:varchar(100)"`
+	Prefix     string     `json:"prefix" gorm:"type:varchar(16);uniqueIndex"`
+	KeyHash    string     `json:"-" gorm:"type:varchar(64)"`
+	Scopes     string     `json:"-" gorm:"type:varchar(255)"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	User       User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;" json:"-"`
+}
+
+func (*APIKey) TableName() string {
+	return "api_keys"
+}
+
+func (k *APIKey) ScopeList() []string {
+	if k.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+func (k *APIKey) Active(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// HashAPIKey is the only form of the key that is stored.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
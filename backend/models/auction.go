@@ -0,0 +1,84 @@
+package models
+
+import "time"
+
+const (
+	ListingFixed   = "fixed"
+	ListingAuction = "auction"
+)
+
+const (
+	AuctionRunning   = "running"
+	AuctionWon       = "won"
+	AuctionSold      = "sold"
+	AuctionUnsold    = "unsold"
+	AuctionFailed    = "failed"
+	AuctionCancelled = "cancelled"
+)
+
+// Auction sells one unit of a product to the highest bidder. Bids must beat
+// the current price by MinIncrement, or start at StartPrice. A bid shortly
+// before EndsAt extends the auction, so nobody wins by sniping. At the end
+// the highest bidder wins when the bid reaches ReservePrice: the auction is
+// won until the order for the winner is created, then sold. It failed when
+// the won item could no longer be ordered, e.g. because it was deleted.
+type Auction struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	ProductID    uint       `json:"product_id" gorm:"index"`
+	ShopID       uint       `json:"shop_id" gorm:"index"`
+	StartPrice   float64    `json:"start_price" gorm:"type:decimal(10)"`
+	ReservePrice *float64   `json:"reserve_price,omitempty" gorm:"type:decimal(10)"`
+	MinIncrement float64    `json:"min_increment" gorm:"type:decimal(10)"`
+	CurrentPrice float64    `json:"current_price" gorm:"type:decimal(10)"`
+	BidCount     int        `json:"bid_count"`
+	LeaderID     *uint      `json:"-"`
+	StartsAt     time.Time  `json:"starts_at"`
+	EndsAt       time.Time  `json:"ends_at" gorm:"index"`
+	Status       string     `json:"status" gorm:"type:varchar(20);index"`
+	WinnerID     *uint      `json:"winner_id"`
+	OrderID      *uint      `json:"order_id"`
+	ClosedAt     *time.Time `json:"closed_at"`
+	CreatedAt    time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+	Product      *Product   `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	// MinimumBid and ReserveMet are filled in for bidders, who do not see
+	// the reserve price itself.
+	MinimumBid float64 `gorm:"-" json:"minimum_bid"`
+	ReserveMet bool    `gorm:"-" json:"reserve_met"`
+}
+
+func (*Auction) TableName() string {
+	return "auctions"
+}
+
+// Open reports whether bids are accepted at the given time.
+func (a *Auction) Open(now time.Time) bool {
+	return a.Status == AuctionRunning && !now.Before(a.StartsAt) && now.Before(a.EndsAt)
+}
+
+// ReserveReached reports whether the current bid is high enough to sell.
+func (a *Auction) ReserveReached() bool {
+	return a.BidCount > 0 && (a.ReservePrice == nil || a.CurrentPrice >= *a.ReservePrice)
+}
+
+// NextBid is the lowest amount the next bid may have.
+func (a *Auction) NextBid() float64 {
+	if a.BidCount == 0 {
+		return a.StartPrice
+	}
+	return a.CurrentPrice + a.MinIncrement
+}
+
+// Bid is one entry of the bid history of an auction.
+type Bid struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	AuctionID uint      `json:"auction_id" gorm:"index"`
+	UserID    uint      `json:"-" gorm:"index"`
+	Amount    float64   `json:"amount" gorm:"type:decimal(10)"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	User      *User     `gorm:"foreignKey:UserID" json:"-"`
+}
+
+func (*Bid) TableName() string {
+	return "auction_bids"
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrAuditLogImmutable = errors.New("audit log entries cannot be changed or deleted")
+
+// AuditLog is append-only. Before and After only hold the fields that changed
+// (or the whole entity for creations and deletions).
+type AuditLog struct {
+	ID         uint            `json:"id" gorm:"primaryKey;autoIncrement"`
+	ActorID    *int            `json:"actor_id" gorm:"index"`
+	ActorRole  string          `json:"actor_role" gorm:"type:varchar(50)"`
+	Action     string          `json:"action" gorm:"type:varchar(100);index"`
+	EntityType string          `json:"entity_type" gorm:"type:varchar(50);index:idx_audit_entity"`
+	EntityID   string          `json:"entity_id" gorm:"type:varchar(50);index:idx_audit_entity"`
+	Before     json.RawMessage `json:"before" gorm:"type:json"`
+	After      json.RawMessage `json:"after" gorm:"type:json"`
+	IP         string          `json:"ip" gorm:"type:varchar(45)"`
+	UserAgent  string          `json:"user_agent" gorm:"type:varchar(255)"`
+	CreatedAt  time.Time       `json:"created_at" gorm:"autoCreateTime;index"`
+}
+
+func (*AuditLog) TableName() string {
+	return "audit_logs"
+}
+
+func (*AuditLog) BeforeUpdate(*gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+func (*AuditLog) BeforeDelete(*gorm.DB) error {
+	return ErrAuditLogImmutable
+}
@@ -4,13 +4,22 @@ import "time"
 
 type CartItem struct {
 	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
-	UserID    uint      `json:"user_id"`
+	// UserID is NULL for guest cart items, GuestCartID is set instead for
+	// visitors without an account.
+	UserID    *uint     `json:"user_id" gorm:"index"`
+	GuestCartID *uint   `json:"guest_cart_id" gorm:"index"`
 	ProductID uint      `json:"product_id"`
+	VariantID *uint     `json:"variant_id" gorm:"index"`
 	Quantity  int       `json:"quantity"`
+	// Price is the unit price the buyer last saw and agreed to.
 	Price     float64   `json:"price"`
+	// ReducedFrom is the quantity before it was lowered to the stock left,
+	// kept until the buyer acknowledges the change.
+	ReducedFrom *int    `json:"reduced_from"`
 	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
 	User    User    `gorm:"foreignKey:UserID" json:"user"`
 	Product Product `gorm:"foreignKey:ProductID" json:"product"`
+	Variant *ProductVariant `gorm:"foreignKey:VariantID" json:"variant,omitempty"`
 }
 
 func (*CartItem) TableName() string {
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Category is a node of the product taxonomy, e.g. Fashion > Tops > Jackets.
+// Root categories have no parent.
+type Category struct {
+	ID              uint             `json:"id" gorm:"primaryKey"`
+	ParentID        *uint            `json:"parent_id" gorm:"index"`
+	Name            string           `json:"name" gorm:"type:varchar(100)"`
+	Slug            string           `json:"slug" gorm:"type:varchar(120);uniqueIndex"`
+	AttributeSchema *AttributeSchema `json:"attribute_schema" gorm:"type:json;serializer:json"`
+	CreatedAt       time.Time        `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time        `json:"updated_at" gorm:"autoUpdateTime"`
+	Children        []Category       `json:"children,omitempty" gorm:"foreignKey:ParentID"`
+}
+
+type CategoryInput struct {
+	Name            string           `json:"name"`
+	Slug            string           `json:"slug"`
+	ParentID        *uint            `json:"parent_id"`
+	AttributeSchema *AttributeSchema `json:"attribute_schema"`
+}
+
+func (*Category) TableName() string {
+	return "categories"
+}
+
+// Slugify turns a name into a lower case URL segment: "Tops & Jackets"
+// becomes "tops-jackets". Accents are dropped.
+func Slugify(name string) string {
+	folded, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), name)
+	if err != nil {
+		folded = name
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(folded), func(r rune) bool {
+		return r > unicode.MaxASCII || (!unicode.IsLetter(r) && !unicode.IsDigit(r))
+	})
+	return strings.Join(words, "-")
+}
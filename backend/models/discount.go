@@ -0,0 +1,71 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// Discount lowers prices between StartsAt and EndsAt. A discount with a
+// ProductID applies to that product, either as a fixed SalePrice or as a
+// Percent off; one without is a shop-wide sale and always uses Percent.
+// When several discounts are running, buyers get the lowest price.
+type Discount struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	ShopID    uint      `json:"shop_id" gorm:"index"`
+	ProductID *uint     `json:"product_id" gorm:"index"`
+	Name      string    `json:"name" gorm:"type:varchar(100)"`
+	SalePrice *float64  `json:"sale_price" gorm:"type:decimal(10)"`
+	Percent   *float64  `json:"percent" gorm:"type:decimal(5,2)"`
+	StartsAt  time.Time `json:"starts_at" gorm:"index"`
+	EndsAt    time.Time `json:"ends_at" gorm:"index"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+type DiscountInput struct {
+	ProductID *uint      `json:"product_id"`
+	Name      *string    `json:"name"`
+	SalePrice *float64   `json:"sale_price"`
+	Percent   *float64   `json:"percent"`
+	StartsAt  *time.Time `json:"starts_at"`
+	EndsAt    *time.Time `json:"ends_at"`
+}
+
+func (*Discount) TableName() string {
+	return "discounts"
+}
+
+// ActiveAt reports whether the discount is running at the given time.
+func (d *Discount) ActiveAt(now time.Time) bool {
+	return !now.Before(d.StartsAt) && now.Before(d.EndsAt)
+}
+
+// Apply returns the discounted price for a regular price. A sale price never
+// raises the price, e.g. of a variant that is already cheaper.
+func (d *Discount) Apply(price float64) float64 {
+	if d.SalePrice != nil {
+		return math.Min(*d.SalePrice, price)
+	}
+	if d.Percent != nil {
+		return math.Round(price * (100 - *d.Percent) / 100)
+	}
+	return price
+}
+
+// PriceQuote is the price a buyer pays for one unit, next to the regular
+// price it was discounted from. OfferID is set when the price was
+// negotiated in an offer, AuctionID when it is a winning bid and
+// FlashClaimID when it comes from a flash sale claim.
+type PriceQuote struct {
+	OriginalPrice float64   `json:"original_price"`
+	Price         float64   `json:"price"`
+	Discount      *Discount `json:"discount,omitempty"`
+	OfferID       *uint     `json:"offer_id,omitempty"`
+	AuctionID     *uint     `json:"auction_id,omitempty"`
+	FlashClaimID  *uint     `json:"flash_sale_claim_id,omitempty"`
+}
+
+// Discounted reports whether a discount lowers the price.
+func (q PriceQuote) Discounted() bool {
+	return q.Price < q.OriginalPrice
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	FlashClaimHeld      = "held"
+	FlashClaimPurchased = "purchased"
+	FlashClaimReleased  = "released"
+)
+
+// FlashSale sells a limited Quantity of a product, or of one variant, at a
+// lower price between StartsAt and EndsAt. Each buyer may claim at most
+// PerUserLimit units, 0 means no limit. Sold counts the units bought and is
+// updated when the claims are reconciled.
+type FlashSale struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	ShopID       uint      `json:"shop_id" gorm:"index"`
+	ProductID    uint      `json:"product_id" gorm:"index"`
+	VariantID    *uint     `json:"variant_id"`
+	Name         string    `json:"name" gorm:"type:varchar(100)"`
+	SalePrice    *float64  `json:"sale_price" gorm:"type:decimal(10)"`
+	Percent      *float64  `json:"percent" gorm:"type:decimal(5,2)"`
+	Quantity     int       `json:"quantity"`
+	PerUserLimit int       `json:"per_user_limit"`
+	Sold         int       `json:"sold"`
+	StartsAt     time.Time `json:"starts_at" gorm:"index"`
+	EndsAt       time.Time `json:"ends_at" gorm:"index"`
+	CreatedAt    time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	Product      *Product  `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	// Remaining is the number of units left to claim, from the allocator.
+	Remaining *int `gorm:"-" json:"remaining,omitempty"`
+}
+
+func (*FlashSale) TableName() string {
+	return "flash_sales"
+}
+
+// ActiveAt reports whether units can be claimed at the given time.
+func (s *FlashSale) ActiveAt(now time.Time) bool {
+	return !now.Before(s.StartsAt) && now.Before(s.EndsAt)
+}
+
+// Apply returns the flash sale price for a regular price.
+func (s *FlashSale) Apply(price float64) float64 {
+	if s.SalePrice != nil {
+		return math.Min(*s.SalePrice, price)
+	}
+	if s.Percent != nil {
+		return math.Round(price * (100 - *s.Percent) / 100)
+	}
+	return price
+}
+
+// FlashSaleClaim is a buyer's allocation of flash sale units. It is held at
+// Price until ExpiresAt and becomes purchased with the order, or released
+// back to the sale when the buyer does not check out in time.
+type FlashSaleClaim struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	FlashSaleID uint      `json:"flash_sale_id" gorm:"index"`
+	UserID      uint      `json:"user_id" gorm:"index"`
+	ProductID   uint      `json:"product_id"`
+	VariantID   *uint     `json:"variant_id"`
+	Quantity    int       `json:"quantity"`
+	Price       float64   `json:"price"`
+	Status      string    `json:"status" gorm:"type:varchar(20);index"`
+	ExpiresAt   time.Time `json:"expires_at" gorm:"index"`
+	OrderID     *uint     `json:"order_id"`
+	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (*FlashSaleClaim) TableName() string {
+	return "flash_sale_claims"
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// GuestCart owns the cart items of a visitor who is not logged in. The
+// visitor is recognised by a signed cookie holding the cart ID.
+type GuestCart struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	LastSeenAt time.Time  `json:"last_seen_at" gorm:"index"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	Items      []CartItem `json:"items,omitempty" gorm:"foreignKey:GuestCartID"`
+}
+
+func (*GuestCart) TableName() string {
+	return "guest_carts"
+}
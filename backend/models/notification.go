@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+const (
+	NotificationPriceDrop   = "price_drop"
+	NotificationBackInStock = "back_in_stock"
+	NotificationOffer       = "offer"
+	NotificationAuction     = "auction"
+	NotificationReview      = "review"
+)
+
+// Notification is an in-app message for a user.
+type Notification struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"index"`
+	Type      string     `json:"type" gorm:"type:varchar(40)"`
+	Title     string     `json:"title" gorm:"type:varchar(150)"`
+	Message   string     `json:"message" gorm:"type:varchar(300)"`
+	ProductID *uint      `json:"product_id"`
+	ReadAt    *time.Time `json:"read_at" gorm:"index"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime;index"`
+}
+
+func (*Notification) TableName() string {
+	return "notifications"
+}
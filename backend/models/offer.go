@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+const (
+	OfferPending   = "pending"
+	OfferCountered = "countered"
+	OfferAccepted  = "accepted"
+	OfferRejected  = "rejected"
+	OfferWithdrawn = "withdrawn"
+	OfferExpired   = "expired"
+	OfferPurchased = "purchased"
+)
+
+// Offer is a price a buyer proposes for a product. A pending offer waits for
+// the seller, a countered one for the buyer; either side can accept, reject
+// or counter while it is their turn and ExpiresAt has not passed. Once
+// accepted, AgreedPrice is what the buyer pays for up to Quantity units until
+// PriceExpiresAt, and only in one order.
+type Offer struct {
+	ID             uint            `json:"id" gorm:"primaryKey"`
+	UserID         uint            `json:"user_id" gorm:"index"`
+	ShopID         uint            `json:"shop_id" gorm:"index"`
+	ProductID      uint            `json:"product_id" gorm:"index"`
+	VariantID      *uint           `json:"variant_id"`
+	Quantity       int             `json:"quantity" gorm:"default:1"`
+	Price          float64         `json:"price"`
+	CounterPrice   *float64        `json:"counter_price"`
+	AgreedPrice    *float64        `json:"agreed_price"`
+	Message        string          `json:"message" gorm:"type:varchar(300)"`
+	Status         string          `json:"status" gorm:"type:varchar(20);index"`
+	ExpiresAt      time.Time       `json:"expires_at" gorm:"index"`
+	PriceExpiresAt *time.Time      `json:"price_expires_at" gorm:"index"`
+	OrderID        *uint           `json:"order_id"`
+	CreatedAt      time.Time       `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time       `json:"updated_at" gorm:"autoUpdateTime"`
+	Product        *Product        `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	Variant        *ProductVariant `gorm:"foreignKey:VariantID" json:"variant,omitempty"`
+}
+
+func (*Offer) TableName() string {
+	return "offers"
+}
+
+// Open reports whether the offer still waits for an answer.
+func (o *Offer) Open(now time.Time) bool {
+	return (o.Status == OfferPending || o.Status == OfferCountered) && now.Before(o.ExpiresAt)
+}
+
+// Usable reports whether the agreed price can still be used at checkout.
+func (o *Offer) Usable(now time.Time) bool {
+	return o.Status == OfferAccepted && o.AgreedPrice != nil && o.OrderID == nil &&
+		o.PriceExpiresAt != nil && now.Before(*o.PriceExpiresAt)
+}
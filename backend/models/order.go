@@ -14,6 +14,11 @@ type Order struct {
 	Note           string     `json:"note" gorm:"type:text"`
 	ProofPayment   string     `json:"proof_payment" gorm:"type:varchar(255)"`
 	CancelBy *string `json:"cancel_by" gorm:"type:varchar(50);default:null"`
+	VoucherID       *uint   `json:"voucher_id"`
+	VoucherCode     string  `json:"voucher_code" gorm:"type:varchar(32)"`
+	VoucherDiscount float64 `json:"voucher_discount"`
+	RefundedAt      *time.Time `json:"refunded_at"`
+	RefundedBy      *uint      `json:"refunded_by"`
 	CreatedAt      time.Time  `json:"created_at" gorm:"autoCreateTime"`
 	DeletedAt      *time.Time `json:"deleted_at" gorm:"index"`
 	User       User        `gorm:"foreignKey:UserID" json:"user"`
@@ -6,12 +6,36 @@ type OrderItem struct {
 	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
 	OrderID   uint      `json:"order_id"`
 	ProductID uint      `json:"product_id"`
+	VariantID *uint     `json:"variant_id" gorm:"index"`
 	Quantity  int       `json:"quantity"`
 	Price     float64   `json:"price"`
 	SubTotal  float64   `json:"sub_total"`
+	// OriginalPrice is the regular unit price when Price was discounted.
+	OriginalPrice float64 `json:"original_price"`
+	DiscountID    *uint   `json:"discount_id"`
+	OfferID       *uint   `json:"offer_id"`
+	FlashClaimID  *uint   `json:"flash_sale_claim_id"`
+	// The product as it was at checkout, so later edits or deletion of the
+	// product do not change the order.
+	ProductName  string  `json:"product_name" gorm:"type:varchar(100)"`
+	ProductLabel string  `json:"product_label" gorm:"type:varchar(100)"`
+	ProductImage string  `json:"product_image" gorm:"type:varchar(100);index"`
+	CategoryName string  `json:"category_name" gorm:"type:varchar(100)"`
+	VariantLabel string  `json:"variant_label" gorm:"type:varchar(80)"`
+	SKU          string  `json:"sku" gorm:"type:varchar(64)"`
+	Attributes   *ProductSnapshotAttributes `json:"attributes" gorm:"type:json;serializer:json"`
 	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
 	Order   Order   `gorm:"foreignKey:OrderID" json:"order"`
 	Product Product `gorm:"foreignKey:ProductID" json:"product"`
+	Variant *ProductVariant `gorm:"foreignKey:VariantID" json:"variant,omitempty"`
+}
+
+type ProductSnapshotAttributes struct {
+	Condition    string             `json:"condition,omitempty"`
+	Size         string             `json:"size,omitempty"`
+	Brand        string             `json:"brand,omitempty"`
+	Material     string             `json:"material,omitempty"`
+	Measurements map[string]float64 `json:"measurements,omitempty"`
 }
 
 func (*OrderItem) TableName() string {
@@ -1,22 +1,74 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ProductStatusDraft     = "draft"
+	ProductStatusPublished = "published"
+	ProductStatusHidden    = "hidden"
+	ProductStatusSoldOut   = "sold_out"
+)
+
+var ProductStatuses = []string{ProductStatusDraft, ProductStatusPublished, ProductStatusHidden, ProductStatusSoldOut}
 
 type Product struct {
 	ID        	uint       `json:"id"    gorm:"primaryKey"`
 	ShopID    	uint       `json:"shop_id"`
 	Name      	string    `json:"name" gorm:"type:varchar(100)"`
-	Category  	string    `json:"category" gorm:"type:varchar(100)"`
+	CategoryID	*uint     `json:"category_id" gorm:"index"`
 	Label		string    `json:"label" gorm:"type:varchar(100)"`
 	Description string    `json:"description" gorm:"type:varchar(300)"`
 	Image     	string    `json:"image" gorm:"type:varchar(100)"`
 	Price     	float64   `json:"price" gorm:"type:decimal(10)"`
 	Stock     	int       `json:"stock" gorm:"type:int"`
+	Status		string    `json:"status" gorm:"type:varchar(20);default:published;index"`
+	// ListingType is auction for products sold through an Auction instead
+	// of the cart.
+	ListingType	string    `json:"listing_type" gorm:"type:varchar(20);default:fixed;index"`
+	// PublishAt publishes a draft automatically once it has passed.
+	PublishAt	*time.Time `json:"publish_at" gorm:"index"`
+	Condition	string    `json:"condition" gorm:"type:varchar(20);index"`
+	Size		string    `json:"size" gorm:"type:varchar(20);index"`
+	Brand		string    `json:"brand" gorm:"type:varchar(100);index"`
+	Material	string    `json:"material" gorm:"type:varchar(100)"`
+	Measurements map[string]float64 `json:"measurements" gorm:"type:json;serializer:json"`
 	CreatedAt 	time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt 	time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	// ArchivedAt hides the product from buyers until the seller restores it.
+	ArchivedAt	*time.Time `json:"archived_at" gorm:"index"`
+	// DeletedAt soft deletes products that still appear in past orders.
+	DeletedAt	gorm.DeletedAt `json:"-" gorm:"index"`
 	Shop      	Shop      `gorm:"foreignKey:ShopID" json:"shop"`
+	Category	*Category `gorm:"foreignKey:CategoryID" json:"category,omitempty"`
+	Variants	[]ProductVariant `gorm:"foreignKey:ProductID" json:"variants,omitempty"`
+	// FinalPrice and Discount are the current price after discounts, filled
+	// in for buyers. Price stays the regular price.
+	FinalPrice	*float64  `gorm:"-" json:"final_price,omitempty"`
+	Discount	*Discount `gorm:"-" json:"discount,omitempty"`
+	// WishlistCount is how many users wishlisted the product, shown to the
+	// shop owner only.
+	WishlistCount *int64 `gorm:"-" json:"wishlist_count,omitempty"`
 }
 
 func (*Product) TableName() string {
 	return "products"
 }
+
+// IsPublished reports whether buyers can see and buy the product, counting a
+// draft whose scheduled publish time has passed as published.
+func (p *Product) IsPublished(now time.Time) bool {
+	if p.ArchivedAt != nil {
+		return false
+	}
+	switch p.Status {
+	case ProductStatusPublished, "":
+		return true
+	case ProductStatusDraft:
+		return p.PublishAt != nil && !now.Before(*p.PublishAt)
+	}
+	return false
+}
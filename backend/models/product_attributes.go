@@ -0,0 +1,26 @@
+package models
+
+// Condition grades for second-hand products, best first.
+const (
+	ConditionNewWithTags = "new_with_tags"
+	ConditionLikeNew     = "like_new"
+	ConditionGood        = "good"
+	ConditionFair        = "fair"
+)
+
+var ProductConditions = []string{ConditionNewWithTags, ConditionLikeNew, ConditionGood, ConditionFair}
+
+// ProductAttributeFields are the structured attributes a category schema can
+// make required.
+var ProductAttributeFields = []string{"condition", "size", "brand", "material", "measurements"}
+
+// AttributeSchema describes which attributes products of a category need.
+// Subcategories without a schema use the schema of their closest ancestor.
+type AttributeSchema struct {
+	// Required lists attributes from ProductAttributeFields that must be set.
+	Required []string `json:"required"`
+	// Sizes limits the size to these values when not empty, e.g. S, M, L.
+	Sizes []string `json:"sizes"`
+	// Measurements lists the allowed measurement keys, e.g. chest_cm.
+	Measurements []string `json:"measurements"`
+}
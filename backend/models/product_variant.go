@@ -0,0 +1,61 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// ProductVariant is one purchasable option of a product, e.g. size M in red.
+// Products without variants are sold from the product's own price and stock.
+type ProductVariant struct {
+	ID        uint   `json:"id" gorm:"primaryKey"`
+	ProductID uint   `json:"product_id" gorm:"index"`
+	SKU       string `json:"sku" gorm:"type:varchar(64);uniqueIndex"`
+	Size      string `json:"size" gorm:"type:varchar(20)"`
+	Color     string `json:"color" gorm:"type:varchar(50)"`
+	// Price overrides the product price when set.
+	Price     *float64  `json:"price" gorm:"type:decimal(10)"`
+	Stock     int       `json:"stock" gorm:"type:int"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	// FinalPrice is the current price after discounts, see Product.
+	FinalPrice *float64 `json:"final_price,omitempty" gorm:"-"`
+	// Availability is in_stock, reserved or sold_out, filled in for buyers.
+	Availability string `json:"availability,omitempty" gorm:"-"`
+}
+
+type ProductVariantInput struct {
+	SKU   *string  `json:"sku"`
+	Size  *string  `json:"size"`
+	Color *string  `json:"color"`
+	Price *float64 `json:"price"`
+	Stock *int     `json:"stock"`
+	// ClearPrice removes the price override on update.
+	ClearPrice bool `json:"clear_price"`
+}
+
+func (*ProductVariant) TableName() string {
+	return "product_variants"
+}
+
+// UnitPrice is the price of the variant, falling back to the product price.
+func (v *ProductVariant) UnitPrice(product *Product) float64 {
+	if v.Price != nil {
+		return *v.Price
+	}
+	return product.Price
+}
+
+// Label describes the variant for carts and orders, e.g. "M / Red".
+func (v *ProductVariant) Label() string {
+	parts := []string{}
+	for _, part := range []string{v.Size, v.Color} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return v.SKU
+	}
+	return strings.Join(parts, " / ")
+}
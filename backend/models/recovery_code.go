@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+type RecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID    int        `json:"user_id" gorm:"index"`
+	CodeHash  string     `json:"-" gorm:"type:varchar(64)"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	User      User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;" json:"-"`
+}
+
+func (*RecoveryCode) TableName() string {
+	return "recovery_codes"
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+const (
+	ReviewPublished = "published"
+	ReviewHidden    = "hidden"
+)
+
+// Review is a buyer's rating of one delivered order item, with an optional
+// text and photos. The seller may reply once. Hidden reviews were taken down
+// by a moderator and do not count towards ratings.
+type Review struct {
+	ID               uint       `json:"id" gorm:"primaryKey"`
+	OrderItemID      uint       `json:"order_item_id" gorm:"uniqueIndex"`
+	OrderID          uint       `json:"order_id"`
+	UserID           uint       `json:"-" gorm:"index"`
+	ShopID           uint       `json:"shop_id" gorm:"index"`
+	ProductID        uint       `json:"product_id" gorm:"index"`
+	VariantLabel     string     `json:"variant_label" gorm:"type:varchar(80)"`
+	Rating           int        `json:"rating"`
+	Comment          string     `json:"comment" gorm:"type:text"`
+	Photos           []string   `json:"photos" gorm:"type:json;serializer:json"`
+	Reply            string     `json:"reply" gorm:"type:text"`
+	RepliedAt        *time.Time `json:"replied_at"`
+	Status           string     `json:"status" gorm:"type:varchar(20);index;default:published"`
+	ModerationReason string     `json:"moderation_reason,omitempty" gorm:"type:varchar(255)"`
+	ModeratedBy      *uint      `json:"moderated_by,omitempty"`
+	ModeratedAt      *time.Time `json:"moderated_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt        time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+	User             *User      `gorm:"foreignKey:UserID" json:"-"`
+	// Reviewer is the masked username shown with the review.
+	Reviewer string `gorm:"-" json:"reviewer"`
+}
+
+func (*Review) TableName() string {
+	return "reviews"
+}
+
+// RatingSummary aggregates the published reviews of a product or shop.
+type RatingSummary struct {
+	Average float64       `json:"average"`
+	Count   int64         `json:"count"`
+	Stars   map[int]int64 `json:"stars"`
+}
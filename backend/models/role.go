@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+type Role struct {
+	Name        string           `json:"name" gorm:"primaryKey;type:varchar(50)"`
+	Description string           `json:"description" gorm:"type:varchar(255)"`
+	CreatedAt   time.Time        `json:"created_at" gorm:"autoCreateTime"`
+	Permissions []RolePermission `json:"permissions" gorm:"foreignKey:Role;references:Name"`
+}
+
+// Permission lists every permission the code knows about. A row is only
+// written once, so grants an admin later removes are not restored.
+type Permission struct {
+	Name        string    `json:"name" gorm:"primaryKey;type:varchar(100)"`
+	Description string    `json:"description" gorm:"type:varchar(255)"`
+	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+type RolePermission struct {
+	ID         uint   `json:"-" gorm:"primaryKey;autoIncrement"`
+	Role       string `json:"-" gorm:"type:varchar(50);uniqueIndex:idx_role_permission"`
+	Permission string `json:"permission" gorm:"type:varchar(100);uniqueIndex:idx_role_permission"`
+}
+
+func (*Role) TableName() string {
+	return "roles"
+}
+
+func (*Permission) TableName() string {
+	return "permissions"
+}
+
+func (*RolePermission) TableName() string {
+	return "role_permissions"
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+const (
+	SettingRequireAdmin2FA    = "require_admin_2fa"
+	SettingReservationMinutes = "reservation_minutes"
+)
+
+type Setting struct {
+	Key       string    `json:"key" gorm:"primaryKey;type:varchar(100)"`
+	Value     string    `json:"value" gorm:"type:varchar(255)"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (*Setting) TableName() string {
+	return "settings"
+}
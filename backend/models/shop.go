@@ -1,12 +1,18 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type Shop struct {
 	ID          	uint      	`gorm:"primaryKey" json:"id"`
 	UserID      	uint      	`json:"user_id"`
 	User          	User      `json:"-" gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;"`
 	ShopName        string    	`json:"shop_name"`
+	Slug            string    	`json:"slug" gorm:"type:varchar(120);index"`
 	ShopTelephone   string    	`json:"shop_telephone"`
 	ShopAddress     string    	`json:"shop_address"`
 	AccountNumber 	string    	`json:"account_number"`
@@ -20,6 +26,7 @@ type ShopResponse struct {
 	ID            uint   `json:"id"`
 	UserID        uint   `json:"user_id"`
 	ShopName      string `json:"shop_name"`
+	Slug          string `json:"slug"`
 	ShopTelephone string `json:"shop_telephone"`
 	ShopAddress   string `json:"shop_address"`
 	AccountNumber string `json:"account_number"`
@@ -30,4 +37,23 @@ type ShopResponse struct {
 
 func (*Shop) TableName() string {
 	return "shops"
-}
\ No newline at end of file
+}
+
+// ShopSlug returns a free slug for a shop name. Numbers are shop IDs in
+// URLs, so a name without letters becomes "shop". taken reports whether a
+// slug is in use; -2, -3 and so on are appended until one is free.
+func ShopSlug(name string, taken func(slug string) bool) string {
+	base := Slugify(name)
+	if len(base) > 100 {
+		base = strings.Trim(base[:100], "-")
+	}
+	if _, err := strconv.Atoi(base); err == nil || base == "" {
+		base = "shop"
+	}
+
+	slug := base
+	for i := 2; taken(slug); i++ {
+		slug = fmt.Sprintf("%s-%d", base, i)
+	}
+	return slug
+}
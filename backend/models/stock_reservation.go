@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// StockReservation holds stock of a cart item for a buyer who started
+// checkout, so nobody else can buy it until the hold expires or the order
+// is placed. The product stock itself only changes when the order is
+// created.
+type StockReservation struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	UserID     uint      `json:"user_id" gorm:"index"`
+	CartItemID uint      `json:"cart_item_id" gorm:"uniqueIndex"`
+	ProductID  uint      `json:"product_id" gorm:"index"`
+	VariantID  *uint     `json:"variant_id" gorm:"index"`
+	Quantity   int       `json:"quantity"`
+	ExpiresAt  time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt  time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (*StockReservation) TableName() string {
+	return "stock_reservations"
+}
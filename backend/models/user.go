@@ -1,5 +1,14 @@
 package models
 
+import "time"
+
+const (
+	UserStatusActive    = "active"
+	UserStatusSuspended = "suspended"
+	UserStatusBanned    = "banned"
+	UserStatusDeleted   = "deleted"
+)
+
 type User struct {
 	ID         		int       	`json:"id" gorm:"primaryKey"`
 	Username       	string    	`json:"username" gorm:"type:varchar(100)"`
@@ -7,8 +16,17 @@ type User struct {
 	Password   		string    	`json:"-" gorm:"type:varchar(100)"`
 	Address   		string    	`json:"address" gorm:"type:varchar(200)"`
 	Telephone   	string    	`json:"telephone" gorm:"type:varchar(15)"`
-	Role			string		`json:"role" gorm:"type:enum('admin','seller','buyer');default('buyer')"`
+	Role			string		`json:"role" gorm:"type:varchar(50);default('buyer')"`
 	ProfilePicture	string		`json:"profile_picture" gorm:"type:varchar(100);default('https://i.pravatar.cc/150')"`
+	TwoFactorEnabled bool		`json:"two_factor_enabled" gorm:"default:false"`
+	TwoFactorSecret	string		`json:"-" gorm:"type:varchar(64)"`
+	// TwoFactorLastStep is the TOTP time step of the last accepted code, so
+	// a code works only once.
+	TwoFactorLastStep int64		`json:"-" gorm:"default:0"`
+	Status			string		`json:"status" gorm:"type:varchar(20);default:active"`
+	StatusReason	string		`json:"status_reason" gorm:"type:varchar(255)"`
+	StatusUntil		*time.Time	`json:"status_until"`
+	MustResetPassword bool		`json:"must_reset_password" gorm:"default:false"`
 	Shop      		*Shop      	`gorm:"foreignKey:UserID"`
 }
 
@@ -23,6 +41,30 @@ type LoginInput struct {
     Password string `json:"password" validate:"required"`
 }
 
+type TwoFactorLoginInput struct {
+    MfaToken     string `json:"mfa_token" validate:"required"`
+    Code         string `json:"code"`
+    RecoveryCode string `json:"recovery_code"`
+}
+
+type ResetPasswordInput struct {
+    ResetToken  string `json:"reset_token" validate:"required"`
+    NewPassword string `json:"new_password" validate:"required"`
+}
+
 func (*User) TableName() string {
 	return "user"
+}
+
+// IsBlocked reports whether the account may not log in or use a session at
+// the given time. A suspension or ban with an expiry lifts itself once the
+// expiry has passed.
+func (u *User) IsBlocked(now time.Time) bool {
+	switch u.Status {
+	case UserStatusDeleted:
+		return true
+	case UserStatusSuspended, UserStatusBanned:
+		return u.StatusUntil == nil || now.Before(*u.StatusUntil)
+	}
+	return false
 }
\ No newline at end of file
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	VoucherPercent = "percent"
+	VoucherFixed   = "fixed"
+)
+
+// Voucher is a code buyers enter at checkout. Vouchers without a ShopID are
+// issued by the platform and work in every shop, the others only in their
+// own shop. CategoryIDs and ProductIDs restrict the items the voucher
+// applies to; when both are empty it applies to the whole order.
+type Voucher struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	Code         string    `json:"code" gorm:"type:varchar(32);uniqueIndex"`
+	ShopID       *uint     `json:"shop_id" gorm:"index"`
+	Name         string    `json:"name" gorm:"type:varchar(100)"`
+	Type         string    `json:"type" gorm:"type:varchar(10)"`
+	Value        float64   `json:"value" gorm:"type:decimal(10,2)"`
+	MaxDiscount  *float64  `json:"max_discount" gorm:"type:decimal(10)"`
+	MinSpend     float64   `json:"min_spend" gorm:"type:decimal(10)"`
+	UsageLimit   *int      `json:"usage_limit"`
+	PerUserLimit *int      `json:"per_user_limit"`
+	UsedCount    int       `json:"used_count" gorm:"default:0"`
+	CategoryIDs  []uint    `json:"category_ids" gorm:"type:json;serializer:json"`
+	ProductIDs   []uint    `json:"product_ids" gorm:"type:json;serializer:json"`
+	StartsAt     time.Time `json:"starts_at" gorm:"index"`
+	EndsAt       time.Time `json:"ends_at" gorm:"index"`
+	Active       bool      `json:"active"`
+	CreatedAt    time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+type VoucherInput struct {
+	Code         *string    `json:"code"`
+	ShopID       *uint      `json:"shop_id"`
+	Name         *string    `json:"name"`
+	Type         *string    `json:"type"`
+	Value        *float64   `json:"value"`
+	MaxDiscount  *float64   `json:"max_discount"`
+	MinSpend     *float64   `json:"min_spend"`
+	UsageLimit   *int       `json:"usage_limit"`
+	PerUserLimit *int       `json:"per_user_limit"`
+	CategoryIDs  *[]uint    `json:"category_ids"`
+	ProductIDs   *[]uint    `json:"product_ids"`
+	StartsAt     *time.Time `json:"starts_at"`
+	EndsAt       *time.Time `json:"ends_at"`
+	Active       *bool      `json:"active"`
+}
+
+func (*Voucher) TableName() string {
+	return "vouchers"
+}
+
+// ActiveAt reports whether the voucher can be redeemed at the given time.
+func (v *Voucher) ActiveAt(now time.Time) bool {
+	return v.Active && !now.Before(v.StartsAt) && now.Before(v.EndsAt)
+}
+
+// Restricted reports whether the voucher only applies to some items.
+func (v *Voucher) Restricted() bool {
+	return len(v.CategoryIDs) > 0 || len(v.ProductIDs) > 0
+}
+
+// Amount returns the discount for the subtotal of the items the voucher
+// applies to. It never exceeds that subtotal.
+func (v *Voucher) Amount(subtotal float64) float64 {
+	amount := v.Value
+	if v.Type == VoucherPercent {
+		amount = math.Round(subtotal * v.Value / 100)
+		if v.MaxDiscount != nil {
+			amount = math.Min(amount, *v.MaxDiscount)
+		}
+	}
+	return math.Min(amount, subtotal)
+}
+
+// VoucherRedemption records that a user redeemed a voucher on an order. It
+// is what per-user limits are counted from.
+type VoucherRedemption struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	VoucherID uint      `json:"voucher_id" gorm:"index"`
+	UserID    uint      `json:"user_id" gorm:"index"`
+	OrderID   uint      `json:"order_id" gorm:"index"`
+	Discount  float64   `json:"discount" gorm:"type:decimal(10)"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (*VoucherRedemption) TableName() string {
+	return "voucher_redemptions"
+}
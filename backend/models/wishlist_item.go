@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// WishlistItem bookmarks a product, or one variant of it, for a user. Items
+// saved for later come from the cart and keep their quantity.
+type WishlistItem struct {
+	ID            uint  `json:"id" gorm:"primaryKey"`
+	UserID        uint  `json:"user_id" gorm:"index"`
+	ProductID     uint  `json:"product_id" gorm:"index"`
+	VariantID     *uint `json:"variant_id" gorm:"index"`
+	Quantity      int   `json:"quantity" gorm:"default:1"`
+	SavedForLater bool  `json:"saved_for_later" gorm:"index"`
+	// NotifiedPrice and NotifiedInStock are what the user was last told
+	// about, so each price drop or restock is notified once.
+	NotifiedPrice   float64         `json:"-"`
+	NotifiedInStock bool            `json:"-"`
+	CreatedAt       time.Time       `json:"created_at" gorm:"autoCreateTime"`
+	Product         Product         `json:"product" gorm:"foreignKey:ProductID"`
+	Variant         *ProductVariant `json:"variant,omitempty" gorm:"foreignKey:VariantID"`
+}
+
+func (*WishlistItem) TableName() string {
+	return "wishlist_items"
+}
@@ -0,0 +1,146 @@
+package rbac
+
+import (
+	"sync"
+
+	"finpro/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ProductWrite     = "product:write"
+	ShopCreate       = "shop:create"
+	ShopView         = "shop:view"
+	ShopWrite        = "shop:write"
+	ShopApprove      = "shop:approve"
+	UserRead         = "user:read"
+	UserManage       = "user:manage"
+	OrderProcess     = "order:process"
+	OrderViewAll     = "order:view_all"
+	OrderRefund      = "order:refund"
+	RoleManage       = "role:manage"
+	SettingManage    = "setting:manage"
+	AuditRead        = "audit:read"
+	AccountTwoFactor = "account:2fa"
+	APIKeyManage     = "apikey:manage"
+	CategoryManage   = "category:manage"
+	VoucherManage    = "voucher:manage"
+	ReviewModerate   = "review:moderate"
+)
+
+type definition struct {
+	Name        string
+	Description string
+	Roles       []string
+}
+
+// definitions are the permissions known to the code and the roles that get
+// them by default. Admins can change the grants afterwards.
+var definitions = []definition{
+	{ProductWrite, "Create, edit and delete products of the own shop", []string{"seller"}},
+	{ShopCreate, "Register a new shop", []string{"buyer"}},
+	{ShopView, "View shop details", []string{"buyer", "seller", "admin", "moderator", "support"}},
+	{ShopWrite, "Edit the own shop", []string{"seller", "admin"}},
+	{ShopApprove, "List, approve and reject shop registrations", []string{"admin"}},
+	{UserRead, "List and view user accounts", []string{"admin", "moderator", "support"}},
+	{UserManage, "Suspend, ban, reinstate and delete users, change roles and force password resets", []string{"admin"}},
+	{OrderProcess, "View sales, accept payments and update shipping of the own shop", []string{"seller"}},
+	{OrderViewAll, "View orders of every user and shop", []string{"admin", "moderator", "support"}},
+	{OrderRefund, "Cancel and refund any order", []string{"admin", "support"}},
+	{RoleManage, "Manage roles and their permissions", []string{"admin"}},
+	{SettingManage, "Change platform settings", []string{"admin"}},
+	{AuditRead, "Query and export the audit log", []string{"admin"}},
+	{AccountTwoFactor, "Set up two-factor authentication", []string{"seller", "admin"}},
+	{APIKeyManage, "Create and revoke API keys for scripted access", []string{"seller"}},
+	{CategoryManage, "Create, edit and delete product categories", []string{"admin"}},
+	{VoucherManage, "Create, edit and delete platform vouchers and the vouchers of any shop", []string{"admin"}},
+	{ReviewModerate, "Hide, restore and delete product reviews", []string{"admin", "moderator"}},
+}
+
+var defaultRoles = []models.Role{
+	{Name: "admin", Description: "Platform administrator"},
+	{Name: "seller", Description: "Approved shop owner"},
+	{Name: "buyer", Description: "Registered customer"},
+	{Name: "moderator", Description: "Reviews users and orders"},
+	{Name: "support", Description: "Customer support staff"},
+}
+
+// BuiltinRoles cannot be deleted because the application assigns them itself.
+var BuiltinRoles = map[string]bool{"admin": true, "seller": true, "buyer": true}
+
+var cache = struct {
+	sync.RWMutex
+	grants map[string]map[string]bool
+}{grants: map[string]map[string]bool{}}
+
+// Seed creates the default roles and grants every permission that has never
+// been registered before to its default roles.
+func Seed(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, role := range defaultRoles {
+			role := role
+			if err := tx.Where(models.Role{Name: role.Name}).FirstOrCreate(&role).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, def := range definitions {
+			var count int64
+			if err := tx.Model(&models.Permission{}).Where("name = ?", def.Name).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				continue
+			}
+
+			if err := tx.Create(&models.Permission{Name: def.Name, Description: def.Description}).Error; err != nil {
+				return err
+			}
+			for _, role := range def.Roles {
+				if err := tx.Create(&models.RolePermission{Role: role, Permission: def.Name}).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// Load replaces the in-memory grant table. Call it again after roles change.
+func Load(db *gorm.DB) error {
+	var rows []models.RolePermission
+	if err := db.Find(&rows).Error; err != nil {
+		return err
+	}
+
+	grants := map[string]map[string]bool{}
+	for _, row := range rows {
+		if grants[row.Role] == nil {
+			grants[row.Role] = map[string]bool{}
+		}
+		grants[row.Role][row.Permission] = true
+	}
+
+	cache.Lock()
+	cache.grants = grants
+	cache.Unlock()
+	return nil
+}
+
+func Can(role string, permission string) bool {
+	cache.RLock()
+	defer cache.RUnlock()
+
+	return cache.grants[role][permission]
+}
+
+func Known(permission string) bool {
+	for _, def := range definitions {
+		if def.Name == permission {
+			return true
+		}
+	}
+	return false
+}
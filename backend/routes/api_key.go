@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"finpro/controllers"
+	"finpro/middleware"
+	"finpro/rbac"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func APIKeyRoutes(api fiber.Router) {
+	apiKey := api.Group("/api-keys", middleware.Protected(), middleware.RequirePermission(rbac.APIKeyManage))
+
+	apiKey.Get("/", controllers.GetAPIKeys)
+	apiKey.Post("/", controllers.CreateAPIKey)
+	apiKey.Delete("/:id", controllers.RevokeAPIKey)
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"finpro/controllers"
+	"finpro/middleware"
+	"finpro/rbac"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func AuctionRoutes(api fiber.Router) {
+	auction := api.Group("/auctions")
+
+	auction.Get("/", controllers.GetAuctions)
+	auction.Get("/mine", middleware.Protected(), middleware.RequirePermission(rbac.ProductWrite), controllers.GetMyAuctions)
+	auction.Get("/:id", controllers.GetAuction)
+	auction.Post("/", middleware.Protected(), middleware.RequirePermission(rbac.ProductWrite), controllers.CreateAuction)
+	auction.Delete("/:id", middleware.Protected(), middleware.RequirePermission(rbac.ProductWrite), controllers.CancelAuction)
+	auction.Post("/:id/bids", middleware.Protected(), controllers.PlaceBid)
+}
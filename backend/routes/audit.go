@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"finpro/controllers"
+	"finpro/middleware"
+	"finpro/rbac"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func AuditRoutes(api fiber.Router) {
+	audit := api.Group("/audit-logs", middleware.Protected(), middleware.RequirePermission(rbac.AuditRead))
+
+	audit.Get("/", controllers.GetAuditLogs)
+	audit.Get("/export", controllers.ExportAuditLogs)
+}
@@ -7,6 +7,10 @@ import (
 
 func AuthRoutes(api fiber.Router) {
 	api.Post("/login", controllers.Login)
+	api.Post("/login/2fa", controllers.VerifyLogin2FA)
+	api.Post("/login/2fa/setup", controllers.SetupLogin2FA)
+	api.Post("/login/2fa/enable", controllers.EnableLogin2FA)
+	api.Post("/login/reset-password", controllers.ResetPasswordLogin)
 	api.Post("/register", controllers.SignUp)
 	api.Post("/logout", controllers.Logout)
-}
\ No newline at end of file
+}
@@ -9,8 +9,12 @@ import (
 func CartRoutes(api fiber.Router) {
 	cart := api.Group("/cart")
 
-	cart.Get("/",middleware.Protected(), controllers.GetAllCart)
-	cart.Post("/",middleware.Protected(), controllers.AddToCart)
-	cart.Patch("/:cart_id",middleware.Protected(), controllers.UpdateCartQuantity)
-	cart.Delete("/:cart_id",middleware.Protected(), controllers.DeleteCartItem)
+	// Visitors can use a guest cart, checkout needs an account.
+	cart.Get("/",middleware.ProtectedOrGuest(), controllers.GetAllCart)
+	cart.Post("/",middleware.ProtectedOrGuest(), controllers.AddToCart)
+	cart.Post("/acknowledge",middleware.ProtectedOrGuest(), controllers.AcknowledgeCart)
+	cart.Post("/checkout",middleware.Protected(), controllers.StartCheckout)
+	cart.Post("/:cart_id/save-for-later",middleware.Protected(), controllers.SaveForLater)
+	cart.Patch("/:cart_id",middleware.ProtectedOrGuest(), controllers.UpdateCartQuantity)
+	cart.Delete("/:cart_id",middleware.ProtectedOrGuest(), controllers.DeleteCartItem)
 }
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"finpro/controllers"
+	"finpro/middleware"
+	"finpro/rbac"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func CategoryRoutes(api fiber.Router) {
+	category := api.Group("/categories")
+
+	category.Get("/", controllers.GetAllCategories)
+	category.Get("/:category", controllers.GetCategory)
+	category.Post("/", middleware.Protected(), middleware.RequirePermission(rbac.CategoryManage), controllers.CreateCategory)
+	category.Patch("/:id", middleware.Protected(), middleware.RequirePermission(rbac.CategoryManage), controllers.UpdateCategory)
+	category.Delete("/:id", middleware.Protected(), middleware.RequirePermission(rbac.CategoryManage), controllers.DeleteCategory)
+}
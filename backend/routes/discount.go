@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"finpro/controllers"
+	"finpro/middleware"
+	"finpro/rbac"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func DiscountRoutes(api fiber.Router) {
+	discount := api.Group("/discounts", middleware.ProtectedOrAPIKey(), middleware.RequirePermission(rbac.ProductWrite))
+
+	discount.Get("/", controllers.GetMyDiscounts)
+	discount.Post("/", controllers.CreateDiscount)
+	discount.Patch("/:id", controllers.UpdateDiscount)
+	discount.Delete("/:id", controllers.DeleteDiscount)
+}
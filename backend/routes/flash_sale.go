@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"finpro/controllers"
+	"finpro/middleware"
+	"finpro/rbac"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func FlashSaleRoutes(api fiber.Router) {
+	flashSale := api.Group("/flash-sales")
+
+	flashSale.Get("/", controllers.GetFlashSales)
+	flashSale.Get("/mine", middleware.Protected(), middleware.RequirePermission(rbac.ProductWrite), controllers.GetMyFlashSales)
+	flashSale.Post("/", middleware.Protected(), middleware.RequirePermission(rbac.ProductWrite), controllers.CreateFlashSale)
+	flashSale.Delete("/:id", middleware.Protected(), middleware.RequirePermission(rbac.ProductWrite), controllers.DeleteFlashSale)
+	flashSale.Post("/:id/claim", middleware.Protected(), controllers.ClaimFlashSale)
+}
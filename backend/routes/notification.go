@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"finpro/controllers"
+	"finpro/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func NotificationRoutes(api fiber.Router) {
+	notification := api.Group("/notifications", middleware.Protected())
+
+	notification.Get("/", controllers.GetNotifications)
+	notification.Patch("/read-all", controllers.MarkAllNotificationsRead)
+	notification.Patch("/:id/read", controllers.MarkNotificationRead)
+}
@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"finpro/controllers"
+	"finpro/middleware"
+	"finpro/rbac"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func OfferRoutes(api fiber.Router) {
+	offer := api.Group("/offers", middleware.Protected())
+
+	offer.Post("/", controllers.MakeOffer)
+	offer.Get("/", controllers.GetMyOffers)
+	offer.Get("/shop", middleware.RequirePermission(rbac.OrderProcess), controllers.GetShopOffers)
+	offer.Patch("/:id/accept", controllers.AcceptOffer)
+	offer.Patch("/:id/reject", controllers.RejectOffer)
+	offer.Patch("/:id/counter", controllers.CounterOffer)
+	offer.Patch("/:id/withdraw", controllers.WithdrawOffer)
+}
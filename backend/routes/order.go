@@ -3,6 +3,7 @@ package routes
 import (
 	"finpro/controllers"
 	"finpro/middleware"
+	"finpro/rbac"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -13,13 +14,16 @@ func OrderRoutes(api fiber.Router) {
 	order.Post("/", controllers.CreateOrder)
 	order.Get("/", controllers.GetAllOrder)
 	order.Get("/history", controllers.GetAllOrderHistory)
+	order.Get("/all", middleware.RequirePermission(rbac.OrderViewAll), controllers.GetAllOrdersAdmin)
 	order.Get("/:id", controllers.GetOrderDetail)
 
+	order.Patch("/:id/proof-payment", controllers.UploadProofPayment)
 	order.Patch("/:id/cancel", controllers.CancelOrder)
 	order.Patch("/:id/reject-cancel", controllers.RejectCancel)
 	order.Patch("/:id/accept-cancel", controllers.AcceptCancel)
+	order.Patch("/:id/refund", middleware.RequirePermission(rbac.OrderRefund), controllers.RefundOrder)
 
-	order.Get("/sales/:shop_id",middleware.RequireRole("seller"), controllers.GetAllSales)
-	order.Patch("/:id/accept-payment",middleware.RequireRole("seller"), controllers.AcceptPayment)
-	order.Patch("/:id/status",middleware.RequireRole("seller"), controllers.ChangeStatusShipping)
+	order.Get("/sales/:shop_id",middleware.RequirePermission(rbac.OrderProcess), controllers.GetAllSales)
+	order.Patch("/:id/accept-payment",middleware.RequirePermission(rbac.OrderProcess), controllers.AcceptPayment)
+	order.Patch("/:id/status",middleware.RequirePermission(rbac.OrderProcess), controllers.ChangeStatusShipping)
 }
@@ -3,6 +3,7 @@ package routes
 import (
 	"finpro/controllers"
 	"finpro/middleware"
+	"finpro/rbac"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -13,8 +14,20 @@ func ProductsRoutes(api fiber.Router) {
 	product.Get("/", controllers.GetAllProducts)
 	product.Get("/category/:category", controllers.GetProductByCategory)
 	product.Get("/search", controllers.SearchProduct)
+	product.Get("/suggest", controllers.SuggestProducts)
+	product.Get("/mine", middleware.ProtectedOrAPIKey(), middleware.RequirePermission(rbac.ProductWrite), controllers.GetMyProducts)
+	product.Get("/archived", middleware.ProtectedOrAPIKey(), middleware.RequirePermission(rbac.ProductWrite), controllers.GetArchivedProducts)
+	product.Get("/export", middleware.ProtectedOrAPIKey(), middleware.RequirePermission(rbac.ProductWrite), controllers.ExportProducts)
+	product.Post("/import", middleware.ProtectedOrAPIKey(), middleware.RequirePermission(rbac.ProductWrite), controllers.ImportProducts)
 	product.Get("/:id", controllers.GetDetailProduct)
-	product.Patch("/:id", middleware.Protected(), middleware.RequireRole("seller"), controllers.EditProduct) 
-	product.Post("/", middleware.Protected(), middleware.RequireRole("seller"), controllers.AddProduct)
-	product.Delete("/:id", middleware.Protected(), middleware.RequireRole("seller"), controllers.DeleteProduct)
+	product.Patch("/:id", middleware.ProtectedOrAPIKey(), middleware.RequirePermission(rbac.ProductWrite), controllers.EditProduct) 
+	product.Post("/", middleware.ProtectedOrAPIKey(), middleware.RequirePermission(rbac.ProductWrite), controllers.AddProduct)
+	product.Delete("/:id", middleware.ProtectedOrAPIKey(), middleware.RequirePermission(rbac.ProductWrite), controllers.DeleteProduct)
+	product.Patch("/:id/archive", middleware.ProtectedOrAPIKey(), middleware.RequirePermission(rbac.ProductWrite), controllers.ArchiveProduct)
+	product.Patch("/:id/unarchive", middleware.ProtectedOrAPIKey(), middleware.RequirePermission(rbac.ProductWrite), controllers.UnarchiveProduct)
+
+	product.Get("/:id/variants", controllers.GetProductVariants)
+	product.Post("/:id/variants", middleware.ProtectedOrAPIKey(), middleware.RequirePermission(rbac.ProductWrite), controllers.AddProductVariant)
+	product.Patch("/:id/variants/:variant_id", middleware.ProtectedOrAPIKey(), middleware.RequirePermission(rbac.ProductWrite), controllers.EditProductVariant)
+	product.Delete("/:id/variants/:variant_id", middleware.ProtectedOrAPIKey(), middleware.RequirePermission(rbac.ProductWrite), controllers.DeleteProductVariant)
 }
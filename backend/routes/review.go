@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"finpro/controllers"
+	"finpro/middleware"
+	"finpro/rbac"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func ReviewRoutes(api fiber.Router) {
+	review := api.Group("/reviews")
+
+	review.Get("/", controllers.GetReviews)
+	review.Post("/", middleware.Protected(), controllers.CreateReview)
+	review.Patch("/:id/reply", middleware.Protected(), middleware.RequirePermission(rbac.OrderProcess), controllers.ReplyReview)
+
+	review.Get("/moderation", middleware.Protected(), middleware.RequirePermission(rbac.ReviewModerate), controllers.GetReviewsAdmin)
+	review.Patch("/:id/hide", middleware.Protected(), middleware.RequirePermission(rbac.ReviewModerate), controllers.HideReview)
+	review.Patch("/:id/restore", middleware.Protected(), middleware.RequirePermission(rbac.ReviewModerate), controllers.RestoreReview)
+	review.Delete("/:id", middleware.Protected(), middleware.RequirePermission(rbac.ReviewModerate), controllers.DeleteReview)
+}
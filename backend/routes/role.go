@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"finpro/controllers"
+	"finpro/middleware"
+	"finpro/rbac"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func RoleRoutes(api fiber.Router) {
+	role := api.Group("/roles", middleware.Protected(), middleware.RequirePermission(rbac.RoleManage))
+
+	role.Get("/", controllers.GetAllRoles)
+	role.Get("/permissions", controllers.GetAllPermissions)
+	role.Post("/", controllers.CreateRole)
+	role.Put("/:name/permissions", controllers.UpdateRolePermissions)
+	role.Delete("/:name", controllers.DeleteRole)
+}
@@ -13,4 +13,17 @@ func Routes(app *fiber.App) {
 	ShopRoutes(api)
 	CartRoutes(api)
 	OrderRoutes(api)
+	SettingRoutes(api)
+	RoleRoutes(api)
+	AuditRoutes(api)
+	APIKeyRoutes(api)
+	CategoryRoutes(api)
+	DiscountRoutes(api)
+	WishlistRoutes(api)
+	NotificationRoutes(api)
+	VoucherRoutes(api)
+	OfferRoutes(api)
+	AuctionRoutes(api)
+	FlashSaleRoutes(api)
+	ReviewRoutes(api)
 }
\ No newline at end of file
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"finpro/controllers"
+	"finpro/middleware"
+	"finpro/rbac"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SettingRoutes(api fiber.Router) {
+	setting := api.Group("/settings", middleware.Protected(), middleware.RequirePermission(rbac.SettingManage))
+
+	setting.Get("/", controllers.GetSettings)
+	setting.Patch("/", controllers.UpdateSettings)
+}
@@ -3,6 +3,7 @@ package routes
 import (
 	"finpro/controllers"
 	"finpro/middleware"
+	"finpro/rbac"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -10,15 +11,17 @@ import (
 func ShopRoutes(api fiber.Router) {
 	shop := api.Group("/shop")
 	
-	shop.Post("/", middleware.Protected(), middleware.RequireRole("buyer"), controllers.CreateShop)
+	shop.Post("/", middleware.Protected(), middleware.RequirePermission(rbac.ShopCreate), controllers.CreateShop)
 	
-	shop.Get("/approve", middleware.Protected(), middleware.RequireRole("admin"), controllers.GetAllShopApprove)
+	shop.Get("/approve", middleware.Protected(), middleware.RequirePermission(rbac.ShopApprove), controllers.GetAllShopApprove)
 	
-	shop.Get("/pending", middleware.Protected(), middleware.RequireRole("admin"), controllers.GetAllShopPending)
+	shop.Get("/pending", middleware.Protected(), middleware.RequirePermission(rbac.ShopApprove), controllers.GetAllShopPending)
 
-	shop.Patch("/accept", middleware.Protected(), middleware.RequireRole("admin"), controllers.AcceptRequestShop)
+	shop.Patch("/accept", middleware.Protected(), middleware.RequirePermission(rbac.ShopApprove), controllers.AcceptRequestShop)
 
-	shop.Get("/:id", middleware.Protected(), middleware.RequireRole("buyer", "seller", "admin"), controllers.GetDetailShop)
+	shop.Get("/:shop/storefront", controllers.GetStorefront)
 
-	shop.Patch("/:id", middleware.Protected(), middleware.RequireRole("seller", "admin"), controllers.EditShop)
+	shop.Get("/:id", middleware.Protected(), middleware.RequirePermission(rbac.ShopView), controllers.GetDetailShop)
+
+	shop.Patch("/:id", middleware.Protected(), middleware.RequirePermission(rbac.ShopWrite), controllers.EditShop)
 }
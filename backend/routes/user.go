@@ -3,14 +3,25 @@ package routes
 import (
 	"finpro/controllers"
 	"finpro/middleware"
+	"finpro/rbac"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 func UserRoutes(api fiber.Router){
 	user := api.Group("/user")
-	user.Get("/",middleware.Protected(), middleware.RequireRole("admin"), controllers.GetAllUsers)
+	user.Get("/",middleware.Protected(), middleware.RequirePermission(rbac.UserRead), controllers.GetAllUsers)
 	user.Get("/profile",middleware.Protected(), controllers.GetProfile)
 	user.Patch("/profile",middleware.Protected(), controllers.UpdateProfile)
-	user.Get("/:id", middleware.Protected(), middleware.RequireRole("admin"), controllers.GetUserById) 
+	user.Post("/2fa/setup", middleware.Protected(), middleware.RequirePermission(rbac.AccountTwoFactor), controllers.Setup2FA)
+	user.Post("/2fa/enable", middleware.Protected(), middleware.RequirePermission(rbac.AccountTwoFactor), controllers.Enable2FA)
+	user.Post("/2fa/disable", middleware.Protected(), middleware.RequirePermission(rbac.AccountTwoFactor), controllers.Disable2FA)
+	user.Post("/2fa/recovery-codes", middleware.Protected(), middleware.RequirePermission(rbac.AccountTwoFactor), controllers.RegenerateRecoveryCodes)
+	user.Get("/:id", middleware.Protected(), middleware.RequirePermission(rbac.UserRead), controllers.GetUserById) 
+	user.Patch("/:id/suspend", middleware.Protected(), middleware.RequirePermission(rbac.UserManage), controllers.SuspendUser)
+	user.Patch("/:id/ban", middleware.Protected(), middleware.RequirePermission(rbac.UserManage), controllers.BanUser)
+	user.Patch("/:id/reinstate", middleware.Protected(), middleware.RequirePermission(rbac.UserManage), controllers.ReinstateUser)
+	user.Patch("/:id/role", middleware.Protected(), middleware.RequirePermission(rbac.UserManage), controllers.ChangeUserRole)
+	user.Patch("/:id/force-password-reset", middleware.Protected(), middleware.RequirePermission(rbac.UserManage), controllers.ForcePasswordReset)
+	user.Delete("/:id", middleware.Protected(), middleware.RequirePermission(rbac.UserManage), controllers.DeleteUser)
 }
\ No newline at end of file
@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"finpro/controllers"
+	"finpro/middleware"
+	"finpro/rbac"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func VoucherRoutes(api fiber.Router) {
+	voucher := api.Group("/vouchers", middleware.Protected())
+
+	voucher.Post("/validate", controllers.ValidateVoucher)
+
+	manage := middleware.RequireAnyPermission(rbac.VoucherManage, rbac.ProductWrite)
+	voucher.Get("/", manage, controllers.GetVouchers)
+	voucher.Post("/", manage, controllers.CreateVoucher)
+	voucher.Patch("/:id", manage, controllers.UpdateVoucher)
+	voucher.Delete("/:id", manage, controllers.DeleteVoucher)
+}
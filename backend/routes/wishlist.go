@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"finpro/controllers"
+	"finpro/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func WishlistRoutes(api fiber.Router) {
+	wishlist := api.Group("/wishlist", middleware.Protected())
+
+	wishlist.Get("/", controllers.GetWishlist)
+	wishlist.Post("/", controllers.AddToWishlist)
+	wishlist.Post("/:id/move-to-cart", controllers.MoveWishlistToCart)
+	wishlist.Delete("/:id", controllers.RemoveFromWishlist)
+}
@@ -0,0 +1,90 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// stopwords holds common Indonesian and English words that carry no meaning
+// for product search.
+var stopwords = map[string]bool{
+	"dan": true, "atau": true, "yang": true, "di": true, "ke": true, "dari": true,
+	"untuk": true, "dengan": true, "ini": true, "itu": true, "ada": true, "juga": true,
+	"the": true, "and": true, "or": true, "for": true, "with": true, "of": true,
+	"a": true, "an": true, "in": true, "on": true, "to": true, "is": true,
+}
+
+// Analyze turns text into normalized search terms: lower case, accents
+// folded, stopwords dropped and light stemming applied.
+func Analyze(text string) []string {
+	terms := []string{}
+	for _, token := range tokenize(text) {
+		if stopwords[token] {
+			continue
+		}
+		terms = append(terms, stem(token))
+	}
+	return terms
+}
+
+// analyzeQuery is Analyze for user input. The last word is kept even when it
+// is a stopword, since it may be the start of a longer word still being typed.
+func analyzeQuery(text string) []string {
+	tokens := tokenize(text)
+	terms := []string{}
+	for i, token := range tokens {
+		if stopwords[token] && i < len(tokens)-1 {
+			continue
+		}
+		terms = append(terms, stem(token))
+	}
+	return terms
+}
+
+func tokenize(text string) []string {
+	folded, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), text)
+	if err != nil {
+		folded = text
+	}
+
+	return strings.FieldsFunc(strings.ToLower(folded), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// stem removes only inflections that are safe in both languages. Indonesian
+// prefixes are left alone on purpose: stripping "ke-" or "se-" would turn
+// "kemeja" into "meja" and "sepatu" into "patu". Typo tolerance covers most
+// of the remaining variation.
+func stem(term string) string {
+	if len(term) <= 4 {
+		return term
+	}
+
+	// Indonesian particles and possessive pronouns: "jaketnya", "bajuku".
+	for _, suffix := range []string{"lah", "kah", "pun", "nya", "ku", "mu"} {
+		if strings.HasSuffix(term, suffix) && len(term)-len(suffix) >= 4 {
+			return strings.TrimSuffix(term, suffix)
+		}
+	}
+
+	// English inflections: "shirts", "dresses", "running", "washed".
+	switch {
+	case strings.HasSuffix(term, "ies") && len(term) > 5:
+		return strings.TrimSuffix(term, "ies") + "y"
+	case strings.HasSuffix(term, "sses"):
+		return strings.TrimSuffix(term, "es")
+	case strings.HasSuffix(term, "ing") && len(term) > 6:
+		return strings.TrimSuffix(term, "ing")
+	case strings.HasSuffix(term, "ed") && len(term) > 5:
+		return strings.TrimSuffix(term, "ed")
+	case strings.HasSuffix(term, "s") && !strings.HasSuffix(term, "ss") && !strings.HasSuffix(term, "us"):
+		return strings.TrimSuffix(term, "s")
+	}
+
+	return term
+}
@@ -0,0 +1,181 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// vocabulary counts in how many documents each term appears. It drives typo
+// correction and prefix completion for every backend.
+type vocabulary struct {
+	mu    sync.RWMutex
+	terms map[string]int
+}
+
+func newVocabulary() *vocabulary {
+	return &vocabulary{terms: map[string]int{}}
+}
+
+func (v *vocabulary) add(terms []string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, term := range unique(terms) {
+		v.terms[term]++
+	}
+}
+
+func (v *vocabulary) remove(terms []string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, term := range unique(terms) {
+		v.terms[term]--
+		if v.terms[term] <= 0 {
+			delete(v.terms, term)
+		}
+	}
+}
+
+type expansion struct {
+	term   string
+	weight float64
+}
+
+// expand returns the terms a query term should match: the term itself when
+// it is known, close spellings when it is not (or is rare), and completions
+// when the term is the one still being typed.
+func (v *vocabulary) expand(term string, prefix bool) []expansion {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	expansions := []expansion{}
+	freq, known := v.terms[term]
+	if known {
+		expansions = append(expansions, expansion{term, 1})
+	}
+
+	if maxDist := allowedDistance(term); maxDist > 0 && freq < 2 {
+		for candidate := range v.terms {
+			if candidate == term || abs(len(candidate)-len(term)) > maxDist {
+				continue
+			}
+			if d := editDistance(term, candidate, maxDist); d <= maxDist {
+				expansions = append(expansions, expansion{candidate, 1 - 0.2*float64(d)})
+			}
+		}
+	}
+
+	if prefix && len(term) >= 2 {
+		for candidate := range v.terms {
+			if candidate != term && strings.HasPrefix(candidate, term) {
+				expansions = append(expansions, expansion{candidate, 0.7})
+			}
+		}
+	}
+
+	sort.Slice(expansions, func(i, j int) bool {
+		if expansions[i].weight != expansions[j].weight {
+			return expansions[i].weight > expansions[j].weight
+		}
+		return expansions[i].term < expansions[j].term
+	})
+	if len(expansions) > 10 {
+		expansions = expansions[:10]
+	}
+	return expansions
+}
+
+// correct rewrites the query with the best spelling of each unknown term.
+func (v *vocabulary) correct(terms []string) string {
+	corrected := make([]string, len(terms))
+	changed := false
+	for i, term := range terms {
+		corrected[i] = term
+		expansions := v.expand(term, false)
+		if len(expansions) > 0 && expansions[0].term != term {
+			corrected[i] = expansions[0].term
+			changed = true
+		}
+	}
+	if !changed {
+		return ""
+	}
+	return strings.Join(corrected, " ")
+}
+
+// allowedDistance keeps short words exact, otherwise "baju" would match half
+// of the catalog.
+func allowedDistance(term string) int {
+	switch n := len([]rune(term)); {
+	case n <= 3:
+		return 0
+	case n <= 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// editDistance is the optimal string alignment distance (Levenshtein plus
+// transposition of neighbours). It gives up early once max is exceeded.
+func editDistance(a, b string, max int) int {
+	ra, rb := []rune(a), []rune(b)
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] && prev2[j-2]+1 < curr[j] {
+				curr[j] = prev2[j-2] + 1
+			}
+			if curr[j] < rowMin {
+				rowMin = curr[j]
+			}
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func unique(terms []string) []string {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, term := range terms {
+		if !seen[term] {
+			seen[term] = true
+			result = append(result, term)
+		}
+	}
+	return result
+}
@@ -0,0 +1,227 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+type field int
+
+const (
+	fieldName field = iota
+	fieldLabel
+	fieldShop
+	fieldDescription
+	fieldCount
+)
+
+var fieldWeights = [fieldCount]float64{nameWeight, labelWeight, shopWeight, descriptionWeight}
+
+type memoryDoc struct {
+	name   string
+	terms  [fieldCount][]string
+	counts [fieldCount]map[string]int
+}
+
+// Memory is an in-process inverted index with BM25 ranking. It keeps nothing
+// on disk, which makes it the backend for tests and single-node setups.
+type Memory struct {
+	mu          sync.RWMutex
+	docs        map[uint]*memoryDoc
+	postings    map[string]map[uint]bool
+	totalLength [fieldCount]int
+	vocab       *vocabulary
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		docs:     map[uint]*memoryDoc{},
+		postings: map[string]map[uint]bool{},
+		vocab:    newVocabulary(),
+	}
+}
+
+func (m *Memory) Index(docs ...Document) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, doc := range docs {
+		m.remove(doc.ID)
+
+		entry := &memoryDoc{name: doc.Name}
+		entry.terms[fieldName] = Analyze(doc.Name)
+		entry.terms[fieldLabel] = Analyze(doc.Label)
+		entry.terms[fieldShop] = Analyze(doc.ShopName)
+		entry.terms[fieldDescription] = Analyze(doc.Description)
+
+		all := []string{}
+		for f := field(0); f < fieldCount; f++ {
+			entry.counts[f] = map[string]int{}
+			for _, term := range entry.terms[f] {
+				entry.counts[f][term]++
+				if m.postings[term] == nil {
+					m.postings[term] = map[uint]bool{}
+				}
+				m.postings[term][doc.ID] = true
+			}
+			m.totalLength[f] += len(entry.terms[f])
+			all = append(all, entry.terms[f]...)
+		}
+
+		m.docs[doc.ID] = entry
+		m.vocab.add(all)
+	}
+	return nil
+}
+
+func (m *Memory) Remove(ids ...uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range ids {
+		m.remove(id)
+	}
+	return nil
+}
+
+func (m *Memory) remove(id uint) {
+	entry, ok := m.docs[id]
+	if !ok {
+		return
+	}
+
+	all := []string{}
+	for f := field(0); f < fieldCount; f++ {
+		for _, term := range entry.terms[f] {
+			delete(m.postings[term], id)
+			if len(m.postings[term]) == 0 {
+				delete(m.postings, term)
+			}
+		}
+		m.totalLength[f] -= len(entry.terms[f])
+		all = append(all, entry.terms[f]...)
+	}
+
+	m.vocab.remove(all)
+	delete(m.docs, id)
+}
+
+func (m *Memory) Search(query string, limit int) (Result, error) {
+	terms := analyzeQuery(query)
+	if len(terms) == 0 {
+		return Result{}, nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	scores := map[uint]float64{}
+	matched := map[uint]int{}
+	for i, term := range terms {
+		best := map[uint]float64{}
+		for _, exp := range m.vocab.expand(term, i == len(terms)-1) {
+			for id := range m.postings[exp.term] {
+				if score := m.score(id, exp.term) * exp.weight; score > best[id] {
+					best[id] = score
+				}
+			}
+		}
+		for id, score := range best {
+			scores[id] += score
+			matched[id]++
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		// Documents matching more of the query terms rank first.
+		coverage := float64(matched[id]) / float64(len(terms))
+		hits = append(hits, Hit{ID: id, Score: score * coverage})
+	}
+	sortHits(hits)
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	return Result{Hits: hits, Corrected: m.vocab.correct(terms)}, nil
+}
+
+// score is the field weighted BM25 score of one term in one document.
+func (m *Memory) score(id uint, term string) float64 {
+	entry := m.docs[id]
+	docCount := float64(len(m.docs))
+	idf := math.Log(1 + (docCount-float64(len(m.postings[term]))+0.5)/(float64(len(m.postings[term]))+0.5))
+
+	total := 0.0
+	for f := field(0); f < fieldCount; f++ {
+		tf := float64(entry.counts[f][term])
+		if tf == 0 {
+			continue
+		}
+		avg := float64(m.totalLength[f]) / docCount
+		if avg == 0 {
+			avg = 1
+		}
+		norm := tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(len(entry.terms[f]))/avg))
+		total += fieldWeights[f] * idf * norm
+	}
+	return total
+}
+
+func (m *Memory) Suggest(prefix string, limit int) ([]Suggestion, error) {
+	terms := analyzeQuery(prefix)
+	if len(terms) == 0 {
+		return []Suggestion{}, nil
+	}
+
+	result, err := m.Search(prefix, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Only names matching the last, unfinished word are useful completions.
+	last := terms[len(terms)-1]
+	suggestions := []Suggestion{}
+	seen := map[string]bool{}
+	for _, hit := range result.Hits {
+		entry := m.docs[hit.ID]
+		key := strings.ToLower(entry.name)
+		if seen[key] || !nameCompletes(entry.terms[fieldName], last) {
+			continue
+		}
+		seen[key] = true
+		suggestions = append(suggestions, Suggestion{ID: hit.ID, Text: entry.name})
+		if limit > 0 && len(suggestions) >= limit {
+			break
+		}
+	}
+	return suggestions, nil
+}
+
+func nameCompletes(nameTerms []string, last string) bool {
+	for _, term := range nameTerms {
+		if strings.HasPrefix(term, last) || editDistance(term, last, allowedDistance(last)) <= allowedDistance(last) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortHits(hits []Hit) {
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID > hits[j].ID
+	})
+}
@@ -0,0 +1,212 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+var catalog = []Document{
+	{ID: 1, Name: "Kemeja Flanel Kotak", Description: "Kemeja bekas, kondisi mulus", Label: "preloved", ShopName: "Toko Andi"},
+	{ID: 2, Name: "Jaket Denim Levis", Description: "Jaket jeans biru", Label: "vintage", ShopName: "Denim House"},
+	{ID: 3, Name: "Sepatu Running Nike", Description: "Sepatu lari ukuran 42", Label: "sport", ShopName: "Sneaker Corner"},
+	{ID: 4, Name: "Vintage Leather Jacket", Description: "Genuine leather jacket, brown", Label: "vintage", ShopName: "Retro Shop"},
+	{ID: 5, Name: "Kaos Polos Hitam", Description: "Kaos katun, cocok dengan jaket denim", Label: "basic", ShopName: "Toko Andi"},
+}
+
+func newCatalog(t *testing.T) *Memory {
+	t.Helper()
+	engine := NewMemory()
+	if err := engine.Index(catalog...); err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+	return engine
+}
+
+func hitIDs(result Result) []uint {
+	ids := []uint{}
+	for _, hit := range result.Hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids
+}
+
+func TestMemorySearchRanking(t *testing.T) {
+	engine := newCatalog(t)
+
+	tests := []struct {
+		name  string
+		query string
+		want  []uint
+	}{
+		{"name before description", "jaket", []uint{2, 5}},
+		{"name and shop before description", "denim", []uint{2, 5}},
+		{"name and label before label", "vintage", []uint{4, 2}},
+		{"label", "preloved", []uint{1}},
+		{"shop", "andi", []uint{5, 1}},
+		{"description", "katun", []uint{5}},
+		{"more query terms first", "jaket denim levis", []uint{2, 5}},
+		{"indonesian suffix", "jaketnya", []uint{2, 5}},
+		// "jaket" is one edit away, exact matches still rank first.
+		{"english plural", "jackets", []uint{4, 2, 5}},
+		{"no match", "televisi", []uint{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := engine.Search(tt.query, 10)
+			if err != nil {
+				t.Fatalf("Search(%q): %v", tt.query, err)
+			}
+			if got := hitIDs(result); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemorySearchLimit(t *testing.T) {
+	engine := newCatalog(t)
+
+	result, err := engine.Search("jaket", 1)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if got := hitIDs(result); !reflect.DeepEqual(got, []uint{2}) {
+		t.Errorf("Search with limit 1 = %v, want [2]", got)
+	}
+}
+
+func TestMemorySearchTypoCorrection(t *testing.T) {
+	engine := newCatalog(t)
+
+	tests := []struct {
+		name      string
+		query     string
+		corrected string
+		top       uint
+	}{
+		{"indonesian", "kemja flanel", "kemeja flanel", 1},
+		{"indonesian transposition", "sepatu nkie", "sepatu nike", 3},
+		{"english", "lether jacket", "leather jacket", 4},
+		{"english transposition", "vintage lecther", "vintage leather", 4},
+		{"nothing to correct", "kaos hitam", "", 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := engine.Search(tt.query, 10)
+			if err != nil {
+				t.Fatalf("Search(%q): %v", tt.query, err)
+			}
+			if result.Corrected != tt.corrected {
+				t.Errorf("Search(%q).Corrected = %q, want %q", tt.query, result.Corrected, tt.corrected)
+			}
+			if len(result.Hits) == 0 || result.Hits[0].ID != tt.top {
+				t.Errorf("Search(%q) = %v, want %d first", tt.query, hitIDs(result), tt.top)
+			}
+		})
+	}
+}
+
+func TestMemorySearchShortWordsStayExact(t *testing.T) {
+	engine := newCatalog(t)
+
+	// Words of up to three letters are never corrected, they would match
+	// half of the catalog. They still complete as a prefix.
+	result, err := engine.Search("kao", 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if result.Corrected != "" {
+		t.Errorf("Corrected = %q, want none for a three letter word", result.Corrected)
+	}
+	if got := hitIDs(result); !reflect.DeepEqual(got, []uint{5}) {
+		t.Errorf("Search(\"kao\") = %v, want [5]", got)
+	}
+}
+
+func TestMemorySuggest(t *testing.T) {
+	engine := newCatalog(t)
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"sep", []string{"Sepatu Running Nike"}},
+		{"jak", []string{"Jaket Denim Levis"}},
+		{"kaos po", []string{"Kaos Polos Hitam"}},
+		{"vint", []string{"Vintage Leather Jacket"}},
+		{"zz", []string{}},
+		{"", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			suggestions, err := engine.Suggest(tt.prefix, 5)
+			if err != nil {
+				t.Fatalf("Suggest(%q): %v", tt.prefix, err)
+			}
+			got := []string{}
+			for _, s := range suggestions {
+				got = append(got, s.Text)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Suggest(%q) = %q, want %q", tt.prefix, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryRemoveAndReindex(t *testing.T) {
+	engine := newCatalog(t)
+
+	if err := engine.Remove(2); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	assertHits(t, engine, "denim", []uint{5})
+	assertHits(t, engine, "levis", []uint{})
+
+	// The removed words are gone from the vocabulary too, so they are no
+	// longer offered as corrections or completions.
+	result, _ := engine.Search("levs", 10)
+	if result.Corrected != "" {
+		t.Errorf("Corrected = %q after removal, want none", result.Corrected)
+	}
+	if suggestions, _ := engine.Suggest("lev", 5); len(suggestions) != 0 {
+		t.Errorf("Suggest after removal = %v, want none", suggestions)
+	}
+
+	renamed := catalog[1]
+	renamed.Name = "Jaket Denim Wrangler"
+	if err := engine.Index(renamed); err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+	assertHits(t, engine, "wrangler", []uint{2})
+	assertHits(t, engine, "levis", []uint{})
+	assertHits(t, engine, "denim", []uint{2, 5})
+}
+
+func TestMemoryIndexReplacesDocument(t *testing.T) {
+	engine := newCatalog(t)
+
+	if err := engine.Index(catalog[0], catalog[0]); err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+	assertHits(t, engine, "flanel", []uint{1})
+
+	changed := catalog[0]
+	changed.Name = "Kemeja Batik"
+	if err := engine.Index(changed); err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+	assertHits(t, engine, "flanel", []uint{})
+	assertHits(t, engine, "batik", []uint{1})
+}
+
+func assertHits(t *testing.T, engine Engine, query string, want []uint) {
+	t.Helper()
+	result, err := engine.Search(query, 10)
+	if err != nil {
+		t.Fatalf("Search(%q): %v", query, err)
+	}
+	if got := hitIDs(result); !reflect.DeepEqual(got, want) {
+		t.Errorf("Search(%q) = %v, want %v", query, got, want)
+	}
+}
@@ -0,0 +1,216 @@
+package search
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// productSearchRow stores the analyzed terms of each field in its own
+// FULLTEXT indexed column, so each field can be weighted in the ranking.
+type productSearchRow struct {
+	ProductID        uint      `gorm:"primaryKey;autoIncrement:false"`
+	Name             string    `gorm:"type:varchar(100)"`
+	NameTerms        string    `gorm:"type:text;index:ft_search_name,class:FULLTEXT"`
+	LabelTerms       string    `gorm:"type:text;index:ft_search_label,class:FULLTEXT"`
+	ShopTerms        string    `gorm:"type:text;index:ft_search_shop,class:FULLTEXT"`
+	DescriptionTerms string    `gorm:"type:text;index:ft_search_description,class:FULLTEXT"`
+	UpdatedAt        time.Time `gorm:"autoUpdateTime"`
+}
+
+func (*productSearchRow) TableName() string {
+	return "product_search"
+}
+
+// MySQL searches a FULLTEXT indexed table in boolean mode. Typo correction
+// and completion come from an in-memory vocabulary loaded at startup.
+type MySQL struct {
+	db    *gorm.DB
+	vocab *vocabulary
+}
+
+var safeTerm = regexp.MustCompile(`^[\p{L}\p{N}]+$`)
+
+func NewMySQL(db *gorm.DB) (*MySQL, error) {
+	if err := db.AutoMigrate(&productSearchRow{}); err != nil {
+		return nil, err
+	}
+
+	engine := &MySQL{db: db, vocab: newVocabulary()}
+
+	var rows []productSearchRow
+	err := db.Select("name_terms", "label_terms", "shop_terms", "description_terms").
+		FindInBatches(&rows, 1000, func(*gorm.DB, int) error {
+			for _, row := range rows {
+				engine.vocab.add(row.terms())
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return engine, nil
+}
+
+func (r productSearchRow) terms() []string {
+	all := strings.Fields(r.NameTerms)
+	all = append(all, strings.Fields(r.LabelTerms)...)
+	all = append(all, strings.Fields(r.ShopTerms)...)
+	return append(all, strings.Fields(r.DescriptionTerms)...)
+}
+
+func (m *MySQL) Index(docs ...Document) error {
+	for _, doc := range docs {
+		row := productSearchRow{
+			ProductID:        doc.ID,
+			Name:             doc.Name,
+			NameTerms:        strings.Join(Analyze(doc.Name), " "),
+			LabelTerms:       strings.Join(Analyze(doc.Label), " "),
+			ShopTerms:        strings.Join(Analyze(doc.ShopName), " "),
+			DescriptionTerms: strings.Join(Analyze(doc.Description), " "),
+		}
+
+		var old productSearchRow
+		if err := m.db.First(&old, doc.ID).Error; err == nil {
+			m.vocab.remove(old.terms())
+		}
+
+		if err := m.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&row).Error; err != nil {
+			return err
+		}
+		m.vocab.add(row.terms())
+	}
+	return nil
+}
+
+func (m *MySQL) Remove(ids ...uint) error {
+	for _, id := range ids {
+		var old productSearchRow
+		if err := m.db.First(&old, id).Error; err != nil {
+			continue
+		}
+		if err := m.db.Delete(&old).Error; err != nil {
+			return err
+		}
+		m.vocab.remove(old.terms())
+	}
+	return nil
+}
+
+func (m *MySQL) Search(query string, limit int) (Result, error) {
+	terms := analyzeQuery(query)
+	if len(terms) == 0 {
+		return Result{}, nil
+	}
+
+	against := m.booleanQuery(terms, true)
+	if against == "" {
+		return Result{Corrected: m.vocab.correct(terms)}, nil
+	}
+
+	var rows []struct {
+		ProductID uint
+		Score     float64
+	}
+	score := "(? * MATCH(name_terms) AGAINST (? IN BOOLEAN MODE) + " +
+		"? * MATCH(label_terms) AGAINST (? IN BOOLEAN MODE) + " +
+		"? * MATCH(shop_terms) AGAINST (? IN BOOLEAN MODE) + " +
+		"? * MATCH(description_terms) AGAINST (? IN BOOLEAN MODE))"
+
+	stmt := m.db.Model(&productSearchRow{}).
+		Select("product_id, "+score+" AS score",
+			nameWeight, against, labelWeight, against, shopWeight, against, descriptionWeight, against).
+		Where("MATCH(name_terms) AGAINST (? IN BOOLEAN MODE) OR MATCH(label_terms) AGAINST (? IN BOOLEAN MODE) OR "+
+			"MATCH(shop_terms) AGAINST (? IN BOOLEAN MODE) OR MATCH(description_terms) AGAINST (? IN BOOLEAN MODE)",
+			against, against, against, against).
+		Order("score DESC, product_id DESC")
+	if limit > 0 {
+		stmt = stmt.Limit(limit)
+	}
+
+	if err := stmt.Scan(&rows).Error; err != nil {
+		return Result{}, err
+	}
+
+	hits := make([]Hit, 0, len(rows))
+	for _, row := range rows {
+		hits = append(hits, Hit{ID: row.ProductID, Score: row.Score})
+	}
+	return Result{Hits: hits, Corrected: m.vocab.correct(terms)}, nil
+}
+
+func (m *MySQL) Suggest(prefix string, limit int) ([]Suggestion, error) {
+	terms := analyzeQuery(prefix)
+	if len(terms) == 0 {
+		return []Suggestion{}, nil
+	}
+
+	against := m.booleanQuery(terms, true)
+	if against == "" {
+		return []Suggestion{}, nil
+	}
+
+	var rows []productSearchRow
+	query := m.db.Select("product_id", "name").
+		Where("MATCH(name_terms) AGAINST (? IN BOOLEAN MODE)", against).
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:                "MATCH(name_terms) AGAINST (? IN BOOLEAN MODE) DESC",
+			Vars:               []interface{}{against},
+			WithoutParentheses: true,
+		}})
+	if limit > 0 {
+		query = query.Limit(limit * 2)
+	}
+	if err := query.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	suggestions := []Suggestion{}
+	seen := map[string]bool{}
+	for _, row := range rows {
+		key := strings.ToLower(row.Name)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		suggestions = append(suggestions, Suggestion{ID: row.ProductID, Text: row.Name})
+		if limit > 0 && len(suggestions) >= limit {
+			break
+		}
+	}
+	return suggestions, nil
+}
+
+// booleanQuery expands every term with its typo and prefix variants. Terms
+// are restricted to letters and digits, so no boolean operator from the user
+// ever reaches MySQL.
+func (m *MySQL) booleanQuery(terms []string, prefixLast bool) string {
+	parts := []string{}
+	for i, term := range terms {
+		last := prefixLast && i == len(terms)-1
+		expansions := m.vocab.expand(term, false)
+		if len(expansions) == 0 {
+			expansions = []expansion{{term, 1}}
+		}
+
+		for _, exp := range expansions {
+			if !safeTerm.MatchString(exp.term) {
+				continue
+			}
+			// Weaker spellings get the "~" operator, which lowers their rank.
+			operand := exp.term
+			if last && len(exp.term) >= 2 {
+				operand += "*"
+			}
+			if exp.weight < 1 {
+				operand = "~" + operand
+			}
+			parts = append(parts, operand)
+		}
+	}
+	return strings.Join(parts, " ")
+}
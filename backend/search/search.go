@@ -0,0 +1,63 @@
+package search
+
+import "sync"
+
+// Document is the searchable view of a product.
+type Document struct {
+	ID          uint
+	Name        string
+	Description string
+	Label       string
+	ShopName    string
+}
+
+type Hit struct {
+	ID    uint
+	Score float64
+}
+
+type Result struct {
+	Hits []Hit
+	// Corrected is the query after typo correction, empty when nothing changed.
+	Corrected string
+}
+
+type Suggestion struct {
+	ID   uint
+	Text string
+}
+
+// Engine is implemented by every search backend. Index replaces documents
+// with the same ID.
+type Engine interface {
+	Index(docs ...Document) error
+	Remove(ids ...uint) error
+	Search(query string, limit int) (Result, error)
+	Suggest(prefix string, limit int) ([]Suggestion, error)
+}
+
+var (
+	mu      sync.RWMutex
+	current Engine = NewMemory()
+)
+
+// Init sets the engine used by the package level functions.
+func Init(engine Engine) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = engine
+}
+
+func Default() Engine {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Field weights used by every backend so ranking stays comparable.
+const (
+	nameWeight        = 3.0
+	labelWeight       = 2.0
+	shopWeight        = 1.5
+	descriptionWeight = 1.0
+)
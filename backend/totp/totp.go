@@ -0,0 +1,103 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Parameters follow the RFC 6238 defaults that authenticator apps expect:
+// HMAC-SHA1, 6 digits and a 30 second time step.
+const (
+	Digits = 6
+	Period = 30
+	// Skew is the number of time steps accepted before and after the current one.
+	Skew = 1
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func GenerateSecret() (string, error) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(secret), nil
+}
+
+func Code(secret string, t time.Time) (string, error) {
+	return codeAt(secret, uint64(t.Unix()/Period))
+}
+
+func Validate(secret, code string, t time.Time) bool {
+	_, ok := ValidateAfter(secret, code, t, 0)
+	return ok
+}
+
+// ValidateAfter is Validate for codes that may be used once: it only accepts
+// a code of a time step after lastStep, the step of the code accepted
+// before, and returns the step of the code. Store the step and pass it next
+// time so an observed code cannot be replayed within the Skew window.
+func ValidateAfter(secret, code string, t time.Time, lastStep int64) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != Digits {
+		return 0, false
+	}
+
+	counter := t.Unix() / Period
+	for i := -Skew; i <= Skew; i++ {
+		step := counter + int64(i)
+		if step <= lastStep {
+			continue
+		}
+		expected, err := codeAt(secret, uint64(step))
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// URI builds the otpauth:// link that authenticator apps import, usually by
+// scanning it as a QR code.
+func URI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer + ":" + account)
+
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(Digits))
+	params.Set("period", fmt.Sprint(Period))
+
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+func codeAt(secret string, counter uint64) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", err
+	}
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", Digits, value%1000000), nil
+}